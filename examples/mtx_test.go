@@ -2,10 +2,13 @@ package examples
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/kozmod/oniontx/mtx"
+	"github.com/kozmod/oniontx/saga"
 )
 
 type mtxExampleKey struct{}
@@ -102,4 +105,26 @@ func Test_Mtx_example(t *testing.T) {
 			t.Fatal("error expected")
 		}
 	})
+
+	t.Run("retry serialization failures with saga retry policy", func(t *testing.T) {
+		errSerialization := errors.New("serialization failure")
+
+		retryTransactor := transactor.WithRetry(
+			saga.NewAdvancedRetryPolicy(3, 10*time.Millisecond, saga.NewExponentialBackoff()).
+				WithJitter(saga.NewFullJitter()),
+			mtx.RetryOn(errSerialization),
+		)
+
+		attempts := 0
+		err := retryTransactor.WithinTx(ctx, func(ctx context.Context) error {
+			attempts++
+			if attempts == 1 {
+				return errSerialization
+			}
+			return repoAInsert(ctx)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
 }
@@ -0,0 +1,16 @@
+module github.com/kozmod/oniontx/gormtx
+
+go 1.25.0
+
+require (
+	github.com/kozmod/oniontx v0.9.2
+	gorm.io/driver/sqlite v1.6.0
+	gorm.io/gorm v1.30.0
+)
+
+require (
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/jinzhu/now v1.1.5 // indirect
+	github.com/mattn/go-sqlite3 v1.14.22 // indirect
+	golang.org/x/text v0.20.0 // indirect
+)
@@ -0,0 +1,153 @@
+// Package gormtx provides the [gorm.DB] implementation of the mtx contracts.
+package gormtx
+
+import (
+	"context"
+	"database/sql"
+
+	"gorm.io/gorm"
+
+	"github.com/kozmod/oniontx/mtx"
+)
+
+// Wrapper wraps [gorm.DB] and implements [mtx.TxBeginner].
+type Wrapper struct {
+	*gorm.DB
+
+	// txOptions is options for gorm transactions begin.
+	txOptions *sql.TxOptions
+}
+
+// NewDB returns [gorm.DB] wrapper with transaction's options (nil means the driver defaults).
+func NewDB(db *gorm.DB, options *sql.TxOptions) *Wrapper {
+	return &Wrapper{
+		DB:        db,
+		txOptions: options,
+	}
+}
+
+// BeginTx starts a transaction with the options passed to [NewDB].
+func (w *Wrapper) BeginTx(ctx context.Context) (*TxWrapper, error) {
+	return w.begin(ctx, w.txOptions)
+}
+
+// BeginTxWithOptions starts a transaction with [mtx.TxOptions] and implements [mtx.TxBeginnerWithOptions].
+// The options override the options passed to [NewDB]. Deferrable is not supported by database/sql and is ignored.
+func (w *Wrapper) BeginTxWithOptions(ctx context.Context, opts mtx.TxOptions) (*TxWrapper, error) {
+	return w.begin(ctx, &sql.TxOptions{
+		Isolation: sql.IsolationLevel(opts.Isolation),
+		ReadOnly:  opts.ReadOnly,
+	})
+}
+
+func (w *Wrapper) begin(ctx context.Context, opts *sql.TxOptions) (*TxWrapper, error) {
+	tx := w.DB.WithContext(ctx).Begin(opts)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &TxWrapper{DB: tx}, nil
+}
+
+// TxOptions returns the [mtx.TxOption] which sets the options of [sql.TxOptions].
+// It can be used to begin a single call with the options:
+//
+//	err := transactor.Transactor.WithTxOptions(gormtx.TxOptions(sql.TxOptions{
+//	    Isolation: sql.LevelSerializable,
+//	})).WithinTx(ctx, fn)
+func TxOptions(opts sql.TxOptions) mtx.TxOption {
+	return func(o *mtx.TxOptions) {
+		o.Isolation = mtx.IsolationLevel(opts.Isolation)
+		o.ReadOnly = opts.ReadOnly
+	}
+}
+
+// TxWrapper wraps the transaction [gorm.DB] and implements [mtx.Tx], [mtx.SavepointTx] and [mtx.SavepointCapableTx].
+// If the dialector does not implement [gorm.SavePointerDialectorInterface], nested calls join the transaction.
+//
+// The operations are executed within new sessions of the transaction,
+// so the errors of the previous operations are not accumulated in DB.Error.
+type TxWrapper struct {
+	*gorm.DB
+}
+
+// Rollback aborts the transaction.
+func (t *TxWrapper) Rollback(ctx context.Context) error {
+	return t.DB.WithContext(ctx).Rollback().Error
+}
+
+// Commit commits the transaction.
+func (t *TxWrapper) Commit(ctx context.Context) error {
+	return t.DB.WithContext(ctx).Commit().Error
+}
+
+// SupportsSavepoints reports whether the dialector implements [gorm.SavePointerDialectorInterface].
+func (t *TxWrapper) SupportsSavepoints() bool {
+	_, ok := t.DB.Dialector.(gorm.SavePointerDialectorInterface)
+	return ok
+}
+
+// Savepoint creates the savepoint ([gorm.DB.SavePoint]).
+func (t *TxWrapper) Savepoint(ctx context.Context, name string) error {
+	return t.DB.WithContext(ctx).SavePoint(name).Error
+}
+
+// RollbackTo rolls back the transaction to the savepoint ([gorm.DB.RollbackTo]).
+func (t *TxWrapper) RollbackTo(ctx context.Context, name string) error {
+	return t.DB.WithContext(ctx).RollbackTo(name).Error
+}
+
+// Release does nothing: gorm has no API to release a savepoint (the nested [gorm.DB.Transaction] does not release it either),
+// so the savepoints are released only when the transaction ends. Every nested call holds its savepoint
+// until then, so many nested calls within one transaction (for example, in a loop) consume
+// the memory of the database session (and the subtransactions of PostgreSQL).
+func (t *TxWrapper) Release(_ context.Context, _ string) error {
+	return nil
+}
+
+// Transactor manage a transaction for single [gorm.DB] instance.
+type Transactor struct {
+	*mtx.Transactor[*Wrapper, *TxWrapper]
+}
+
+// NewTransactor returns new [Transactor] ([gorm] implementation).
+func NewTransactor(db *Wrapper) *Transactor {
+	var (
+		operator   = mtx.NewContextOperator[*Wrapper, *TxWrapper](db)
+		transactor = mtx.NewTransactor[*Wrapper, *TxWrapper](db, operator)
+	)
+	return &Transactor{
+		Transactor: transactor,
+	}
+}
+
+// With returns a new [Transactor] with the configured [mtx.Transactor].
+func (t *Transactor) With(configure func(t *mtx.Transactor[*Wrapper, *TxWrapper]) *mtx.Transactor[*Wrapper, *TxWrapper]) *Transactor {
+	return &Transactor{
+		Transactor: configure(t.Transactor),
+	}
+}
+
+// TryGetTx returns pointer of the transaction [gorm.DB] and "true" from [context.Context] or return `false`.
+func (t *Transactor) TryGetTx(ctx context.Context) (*gorm.DB, bool) {
+	wrapper, ok := t.Transactor.TryGetTx(ctx)
+	if !ok || wrapper == nil || wrapper.DB == nil {
+		return nil, false
+	}
+	return wrapper.DB, true
+}
+
+// TxBeginner returns pointer of [gorm.DB].
+func (t *Transactor) TxBeginner() *gorm.DB {
+	return t.Transactor.TxBeginner().DB
+}
+
+// GetExecutor returns a new session of [gorm.DB] (the transaction from [context.Context] or the beginner)
+// with the context (see [gorm.DB.WithContext]), so the conditions and the errors
+// of the statements are not shared between the calls.
+func (t *Transactor) GetExecutor(ctx context.Context) *gorm.DB {
+	exec, ok := t.TryGetTx(ctx)
+	if !ok {
+		exec = t.TxBeginner()
+	}
+	return exec.WithContext(ctx)
+}
@@ -0,0 +1,202 @@
+package gormtx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+	"github.com/kozmod/oniontx/mtx"
+)
+
+type user struct {
+	ID   int64
+	Name string
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "gorm.db")), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	assert.NoError(t, err)
+	sqlDB, err := db.DB()
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+	})
+	sqlDB.SetMaxOpenConns(1)
+
+	assert.NoError(t, db.AutoMigrate(&user{}))
+	return db
+}
+
+func names(t *testing.T, db *gorm.DB) []string {
+	t.Helper()
+	var users []user
+	assert.NoError(t, db.Order("id").Find(&users).Error)
+	result := make([]string, 0, len(users))
+	for _, u := range users {
+		result = append(result, u.Name)
+	}
+	return result
+}
+
+func Test_Transactor(t *testing.T) {
+	t.Run("commit", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			db         = newTestDB(t)
+			transactor = NewTransactor(NewDB(db, nil))
+		)
+		err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+			_, ok := transactor.TryGetTx(ctx)
+			assert.True(t, ok)
+			return transactor.GetExecutor(ctx).Create(&user{Name: "a"}).Error
+		})
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal([]string{"a"}, names(t, db)))
+	})
+	t.Run("rollback", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			db         = newTestDB(t)
+			transactor = NewTransactor(NewDB(db, nil))
+			expErr     = fmt.Errorf("call error")
+		)
+		err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+			if err := transactor.GetExecutor(ctx).Create(&user{Name: "a"}).Error; err != nil {
+				return err
+			}
+			return expErr
+		})
+		assert.ErrorIs(t, err, expErr)
+		assert.ErrorIs(t, err, mtx.ErrRollbackSuccess)
+		assert.True(t, slices.Equal([]string{}, names(t, db)))
+	})
+	t.Run("nested_savepoint", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			db         = newTestDB(t)
+			transactor = NewTransactor(NewDB(db, nil))
+		)
+		err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+			if err := transactor.GetExecutor(ctx).Create(&user{Name: "a"}).Error; err != nil {
+				return err
+			}
+			err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+				if err := transactor.GetExecutor(ctx).Create(&user{Name: "b"}).Error; err != nil {
+					return err
+				}
+				return fmt.Errorf("nested error")
+			})
+			assert.ErrorIs(t, err, mtx.ErrRollbackToSavepointSuccess)
+			return transactor.WithinTx(ctx, func(ctx context.Context) error {
+				return transactor.GetExecutor(ctx).Create(&user{Name: "c"}).Error
+			})
+		})
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal([]string{"a", "c"}, names(t, db)))
+	})
+	t.Run("nested_join_without_savepoints", func(t *testing.T) {
+		var (
+			ctx = context.Background()
+			db  = newTestDB(t)
+		)
+		// the dialector without gorm.SavePointerDialectorInterface.
+		noSavepoints, err := gorm.Open(struct{ gorm.Dialector }{db.Dialector}, &gorm.Config{
+			Logger:   logger.Discard,
+			ConnPool: db.ConnPool,
+		})
+		assert.NoError(t, err)
+		transactor := NewTransactor(NewDB(noSavepoints, nil))
+
+		err = transactor.WithinTx(ctx, func(ctx context.Context) error {
+			tx, ok := transactor.Transactor.TryGetTx(ctx)
+			assert.True(t, ok)
+			assert.True(t, !tx.SupportsSavepoints())
+			err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+				return transactor.GetExecutor(ctx).Create(&user{Name: "a"}).Error
+			})
+			assert.NoError(t, err)
+			return transactor.GetExecutor(ctx).Create(&user{Name: "b"}).Error
+		})
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal([]string{"a", "b"}, names(t, db)))
+	})
+	t.Run("begin_error", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			db         = newTestDB(t)
+			transactor = NewTransactor(NewDB(db, nil))
+		)
+		sqlDB, err := db.DB()
+		assert.NoError(t, err)
+		assert.NoError(t, sqlDB.Close())
+		err = transactor.WithinTx(ctx, func(ctx context.Context) error {
+			t.Fatalf("should not have been called")
+			return nil
+		})
+		assert.ErrorIs(t, err, mtx.ErrBeginTx)
+	})
+	t.Run("commit_and_rollback_errors", func(t *testing.T) {
+		var (
+			ctx     = context.Background()
+			wrapper = NewDB(newTestDB(t), nil)
+		)
+		tx, err := wrapper.BeginTx(ctx)
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Commit(ctx))
+		assert.ErrorIs(t, tx.Commit(ctx), sql.ErrTxDone)
+		assert.ErrorIs(t, tx.Rollback(ctx), sql.ErrTxDone)
+	})
+	t.Run("errors_are_not_accumulated", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			db         = newTestDB(t)
+			transactor = NewTransactor(NewDB(db, nil))
+		)
+		err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+			err := transactor.GetExecutor(ctx).Exec(`INSERT INTO unknown (name) VALUES (?)`, "a").Error
+			assert.Error(t, err)
+			return transactor.GetExecutor(ctx).Create(&user{Name: "b"}).Error
+		})
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal([]string{"b"}, names(t, db)))
+	})
+	t.Run("executor_context", func(t *testing.T) {
+		type ctxKey struct{}
+		var (
+			ctx        = context.WithValue(context.Background(), ctxKey{}, "value")
+			transactor = NewTransactor(NewDB(newTestDB(t), nil))
+		)
+		assert.Equal[any](t, "value", transactor.GetExecutor(ctx).Statement.Context.Value(ctxKey{}))
+		err := transactor.WithinTx(context.Background(), func(txCtx context.Context) error {
+			callCtx := context.WithValue(txCtx, ctxKey{}, "call")
+			assert.Equal[any](t, "call", transactor.GetExecutor(callCtx).Statement.Context.Value(ctxKey{}))
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+	t.Run("tx_options", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			db         = newTestDB(t)
+			transactor = NewTransactor(NewDB(db, &sql.TxOptions{ReadOnly: true}))
+		)
+		err := transactor.Transactor.WithTxOptions(TxOptions(sql.TxOptions{Isolation: sql.LevelSerializable})).
+			WithinTx(ctx, func(ctx context.Context) error {
+				tx, _ := transactor.TryGetTx(ctx)
+				return tx.WithContext(ctx).Create(&user{Name: "a"}).Error
+			})
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal([]string{"a"}, names(t, db)))
+	})
+}
@@ -0,0 +1,50 @@
+package errors
+
+import (
+	"log/slog"
+	"strconv"
+)
+
+const (
+	// LogKeyMessage is the key of the error message in LogValue.
+	LogKeyMessage = "message"
+	// LogKeyErrors is the key of the joined errors in LogValue.
+	LogKeyErrors = "errors"
+)
+
+// LogValue returns a structured slog.Value of the error:
+// the message of the error and the joined errors (`Unwrap() []error`) as nested groups
+// keyed by the index of the error.
+//
+// The errors wrapped by a single error (`Unwrap() error`) are not logged separately,
+// because the message of the wrapper already contains their messages.
+func LogValue(err error) slog.Value {
+	if err == nil {
+		return slog.AnyValue(nil)
+	}
+
+	attrs := []slog.Attr{slog.String(LogKeyMessage, err.Error())}
+	if errs := joined(err); len(errs) > 0 {
+		children := make([]slog.Attr, 0, len(errs))
+		for i, e := range errs {
+			children = append(children, slog.Attr{Key: strconv.Itoa(i), Value: LogValue(e)})
+		}
+		attrs = append(attrs, slog.Attr{Key: LogKeyErrors, Value: slog.GroupValue(children...)})
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// joined returns the first joined errors in the chain of the wrapped errors.
+func joined(err error) []error {
+	for err != nil {
+		switch e := err.(type) {
+		case interface{ Unwrap() []error }:
+			return e.Unwrap()
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package errors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+)
+
+func Test_LogValue(t *testing.T) {
+	var (
+		errA = fmt.Errorf("errA")
+		errB = fmt.Errorf("errB")
+		errC = fmt.Errorf("errC")
+	)
+
+	t.Run("joined", func(t *testing.T) {
+		var (
+			buf    bytes.Buffer
+			logger = slog.New(slog.NewJSONHandler(&buf, nil))
+			err    = fmt.Errorf("outer: %w", Join(errA, fmt.Errorf("inner: %w", Join(errB, errC))))
+		)
+		logger.Info("msg", slog.Attr{Key: "error", Value: LogValue(err)})
+
+		var record struct {
+			Error struct {
+				Message string `json:"message"`
+				Errors  map[string]struct {
+					Message string `json:"message"`
+					Errors  map[string]struct {
+						Message string `json:"message"`
+					} `json:"errors"`
+				} `json:"errors"`
+			} `json:"error"`
+		}
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+		assert.Equal(t, err.Error(), record.Error.Message)
+		assert.Equal(t, 2, len(record.Error.Errors))
+		assert.Equal(t, errA.Error(), record.Error.Errors["0"].Message)
+		assert.Equal(t, 0, len(record.Error.Errors["0"].Errors))
+		assert.Equal(t, "inner: errB: errC", record.Error.Errors["1"].Message)
+		assert.Equal(t, errB.Error(), record.Error.Errors["1"].Errors["0"].Message)
+		assert.Equal(t, errC.Error(), record.Error.Errors["1"].Errors["1"].Message)
+	})
+	t.Run("single", func(t *testing.T) {
+		value := LogValue(fmt.Errorf("wrap: %w", errA))
+		assert.Equal(t, slog.KindGroup, value.Kind())
+		assert.Equal(t, 1, len(value.Group()))
+		assert.Equal(t, "wrap: errA", value.Group()[0].Value.String())
+	})
+	t.Run("nil", func(t *testing.T) {
+		assert.Equal(t, slog.KindAny, LogValue(nil).Kind())
+	})
+}
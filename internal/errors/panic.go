@@ -1,8 +1,45 @@
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"runtime/debug"
+)
 
-// WrapPanic transforms panic to error.
-func WrapPanic(p any) error {
-	return fmt.Errorf("panic [%v]", p)
+// PanicError is an error created from a recovered panic.
+// It keeps the original panic value and the stack trace captured at the recovery.
+type PanicError struct {
+	value any
+	stack []byte
+}
+
+// WrapPanic transforms panic to error and captures the stack trace of the current goroutine.
+// It should be called in the deferred function which recovered the panic.
+func WrapPanic(p any) *PanicError {
+	return &PanicError{
+		value: p,
+		stack: debug.Stack(),
+	}
+}
+
+// Error returns the error message with the panic value.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic [%v]", e.value)
+}
+
+// Value returns the recovered panic value.
+func (e *PanicError) Value() any {
+	return e.value
+}
+
+// Stack returns the stack trace captured at the recovery of the panic.
+func (e *PanicError) Stack() []byte {
+	return e.stack
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.value.(error); ok {
+		return err
+	}
+	return nil
 }
@@ -0,0 +1,43 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+)
+
+func Test_WrapPanic(t *testing.T) {
+	recovered := func(fn func()) (err *PanicError) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = WrapPanic(p)
+			}
+		}()
+		fn()
+		return nil
+	}
+
+	t.Run("value", func(t *testing.T) {
+		err := recovered(func() {
+			panic("some panic")
+		})
+		assert.Equal(t, "panic [some panic]", err.Error())
+		assert.Equal[any](t, "some panic", err.Value())
+		assert.True(t, strings.Contains(string(err.Stack()), "Test_WrapPanic"))
+		assert.NoError(t, err.Unwrap())
+	})
+	t.Run("error_value", func(t *testing.T) {
+		errA := fmt.Errorf("errA")
+		err := recovered(func() {
+			panic(errA)
+		})
+		assert.ErrorIs(t, err, errA)
+
+		var pErr *PanicError
+		assert.True(t, errors.As(Join(fmt.Errorf("some error"), err), &pErr))
+		assert.Equal[any](t, errA, pErr.Value())
+	})
+}
@@ -0,0 +1,235 @@
+// Package mongotx provides the [mongo.Client] implementation of the mtx contracts.
+package mongotx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"github.com/kozmod/oniontx/mtx"
+)
+
+const (
+	// LabelTransientTransactionError is the label of the errors after which the whole transaction can be retried.
+	LabelTransientTransactionError = "TransientTransactionError"
+
+	// LabelUnknownTransactionCommitResult is the label of the commit errors after which the commit can be retried.
+	LabelUnknownTransactionCommitResult = "UnknownTransactionCommitResult"
+
+	// DefaultCommitRetryTimeout is the default time limit of the commit retries
+	// (equal to the limit of [mongo.Session.WithTransaction]).
+	DefaultCommitRetryTimeout = 120 * time.Second
+
+	// commitRetryMinDelay and commitRetryMaxDelay bound the exponential delay between the commit retries.
+	commitRetryMinDelay = 10 * time.Millisecond
+	commitRetryMaxDelay = time.Second
+)
+
+// IsTransientTransactionError implements [mtx.RetryClassifier].
+// It reports the errors with the TransientTransactionError label as retryable.
+func IsTransientTransactionError(err error) bool {
+	return hasErrorLabel(err, LabelTransientTransactionError)
+}
+
+// hasErrorLabel reports whether the error contains the label.
+func hasErrorLabel(err error, label string) bool {
+	var labeled mongo.LabeledError
+	return errors.As(err, &labeled) && labeled.HasErrorLabel(label)
+}
+
+// isUnknownCommitResult reports whether the commit can be retried after the error.
+func isUnknownCommitResult(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) &&
+		cmdErr.HasErrorLabel(LabelUnknownTransactionCommitResult) &&
+		!cmdErr.IsMaxTimeMSExpiredError()
+}
+
+// ClientWrapper wraps [mongo.Client] and implements [mtx.TxBeginner].
+type ClientWrapper struct {
+	*mongo.Client
+
+	sessionOpts        []options.Lister[options.SessionOptions]
+	transactionOpts    []options.Lister[options.TransactionOptions]
+	commitRetryTimeout time.Duration
+}
+
+// NewMongo returns [mongo.Client] wrapper.
+func NewMongo(client *mongo.Client) *ClientWrapper {
+	return &ClientWrapper{
+		Client:             client,
+		commitRetryTimeout: DefaultCommitRetryTimeout,
+	}
+}
+
+// WithSessionOptions returns a new [ClientWrapper] with the additional [options.SessionOptions].
+// The original ClientWrapper is not modified.
+func (c *ClientWrapper) WithSessionOptions(opts ...options.Lister[options.SessionOptions]) *ClientWrapper {
+	w := *c
+	w.sessionOpts = append(slices.Clip(c.sessionOpts), opts...)
+	return &w
+}
+
+// WithTransactionOptions returns a new [ClientWrapper] with the additional [options.TransactionOptions].
+// The original ClientWrapper is not modified.
+func (c *ClientWrapper) WithTransactionOptions(opts ...options.Lister[options.TransactionOptions]) *ClientWrapper {
+	w := *c
+	w.transactionOpts = append(slices.Clip(c.transactionOpts), opts...)
+	return &w
+}
+
+// WithCommitRetryTimeout returns a new [ClientWrapper] with the time limit of the retries of the commit
+// with the UnknownTransactionCommitResult label (DefaultCommitRetryTimeout by default).
+// A non-positive timeout disables the retries. The original ClientWrapper is not modified.
+func (c *ClientWrapper) WithCommitRetryTimeout(timeout time.Duration) *ClientWrapper {
+	w := *c
+	w.commitRetryTimeout = timeout
+	return &w
+}
+
+// BeginTx starts a session and a transaction within it.
+func (c *ClientWrapper) BeginTx(ctx context.Context) (*SessionWrapper, error) {
+	session, err := c.Client.StartSession(c.sessionOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start mongo session: %w", err)
+	}
+	if err = session.StartTransaction(c.transactionOpts...); err != nil {
+		session.EndSession(ctx)
+		return nil, fmt.Errorf("failed to start mongo transaction: %w", err)
+	}
+
+	return &SessionWrapper{
+		Session:            session,
+		commitRetryTimeout: c.commitRetryTimeout,
+	}, nil
+}
+
+// SessionWrapper wraps [mongo.Session] and implements [mtx.Tx].
+type SessionWrapper struct {
+	*mongo.Session
+
+	commitRetryTimeout time.Duration
+}
+
+// Rollback aborts the transaction and ends the session.
+func (t *SessionWrapper) Rollback(ctx context.Context) error {
+	defer t.Session.EndSession(ctx)
+	return t.Session.AbortTransaction(ctx)
+}
+
+// Commit commits the transaction and ends the session.
+// The commit with the UnknownTransactionCommitResult label is retried with an exponential delay
+// until the commit retry timeout (see ClientWrapper.WithCommitRetryTimeout) is exceeded or the context is done.
+func (t *SessionWrapper) Commit(ctx context.Context) error {
+	defer t.Session.EndSession(ctx)
+	return commitWithRetry(ctx, t.Session.CommitTransaction, t.commitRetryTimeout)
+}
+
+// commitWithRetry calls the commit function while it fails with an unknown commit result.
+// The delay between the calls doubles from commitRetryMinDelay up to commitRetryMaxDelay,
+// so the retries do not flood the cluster during a failover.
+func commitWithRetry(ctx context.Context, commit func(ctx context.Context) error, timeout time.Duration) error {
+	var (
+		deadline = time.Now().Add(timeout)
+		delay    = commitRetryMinDelay
+	)
+	for {
+		err := commit(ctx)
+		if err == nil || !isUnknownCommitResult(err) || ctx.Err() != nil {
+			return err
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 || !waitCommitRetryDelay(ctx, min(delay, remaining)) {
+			return err
+		}
+		delay = min(2*delay, commitRetryMaxDelay)
+	}
+}
+
+// waitCommitRetryDelay waits for the delay and reports "false" if the context is done before.
+func waitCommitRetryDelay(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Transactor manage a transaction for single [mongo.Client] instance.
+type Transactor struct {
+	*mtx.Transactor[*ClientWrapper, *SessionWrapper]
+}
+
+// NewTransactor returns new [Transactor].
+func NewTransactor(client *ClientWrapper) *Transactor {
+	var (
+		operator   = mtx.NewContextOperator[*ClientWrapper, *SessionWrapper](client)
+		transactor = Transactor{
+			Transactor: mtx.NewTransactor[*ClientWrapper, *SessionWrapper](client, operator),
+		}
+	)
+	return &transactor
+}
+
+// With returns a new [Transactor] with the configured [mtx.Transactor].
+func (t *Transactor) With(configure func(t *mtx.Transactor[*ClientWrapper, *SessionWrapper]) *mtx.Transactor[*ClientWrapper, *SessionWrapper]) *Transactor {
+	return &Transactor{
+		Transactor: configure(t.Transactor),
+	}
+}
+
+// WithTransientRetry returns a new [Transactor] that re-runs the whole top-level transaction
+// (with a new session) when it fails with the TransientTransactionError label (see mtx.Transactor.WithRetry).
+// The function can be executed several times, so it must be idempotent. The original Transactor is not modified.
+//
+// Example:
+//
+//	transactor = transactor.WithTransientRetry(saga.NewBaseRetryPolicy(3, 10*time.Millisecond))
+func (t *Transactor) WithTransientRetry(policy mtx.RetryPolicy) *Transactor {
+	return t.With(func(t *mtx.Transactor[*ClientWrapper, *SessionWrapper]) *mtx.Transactor[*ClientWrapper, *SessionWrapper] {
+		return t.WithRetry(policy, IsTransientTransactionError)
+	})
+}
+
+// WithinTx execute all queries with [mongo.Session].
+//
+// Creates new [mongo.Session] or reuse [mongo.Session] obtained from [context.Context].
+func (t *Transactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	return t.Transactor.WithinTx(ctx, fn)
+}
+
+// Session returns pointer of [mongo.Session] and "true" from [context.Context] or return `false`.
+func (t *Transactor) Session(ctx context.Context) (*mongo.Session, bool) {
+	tx, ok := t.Transactor.TryGetTx(ctx)
+	if !ok || tx == nil || tx.Session == nil {
+		return nil, false
+	}
+	return tx.Session, true
+}
+
+// GetExecutor returns the context bound to the [mongo.Session] from [context.Context]
+// (see [mongo.NewSessionContext]) or the context itself when there is no transaction,
+// so the collection calls with the returned context participate in the transaction.
+//
+// Example:
+//
+//	err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+//	    _, err := collection.InsertOne(transactor.GetExecutor(ctx), order)
+//	    return err
+//	})
+func (t *Transactor) GetExecutor(ctx context.Context) context.Context {
+	session, ok := t.Session(ctx)
+	if !ok {
+		return ctx
+	}
+	return mongo.NewSessionContext(ctx, session)
+}
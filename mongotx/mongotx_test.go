@@ -0,0 +1,211 @@
+package mongotx
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+	"github.com/kozmod/oniontx/mtx"
+)
+
+// newTestClient returns a client of an unreachable server:
+// the sessions and the transactions without operations do not require the server.
+func newTestClient(t *testing.T) *mongo.Client {
+	t.Helper()
+	client, err := mongo.Connect(options.Client().ApplyURI("mongodb://127.0.0.1:1").SetServerSelectionTimeout(time.Millisecond))
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.Disconnect(context.Background())
+	})
+	return client
+}
+
+func Test_Transactor(t *testing.T) {
+	t.Run("session_ended_after_commit", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			transactor = NewTransactor(NewMongo(newTestClient(t)))
+			session    *mongo.Session
+		)
+		err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+			var ok bool
+			session, ok = transactor.Session(ctx)
+			assert.True(t, ok)
+			assert.Equal(t, session, mongo.SessionFromContext(transactor.GetExecutor(ctx)))
+			return transactor.WithinTx(ctx, func(nestedCtx context.Context) error {
+				nested, _ := transactor.Session(nestedCtx)
+				assert.Equal(t, session, nested)
+				return nil
+			})
+		})
+		assert.NoError(t, err)
+		assert.True(t, session.ClientSession().Terminated)
+	})
+	t.Run("session_ended_after_rollback", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			transactor = NewTransactor(NewMongo(newTestClient(t)))
+			expErr     = fmt.Errorf("call error")
+			session    *mongo.Session
+		)
+		err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+			session, _ = transactor.Session(ctx)
+			return expErr
+		})
+		assert.ErrorIs(t, err, expErr)
+		assert.ErrorIs(t, err, mtx.ErrRollbackSuccess)
+		assert.True(t, session.ClientSession().Terminated)
+	})
+	t.Run("executor_without_tx", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			transactor = NewTransactor(NewMongo(newTestClient(t)))
+		)
+		_, ok := transactor.Session(ctx)
+		assert.False(t, ok)
+		assert.Equal(t, ctx, transactor.GetExecutor(ctx))
+	})
+	t.Run("transient_retry", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			transactor = NewTransactor(NewMongo(newTestClient(t))).WithTransientRetry(retryPolicy(2))
+			sessions   []*mongo.Session
+		)
+		err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+			session, _ := transactor.Session(ctx)
+			sessions = append(sessions, session)
+			if len(sessions) < 3 {
+				return mongo.CommandError{Labels: []string{LabelTransientTransactionError}}
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(sessions))
+		assert.True(t, sessions[0] != sessions[1])
+		for _, session := range sessions {
+			assert.True(t, session.ClientSession().Terminated)
+		}
+	})
+	t.Run("not_transient", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			transactor = NewTransactor(NewMongo(newTestClient(t))).WithTransientRetry(retryPolicy(2))
+			calls      int
+		)
+		err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+			calls++
+			return mongo.CommandError{Code: 11000}
+		})
+		assert.ErrorIs(t, err, mtx.ErrRollbackSuccess)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func Test_ClientWrapper_options(t *testing.T) {
+	var (
+		client = NewMongo(nil)
+		a      = client.WithTransactionOptions(options.Transaction())
+		b      = a.WithSessionOptions(options.Session()).WithCommitRetryTimeout(time.Second)
+	)
+	assert.Equal(t, 0, len(client.transactionOpts))
+	assert.Equal(t, 1, len(a.transactionOpts))
+	assert.Equal(t, 0, len(a.sessionOpts))
+	assert.Equal(t, 1, len(b.sessionOpts))
+	assert.Equal(t, DefaultCommitRetryTimeout, a.commitRetryTimeout)
+	assert.Equal(t, time.Second, b.commitRetryTimeout)
+}
+
+func Test_commitWithRetry(t *testing.T) {
+	var (
+		unknown = mongo.CommandError{Labels: []string{LabelUnknownTransactionCommitResult}}
+		commit  = func(errs ...error) (func(context.Context) error, *int) {
+			var calls int
+			return func(context.Context) error {
+				calls++
+				if calls > len(errs) {
+					return nil
+				}
+				return errs[calls-1]
+			}, &calls
+		}
+	)
+	t.Run("unknown_result", func(t *testing.T) {
+		fn, calls := commit(unknown, fmt.Errorf("wrapped: %w", unknown))
+		assert.NoError(t, commitWithRetry(context.Background(), fn, time.Minute))
+		assert.Equal(t, 3, *calls)
+	})
+	t.Run("backoff", func(t *testing.T) {
+		var (
+			fn, calls = commit(unknown, unknown, unknown)
+			start     = time.Now()
+		)
+		assert.NoError(t, commitWithRetry(context.Background(), fn, time.Minute))
+		assert.Equal(t, 4, *calls)
+		// the delays: 10ms, 20ms, 40ms.
+		assert.True(t, time.Since(start) >= 7*commitRetryMinDelay)
+	})
+	t.Run("backoff_limited_by_timeout", func(t *testing.T) {
+		var (
+			fn, calls = commit(unknown, unknown, unknown, unknown, unknown, unknown)
+			timeout   = commitRetryMinDelay + commitRetryMinDelay/2
+		)
+		assert.True(t, isUnknownCommitResult(commitWithRetry(context.Background(), fn, timeout)))
+		assert.True(t, *calls <= 3)
+	})
+	t.Run("context_done_during_backoff", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), commitRetryMinDelay/2)
+		defer cancel()
+		fn, calls := commit(unknown, unknown)
+		assert.True(t, isUnknownCommitResult(commitWithRetry(ctx, fn, time.Minute)))
+		assert.Equal(t, 1, *calls)
+	})
+	t.Run("other_error", func(t *testing.T) {
+		expErr := mongo.CommandError{Labels: []string{LabelTransientTransactionError}}
+		fn, calls := commit(expErr)
+		assert.True(t, IsTransientTransactionError(commitWithRetry(context.Background(), fn, time.Minute)))
+		assert.Equal(t, 1, *calls)
+	})
+	t.Run("max_time_expired", func(t *testing.T) {
+		expErr := mongo.CommandError{Code: 50, Labels: []string{LabelUnknownTransactionCommitResult}}
+		fn, calls := commit(expErr)
+		assert.True(t, hasErrorLabel(commitWithRetry(context.Background(), fn, time.Minute), LabelUnknownTransactionCommitResult))
+		assert.Equal(t, 1, *calls)
+	})
+	t.Run("timeout", func(t *testing.T) {
+		fn, calls := commit(unknown, unknown)
+		assert.True(t, isUnknownCommitResult(commitWithRetry(context.Background(), fn, 0)))
+		assert.Equal(t, 1, *calls)
+	})
+	t.Run("context_done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		fn, calls := commit(unknown, unknown)
+		assert.True(t, isUnknownCommitResult(commitWithRetry(ctx, fn, time.Minute)))
+		assert.Equal(t, 1, *calls)
+	})
+}
+
+func Test_IsTransientTransactionError(t *testing.T) {
+	assert.True(t, IsTransientTransactionError(mongo.CommandError{Labels: []string{LabelTransientTransactionError}}))
+	assert.True(t, IsTransientTransactionError(fmt.Errorf("wrapped: %w", mongo.WriteException{
+		Labels: []string{LabelTransientTransactionError},
+	})))
+	assert.False(t, IsTransientTransactionError(mongo.CommandError{Labels: []string{LabelUnknownTransactionCommitResult}}))
+	assert.False(t, IsTransientTransactionError(fmt.Errorf("error")))
+}
+
+// retryPolicy retries without a delay.
+type retryPolicy uint32
+
+func (p retryPolicy) Attempts() uint32 {
+	return uint32(p)
+}
+
+func (retryPolicy) Delay(uint32) time.Duration {
+	return 0
+}
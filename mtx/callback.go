@@ -0,0 +1,199 @@
+package mtx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/kozmod/oniontx/internal/errors"
+)
+
+var (
+	// ErrNilCallback indicates that a nil callback was registered.
+	ErrNilCallback = fmt.Errorf("callback is nil")
+
+	// ErrBeforeCommitFailed indicates that a before-commit hook returned an error or panicked,
+	// so the transaction was rolled back instead of being committed.
+	ErrBeforeCommitFailed = fmt.Errorf("before commit hook failed")
+
+	// ErrAfterCommitFailed indicates that at least one after-commit callback
+	// returned an error or panicked. The transaction itself was committed.
+	ErrAfterCommitFailed = fmt.Errorf("after commit callback failed")
+
+	// ErrAfterRollbackFailed indicates that at least one after-rollback callback
+	// returned an error or panicked.
+	ErrAfterRollbackFailed = fmt.Errorf("after rollback callback failed")
+)
+
+// HookOrder defines the order in which before-commit hooks are called.
+type HookOrder uint8
+
+const (
+	// HookOrderFIFO calls the hooks in registration order (default).
+	HookOrderFIFO HookOrder = iota
+	// HookOrderLIFO calls the hooks in reverse registration order.
+	HookOrderLIFO
+)
+
+// BeforeCommit registers a hook which is called just before the top-level transaction
+// from the context is committed. The hook can be registered from any (top-level or nested) WithinTx call.
+//
+// Hooks are called within the transaction (the context contains the transaction),
+// so they can flush buffered writes (unit of work, outbox rows) or check invariants at the last moment.
+// The order of the hooks is defined by the top-level Transactor (see Transactor.WithBeforeCommitOrder).
+// Hooks registered by other hooks are called after the current ones.
+//
+// If a hook returns an error or panics, the remaining hooks are not called,
+// the transaction is rolled back and the top-level WithinTx returns an error wrapped with ErrBeforeCommitFailed.
+// If the transaction implements SavepointTx, the hooks registered within a nested call
+// are discarded when the nested call is rolled back to its savepoint.
+//
+// BeforeCommit returns ErrTxNotFound if the context does not contain a transaction managed by a Transactor.
+//
+// Example:
+//
+//	err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+//	    uow.Add(order)
+//	    return mtx.BeforeCommit(ctx, uow.Flush)
+//	})
+func BeforeCommit(ctx context.Context, fn func(ctx context.Context) error) error {
+	if fn == nil {
+		return fmt.Errorf("before commit: %w", ErrNilCallback)
+	}
+	state, ok := extractCurrentState(ctx)
+	if !ok {
+		return fmt.Errorf("before commit: %w", ErrTxNotFound)
+	}
+	state.addBeforeCommit(fn)
+	return nil
+}
+
+// AfterCommit registers a callback which is called after the top-level transaction
+// from the context is successfully committed.
+// The callback can be registered from any (top-level or nested) WithinTx call.
+//
+// Callbacks are called in registration order with the context passed to the top-level
+// WithinTx call (the context does not contain the finished transaction).
+// Errors and panics of the callbacks are collected and returned by the top-level WithinTx
+// wrapped with ErrAfterCommitFailed, which does not mean that the commit failed.
+// If the transaction implements SavepointTx, the callbacks registered within a nested call
+// are discarded when the nested call is rolled back to its savepoint.
+//
+// AfterCommit returns ErrTxNotFound if the context does not contain a transaction managed by a Transactor.
+//
+// Example:
+//
+//	err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+//	    if err := repo.Update(ctx, user); err != nil {
+//	        return err
+//	    }
+//	    return mtx.AfterCommit(ctx, func(ctx context.Context) error {
+//	        return cache.Invalidate(ctx, user.ID)
+//	    })
+//	})
+func AfterCommit(ctx context.Context, fn func(ctx context.Context) error) error {
+	if fn == nil {
+		return fmt.Errorf("after commit: %w", ErrNilCallback)
+	}
+	state, ok := extractCurrentState(ctx)
+	if !ok {
+		return fmt.Errorf("after commit: %w", ErrTxNotFound)
+	}
+	state.addAfterCommit(fn)
+	return nil
+}
+
+// AfterRollback registers a callback which is called after the top-level transaction
+// from the context is rolled back or its commit fails.
+// The callback can be registered from any (top-level or nested) WithinTx call.
+//
+// Callbacks are called in registration order with the rollback context
+// (see Transactor.WithRollbackCtxFactory). Errors and panics of the callbacks are collected
+// and returned by the top-level WithinTx wrapped with ErrAfterRollbackFailed.
+// If the transaction implements SavepointTx, the callbacks registered within a nested call
+// are discarded when the nested call is rolled back to its savepoint.
+//
+// AfterRollback returns ErrTxNotFound if the context does not contain a transaction managed by a Transactor.
+func AfterRollback(ctx context.Context, fn func(ctx context.Context) error) error {
+	if fn == nil {
+		return fmt.Errorf("after rollback: %w", ErrNilCallback)
+	}
+	state, ok := extractCurrentState(ctx)
+	if !ok {
+		return fmt.Errorf("after rollback: %w", ErrTxNotFound)
+	}
+	state.addAfterRollback(fn)
+	return nil
+}
+
+// runCallbacks calls all callbacks and returns the collected errors.
+// Panics are recovered and converted to errors with ErrPanicRecovered.
+func runCallbacks(ctx context.Context, fns []func(ctx context.Context) error) []error {
+	var errs []error
+	for i, fn := range fns {
+		if err := runCallback(ctx, fn); err != nil {
+			errs = append(errs, fmt.Errorf("callback [%d]: %w", i, err))
+		}
+	}
+	return errs
+}
+
+func runCallback(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.Join(ErrPanicRecovered, errors.WrapPanic(p))
+		}
+	}()
+	err = fn(ctx)
+	return err
+}
+
+// runBeforeCommit calls the before-commit hooks of the state until the first error.
+// The hooks registered by other hooks are called after the current ones.
+// If the transaction is marked as rollback-only (see SetRollbackOnly),
+// the remaining hooks are not called and ErrRollbackOnly is returned.
+func runBeforeCommit(ctx context.Context, state *txState, order HookOrder) error {
+	for {
+		if state.isRollbackOnly() {
+			return ErrRollbackOnly
+		}
+		fns := state.takeBeforeCommit()
+		if len(fns) == 0 {
+			return nil
+		}
+		if order == HookOrderLIFO {
+			slices.Reverse(fns)
+		}
+		for i, fn := range fns {
+			if state.isRollbackOnly() {
+				return ErrRollbackOnly
+			}
+			if err := runCallback(ctx, fn); err != nil {
+				return fmt.Errorf("before commit: %w", errors.Join(ErrBeforeCommitFailed, fmt.Errorf("hook [%d]: %w", i, err)))
+			}
+		}
+	}
+}
+
+// runAfterCommit calls the after-commit callbacks of the state.
+func runAfterCommit(ctx context.Context, state *txState) error {
+	errs := runCallbacks(ctx, state.takeAfterCommit())
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("transactor - after commit: %w", errors.Join(append([]error{ErrAfterCommitFailed}, errs...)...))
+}
+
+// runAfterRollback calls the after-rollback callbacks of the state and joins their errors to err.
+// The callbacks are called with the rollback context created by the factory (see Transactor.WithRollbackCtxFactory).
+func runAfterRollback(ctx context.Context, state *txState, err error, rollbackCtxFactory func(ctx context.Context) context.Context) error {
+	fns := state.takeAfterRollback()
+	if len(fns) == 0 {
+		return err
+	}
+	errs := runCallbacks(rollbackCtxFactory(ctx), fns)
+	if len(errs) == 0 {
+		return err
+	}
+	return errors.Join(append([]error{err, ErrAfterRollbackFailed}, errs...)...)
+}
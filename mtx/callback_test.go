@@ -0,0 +1,543 @@
+package mtx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+)
+
+func Test_Callbacks(t *testing.T) {
+	type calls struct {
+		commit, rollback int
+		commitErr        error
+	}
+	newInstance := func(c *calls) (
+		*ContextOperator[*beginnerMock[*committerMock], *committerMock],
+		*Transactor[*beginnerMock[*committerMock], *committerMock]) {
+		var (
+			b = &beginnerMock[*committerMock]{
+				beginFn: func(context.Context) (*committerMock, error) {
+					return &committerMock{
+						commitFn: func(context.Context) error {
+							c.commit++
+							return c.commitErr
+						},
+						rollbackFn: func(context.Context) error {
+							c.rollback++
+							return nil
+						},
+					}, nil
+				},
+			}
+			o = NewContextOperator[*beginnerMock[*committerMock], *committerMock](b)
+		)
+		return o, NewTransactor[*beginnerMock[*committerMock], *committerMock](b, o)
+	}
+
+	t.Run("after_commit", func(t *testing.T) {
+		var (
+			c      calls
+			ctx    = context.Background()
+			o, tr  = newInstance(&c)
+			called []string
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			err := AfterCommit(ctx, func(ctx context.Context) error {
+				assert.Equal(t, 1, c.commit)
+				_, ok := o.Extract(ctx)
+				assert.False(t, ok)
+				called = append(called, "top")
+				return nil
+			})
+			assert.NoError(t, err)
+			err = AfterRollback(ctx, func(context.Context) error {
+				t.Fatalf("should not have been called")
+				return nil
+			})
+			assert.NoError(t, err)
+
+			return tr.WithinTx(ctx, func(ctx context.Context) error {
+				return AfterCommit(ctx, func(context.Context) error {
+					called = append(called, "nested")
+					return nil
+				})
+			})
+		})
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal([]string{"top", "nested"}, called))
+	})
+	t.Run("after_rollback", func(t *testing.T) {
+		var (
+			c      calls
+			ctx    = context.Background()
+			_, tr  = newInstance(&c)
+			expErr = fmt.Errorf("some error")
+			called int
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			err := AfterCommit(ctx, func(context.Context) error {
+				t.Fatalf("should not have been called")
+				return nil
+			})
+			assert.NoError(t, err)
+			err = AfterRollback(ctx, func(context.Context) error {
+				assert.Equal(t, 1, c.rollback)
+				called++
+				return nil
+			})
+			assert.NoError(t, err)
+			return expErr
+		})
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.ErrorIs(t, err, expErr)
+		assert.ErrorIsNot(t, err, ErrAfterRollbackFailed)
+		assert.Equal(t, 1, called)
+	})
+	t.Run("after_rollback_on_panic_and_failed_commit", func(t *testing.T) {
+		var (
+			c      calls
+			ctx    = context.Background()
+			_, tr  = newInstance(&c)
+			called int
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			_ = AfterRollback(ctx, func(context.Context) error {
+				called++
+				return nil
+			})
+			panic("some panic")
+		})
+		assert.ErrorIs(t, err, ErrPanicRecovered)
+		assert.Equal(t, 1, called)
+
+		c.commitErr = fmt.Errorf("commit error")
+		err = tr.WithinTx(ctx, func(ctx context.Context) error {
+			return AfterRollback(ctx, func(context.Context) error {
+				called++
+				return nil
+			})
+		})
+		assert.ErrorIs(t, err, ErrCommitFailed)
+		assert.Equal(t, 2, called)
+	})
+	t.Run("errors_and_panics_are_collected", func(t *testing.T) {
+		const (
+			expPanicMsg = "callback_panic"
+		)
+		var (
+			c      calls
+			ctx    = context.Background()
+			_, tr  = newInstance(&c)
+			expErr = fmt.Errorf("callback error")
+			called int
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			_ = AfterCommit(ctx, func(context.Context) error {
+				called++
+				return expErr
+			})
+			_ = AfterCommit(ctx, func(context.Context) error {
+				called++
+				panic(expPanicMsg)
+			})
+			_ = AfterCommit(ctx, func(context.Context) error {
+				called++
+				return nil
+			})
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrAfterCommitFailed)
+		assert.ErrorIs(t, err, expErr)
+		assert.ErrorIs(t, err, ErrPanicRecovered)
+		assert.ErrorIsNot(t, err, ErrCommitFailed)
+		assert.True(t, strings.Contains(err.Error(), expPanicMsg))
+		assert.Equal(t, 3, called)
+		assert.Equal(t, 1, c.commit)
+
+		rbErr := fmt.Errorf("rollback error")
+		err = tr.WithinTx(ctx, func(ctx context.Context) error {
+			_ = AfterRollback(ctx, func(context.Context) error {
+				return expErr
+			})
+			return rbErr
+		})
+		assert.ErrorIs(t, err, ErrAfterRollbackFailed)
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.ErrorIs(t, err, expErr)
+		assert.ErrorIs(t, err, rbErr)
+	})
+	t.Run("requires_new_has_own_callbacks", func(t *testing.T) {
+		var (
+			c      calls
+			ctx    = context.Background()
+			_, tr  = newInstance(&c)
+			called []string
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			_ = AfterCommit(ctx, func(context.Context) error {
+				called = append(called, "outer")
+				return nil
+			})
+			err := tr.WithPropagation(PropagationRequiresNew).WithinTx(ctx, func(ctx context.Context) error {
+				return AfterCommit(ctx, func(context.Context) error {
+					called = append(called, "inner")
+					return nil
+				})
+			})
+			assert.NoError(t, err)
+			assert.True(t, slices.Equal([]string{"inner"}, called))
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal([]string{"inner", "outer"}, called))
+	})
+	t.Run("savepoint_rollback_discards_after_commit", func(t *testing.T) {
+		var (
+			ctx    = context.Background()
+			called []string
+			tx     = &savepointMock{
+				committerMock: committerMock{
+					commitFn: func(context.Context) error {
+						return nil
+					},
+				},
+				savepointFn: func(context.Context, string) error {
+					return nil
+				},
+				rollbackToFn: func(context.Context, string) error {
+					return nil
+				},
+				releaseFn: func(context.Context, string) error {
+					return nil
+				},
+			}
+			b = &beginnerMock[*savepointMock]{
+				beginFn: func(context.Context) (*savepointMock, error) {
+					return tx, nil
+				},
+			}
+			o  = NewContextOperator[*beginnerMock[*savepointMock], *savepointMock](b)
+			tr = NewTransactor[*beginnerMock[*savepointMock], *savepointMock](b, o)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			_ = AfterCommit(ctx, func(context.Context) error {
+				called = append(called, "top")
+				return nil
+			})
+			err := tr.WithinTx(ctx, func(ctx context.Context) error {
+				_ = AfterCommit(ctx, func(context.Context) error {
+					called = append(called, "released")
+					return nil
+				})
+				return nil
+			})
+			assert.NoError(t, err)
+			err = tr.WithinTx(ctx, func(ctx context.Context) error {
+				_ = AfterCommit(ctx, func(context.Context) error {
+					called = append(called, "rolled_back")
+					return nil
+				})
+				return fmt.Errorf("nested error")
+			})
+			assert.ErrorIs(t, err, ErrRollbackToSavepointSuccess)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal([]string{"top", "released"}, called))
+	})
+	t.Run("savepoint_rollback_discards_after_rollback", func(t *testing.T) {
+		var (
+			ctx    = context.Background()
+			expErr = fmt.Errorf("outer error")
+			called []string
+			tx     = &savepointMock{
+				committerMock: committerMock{
+					rollbackFn: func(context.Context) error {
+						return nil
+					},
+				},
+				savepointFn: func(context.Context, string) error {
+					return nil
+				},
+				rollbackToFn: func(context.Context, string) error {
+					return nil
+				},
+				releaseFn: func(context.Context, string) error {
+					return nil
+				},
+			}
+			b = &beginnerMock[*savepointMock]{
+				beginFn: func(context.Context) (*savepointMock, error) {
+					return tx, nil
+				},
+			}
+			o  = NewContextOperator[*beginnerMock[*savepointMock], *savepointMock](b)
+			tr = NewTransactor[*beginnerMock[*savepointMock], *savepointMock](b, o)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			_ = AfterRollback(ctx, func(context.Context) error {
+				called = append(called, "top")
+				return nil
+			})
+			err := tr.WithinTx(ctx, func(ctx context.Context) error {
+				_ = AfterRollback(ctx, func(context.Context) error {
+					called = append(called, "released")
+					return nil
+				})
+				return nil
+			})
+			assert.NoError(t, err)
+			err = tr.WithinTx(ctx, func(ctx context.Context) error {
+				_ = AfterRollback(ctx, func(context.Context) error {
+					called = append(called, "rolled_back")
+					return nil
+				})
+				return fmt.Errorf("nested error")
+			})
+			assert.ErrorIs(t, err, ErrRollbackToSavepointSuccess)
+			return expErr
+		})
+		assert.ErrorIs(t, err, expErr)
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.True(t, slices.Equal([]string{"top", "released"}, called))
+	})
+	t.Run("error_without_tx", func(t *testing.T) {
+		var (
+			c     calls
+			ctx   = context.Background()
+			o, tr = newInstance(&c)
+			fn    = func(context.Context) error {
+				return nil
+			}
+		)
+		assert.ErrorIs(t, AfterCommit(ctx, fn), ErrTxNotFound)
+		assert.ErrorIs(t, AfterRollback(ctx, fn), ErrTxNotFound)
+		assert.ErrorIs(t, BeforeCommit(ctx, fn), ErrTxNotFound)
+
+		// transaction is not managed by the Transactor
+		err := tr.WithinTx(o.Inject(ctx, &committerMock{}), func(ctx context.Context) error {
+			return AfterCommit(ctx, fn)
+		})
+		assert.ErrorIs(t, err, ErrTxNotFound)
+
+		err = tr.WithinTx(ctx, func(ctx context.Context) error {
+			return tr.WithPropagation(PropagationNotSupported).WithinTx(ctx, func(ctx context.Context) error {
+				return AfterCommit(ctx, fn)
+			})
+		})
+		assert.ErrorIs(t, err, ErrTxNotFound)
+	})
+	t.Run("error_nil_callback", func(t *testing.T) {
+		var (
+			c     calls
+			ctx   = context.Background()
+			_, tr = newInstance(&c)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			assert.ErrorIs(t, AfterCommit(ctx, nil), ErrNilCallback)
+			assert.ErrorIs(t, AfterRollback(ctx, nil), ErrNilCallback)
+			assert.ErrorIs(t, BeforeCommit(ctx, nil), ErrNilCallback)
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+}
+
+func Test_BeforeCommit(t *testing.T) {
+	type calls struct {
+		commit, rollback int
+	}
+	newInstance := func(c *calls) (
+		*ContextOperator[*beginnerMock[*committerMock], *committerMock],
+		*Transactor[*beginnerMock[*committerMock], *committerMock]) {
+		var (
+			b = &beginnerMock[*committerMock]{
+				beginFn: func(context.Context) (*committerMock, error) {
+					return &committerMock{
+						commitFn: func(context.Context) error {
+							c.commit++
+							return nil
+						},
+						rollbackFn: func(context.Context) error {
+							c.rollback++
+							return nil
+						},
+					}, nil
+				},
+			}
+			o = NewContextOperator[*beginnerMock[*committerMock], *committerMock](b)
+		)
+		return o, NewTransactor[*beginnerMock[*committerMock], *committerMock](b, o)
+	}
+
+	t.Run("fifo_within_tx", func(t *testing.T) {
+		var (
+			c      calls
+			ctx    = context.Background()
+			o, tr  = newInstance(&c)
+			called []string
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			for _, name := range []string{"first", "second"} {
+				err := BeforeCommit(ctx, func(ctx context.Context) error {
+					assert.Equal(t, 0, c.commit)
+					_, ok := o.Extract(ctx)
+					assert.True(t, ok)
+					called = append(called, name)
+					return nil
+				})
+				assert.NoError(t, err)
+			}
+			return tr.WithinTx(ctx, func(ctx context.Context) error {
+				return BeforeCommit(ctx, func(ctx context.Context) error {
+					called = append(called, "nested")
+					// hook registered by the hook is called after the current ones
+					return BeforeCommit(ctx, func(context.Context) error {
+						called = append(called, "registered_by_hook")
+						return nil
+					})
+				})
+			})
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, c.commit)
+		assert.Equal(t, 0, c.rollback)
+		assert.True(t, slices.Equal([]string{"first", "second", "nested", "registered_by_hook"}, called))
+	})
+	t.Run("lifo", func(t *testing.T) {
+		var (
+			c      calls
+			ctx    = context.Background()
+			_, tr  = newInstance(&c)
+			called []string
+		)
+		err := tr.WithBeforeCommitOrder(HookOrderLIFO).WithinTx(ctx, func(ctx context.Context) error {
+			for _, name := range []string{"first", "second", "third"} {
+				_ = BeforeCommit(ctx, func(context.Context) error {
+					called = append(called, name)
+					return nil
+				})
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, c.commit)
+		assert.True(t, slices.Equal([]string{"third", "second", "first"}, called))
+	})
+	t.Run("veto_rolls_back", func(t *testing.T) {
+		var (
+			c        calls
+			ctx      = context.Background()
+			_, tr    = newInstance(&c)
+			expErr   = fmt.Errorf("invariant violated")
+			called   []string
+			rollback int
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			_ = BeforeCommit(ctx, func(context.Context) error {
+				called = append(called, "veto")
+				return expErr
+			})
+			_ = BeforeCommit(ctx, func(context.Context) error {
+				t.Fatalf("should not have been called")
+				return nil
+			})
+			_ = AfterCommit(ctx, func(context.Context) error {
+				t.Fatalf("should not have been called")
+				return nil
+			})
+			return AfterRollback(ctx, func(context.Context) error {
+				rollback++
+				return nil
+			})
+		})
+		assert.ErrorIs(t, err, ErrBeforeCommitFailed)
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.ErrorIs(t, err, expErr)
+		assert.Equal(t, 0, c.commit)
+		assert.Equal(t, 1, c.rollback)
+		assert.Equal(t, 1, rollback)
+		assert.True(t, slices.Equal([]string{"veto"}, called))
+	})
+	t.Run("panic_rolls_back", func(t *testing.T) {
+		var (
+			c     calls
+			ctx   = context.Background()
+			_, tr = newInstance(&c)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			return BeforeCommit(ctx, func(context.Context) error {
+				panic("hook panic")
+			})
+		})
+		assert.ErrorIs(t, err, ErrBeforeCommitFailed)
+		assert.ErrorIs(t, err, ErrPanicRecovered)
+		assert.Equal(t, 0, c.commit)
+		assert.Equal(t, 1, c.rollback)
+	})
+	t.Run("not_called_on_error", func(t *testing.T) {
+		var (
+			c      calls
+			ctx    = context.Background()
+			_, tr  = newInstance(&c)
+			expErr = fmt.Errorf("fn error")
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			_ = BeforeCommit(ctx, func(context.Context) error {
+				t.Fatalf("should not have been called")
+				return nil
+			})
+			return expErr
+		})
+		assert.ErrorIs(t, err, expErr)
+		assert.Equal(t, 1, c.rollback)
+	})
+	t.Run("savepoint_rollback_discards_hooks", func(t *testing.T) {
+		var (
+			ctx    = context.Background()
+			called []string
+			tx     = &savepointMock{
+				committerMock: committerMock{
+					commitFn: func(context.Context) error {
+						return nil
+					},
+				},
+				savepointFn: func(context.Context, string) error {
+					return nil
+				},
+				rollbackToFn: func(context.Context, string) error {
+					return nil
+				},
+				releaseFn: func(context.Context, string) error {
+					return nil
+				},
+			}
+			b = &beginnerMock[*savepointMock]{
+				beginFn: func(context.Context) (*savepointMock, error) {
+					return tx, nil
+				},
+			}
+			o  = NewContextOperator[*beginnerMock[*savepointMock], *savepointMock](b)
+			tr = NewTransactor[*beginnerMock[*savepointMock], *savepointMock](b, o)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			_ = tr.WithinTx(ctx, func(ctx context.Context) error {
+				_ = BeforeCommit(ctx, func(context.Context) error {
+					called = append(called, "rolled_back")
+					return nil
+				})
+				return fmt.Errorf("nested error")
+			})
+			return BeforeCommit(ctx, func(context.Context) error {
+				called = append(called, "top")
+				return nil
+			})
+		})
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal([]string{"top"}, called))
+	})
+}
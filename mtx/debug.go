@@ -0,0 +1,242 @@
+package mtx
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrTxConcurrentUse indicates that a transaction was used by a goroutine
+	// while another goroutine was using it (see Transactor.WithDebug).
+	ErrTxConcurrentUse = fmt.Errorf("tx used concurrently")
+
+	// ErrTxNonOwnerUse indicates that a transaction was used by a goroutine
+	// other than the goroutine which executes its WithinTx (see Transactor.WithStrictDebug).
+	ErrTxNonOwnerUse = fmt.Errorf("tx used by non-owner goroutine")
+
+	// ErrTxUseAfterDone indicates that a transaction was used after its WithinTx had returned
+	// (see Transactor.WithDebug).
+	ErrTxUseAfterDone = fmt.Errorf("tx used after completion")
+)
+
+// TxMisuseError describes a misuse of a transaction detected in the debug mode (see Transactor.WithDebug).
+type TxMisuseError struct {
+	// Err is ErrTxConcurrentUse, ErrTxNonOwnerUse or ErrTxUseAfterDone.
+	Err error
+	// TxID is the identifier of the transaction (see TxEvent.TxID).
+	TxID uint64
+	// Goroutine is the identifier of the goroutine which misused the transaction.
+	Goroutine uint64
+	// Stack is the stack trace of the misuse.
+	Stack string
+	// OtherGoroutine is the identifier of the other goroutine of the misuse:
+	// the goroutine of the overlapping use for ErrTxConcurrentUse, the owner goroutine for ErrTxNonOwnerUse
+	// and the goroutine which completed the transaction for ErrTxUseAfterDone.
+	OtherGoroutine uint64
+	// OtherStack is the stack trace of the overlapping use for ErrTxConcurrentUse,
+	// of the last use by the owner goroutine (or of the beginning) for ErrTxNonOwnerUse
+	// and of the completion for ErrTxUseAfterDone.
+	OtherStack string
+}
+
+// Error returns the error message with both stack traces.
+func (e *TxMisuseError) Error() string {
+	return fmt.Sprintf(
+		"transactor - tx [%d]: %v: goroutine [%d] at:\n%s\nother goroutine [%d] at:\n%s",
+		e.TxID, e.Err, e.Goroutine, e.Stack, e.OtherGoroutine, e.OtherStack,
+	)
+}
+
+// Unwrap returns ErrTxConcurrentUse, ErrTxNonOwnerUse or ErrTxUseAfterDone.
+func (e *TxMisuseError) Unwrap() error {
+	return e.Err
+}
+
+// TxMisuseHandler handles the misuse of a transaction detected in the debug mode (see Transactor.WithDebug).
+type TxMisuseHandler func(ctx context.Context, err *TxMisuseError)
+
+// WithDebug returns a new Transactor that detects the misuse of its transactions.
+// The original Transactor is not modified. The debug mode is expensive (every use captures the stack),
+// so it is intended for tests and local development.
+//
+// A nested WithinTx call uses the transaction from its beginning until it returns,
+// and TryGetTx uses the transaction at the moment of the extraction. The use of a transaction
+//   - while a nested WithinTx call of another goroutine is in flight is reported with ErrTxConcurrentUse,
+//   - after its WithinTx has returned is reported with ErrTxUseAfterDone.
+//
+// Only the overlapping uses are reported, so the goroutines can use the transaction one after another
+// (for example, while the owner goroutine is blocked in errgroup.Group.Wait).
+// The extractions by TryGetTx do not overlap with each other: the operations of the goroutines
+// must be executed within nested WithinTx calls to be tracked (see WithStrictDebug otherwise).
+//
+// The misuse is passed to the handler; a nil handler panics with *TxMisuseError.
+// The transactions begun by the Transactor are checked by every Transactor with the same TxBeginner,
+// but repositories must extract the transaction by Transactor.TryGetTx: CtxOperator.Extract is not checked.
+//
+// Example:
+//
+//	transactor = transactor.WithDebug(func(ctx context.Context, err *mtx.TxMisuseError) {
+//	    log.Print(err) // contains the stack traces of both goroutines
+//	})
+func (t *Transactor[B, T]) WithDebug(handler TxMisuseHandler) *Transactor[B, T] {
+	c := *t
+	c.debug = true
+	c.strictDebug = false
+	c.misuseHandler = handler
+	return &c
+}
+
+// WithStrictDebug returns a new Transactor with the debug mode (see WithDebug) that also reports
+// every use of a transaction by a goroutine other than the goroutine which executes the top-level WithinTx
+// with ErrTxNonOwnerUse, even if the uses do not overlap.
+// The original Transactor is not modified.
+func (t *Transactor[B, T]) WithStrictDebug(handler TxMisuseHandler) *Transactor[B, T] {
+	c := t.WithDebug(handler)
+	c.strictDebug = true
+	return c
+}
+
+// enterUse starts the use of the transaction from the context if it was begun in the debug mode
+// and returns the function which ends the use.
+func (t *Transactor[B, T]) enterUse(ctx context.Context) func() {
+	state, ok := extractState(ctx, t.beginner)
+	if !ok || state.guard == nil {
+		return func() {}
+	}
+	var (
+		id    = goroutineID()
+		err   = state.guard.enter(state.id, id)
+		leave = func() {
+			state.guard.leave(id)
+		}
+	)
+	if err == nil {
+		return leave
+	}
+	if state.guard.handler == nil {
+		leave()
+		panic(err)
+	}
+	state.guard.handler(ctx, err)
+	return leave
+}
+
+// txUse is an in-flight use of a transaction by a goroutine.
+type txUse struct {
+	goroutine uint64
+	// depth is the number of the nested uses by the goroutine.
+	depth   int
+	callers []uintptr
+}
+
+// txGuard tracks the in-flight uses and the completion of a transaction in the debug mode.
+type txGuard struct {
+	mu            sync.Mutex
+	handler       TxMisuseHandler
+	strict        bool
+	owner         uint64
+	ownerCallers  []uintptr
+	uses          []txUse
+	done          bool
+	doneGoroutine uint64
+	doneCallers   []uintptr
+}
+
+// newTxGuard creates a txGuard owned by the current goroutine.
+func newTxGuard(handler TxMisuseHandler, strict bool) *txGuard {
+	return &txGuard{
+		handler:      handler,
+		strict:       strict,
+		owner:        goroutineID(),
+		ownerCallers: captureCallers(1),
+	}
+}
+
+// enter starts the use of the transaction by the goroutine and returns the misuse (if any).
+// The use is started even if it is a misuse, so every enter must be followed by leave.
+func (g *txGuard) enter(txID, id uint64) *TxMisuseError {
+	callers := captureCallers(1)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var misuse *TxMisuseError
+	switch {
+	case g.done:
+		misuse = g.misuse(ErrTxUseAfterDone, txID, id, callers, g.doneGoroutine, g.doneCallers)
+	case g.strict && id != g.owner:
+		misuse = g.misuse(ErrTxNonOwnerUse, txID, id, callers, g.owner, g.ownerCallers)
+	default:
+		for _, use := range g.uses {
+			if use.goroutine != id {
+				misuse = g.misuse(ErrTxConcurrentUse, txID, id, callers, use.goroutine, use.callers)
+				break
+			}
+		}
+	}
+	if id == g.owner {
+		g.ownerCallers = callers
+	}
+
+	for i := range g.uses {
+		if g.uses[i].goroutine == id {
+			g.uses[i].depth++
+			return misuse
+		}
+	}
+	g.uses = append(g.uses, txUse{goroutine: id, depth: 1, callers: callers})
+	return misuse
+}
+
+// leave ends the use of the transaction by the goroutine.
+func (g *txGuard) leave(id uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i := range g.uses {
+		if g.uses[i].goroutine != id {
+			continue
+		}
+		if g.uses[i].depth--; g.uses[i].depth == 0 {
+			g.uses = slices.Delete(g.uses, i, i+1)
+		}
+		return
+	}
+}
+
+// complete marks the transaction as completed.
+func (g *txGuard) complete() {
+	var (
+		id      = goroutineID()
+		callers = captureCallers(1)
+	)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.done = true
+	g.doneGoroutine = id
+	g.doneCallers = callers
+}
+
+func (g *txGuard) misuse(err error, txID, id uint64, callers []uintptr, otherID uint64, otherCallers []uintptr) *TxMisuseError {
+	return &TxMisuseError{
+		Err:            err,
+		TxID:           txID,
+		Goroutine:      id,
+		Stack:          formatCallers(callers),
+		OtherGoroutine: otherID,
+		OtherStack:     formatCallers(otherCallers),
+	}
+}
+
+// goroutineID returns the identifier of the current goroutine parsed from its stack trace.
+func goroutineID() uint64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	// the stack starts with "goroutine 42 [running]:".
+	id, _, _ := strings.Cut(strings.TrimPrefix(string(buf[:n]), "goroutine "), " ")
+	v, _ := strconv.ParseUint(id, 10, 64)
+	return v
+}
@@ -0,0 +1,198 @@
+package mtx
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+)
+
+func Test_Transactor_WithDebug(t *testing.T) {
+	type misuses struct {
+		mu   sync.Mutex
+		errs []*TxMisuseError
+	}
+	newInstance := func() *Transactor[*beginnerMock[*committerMock], *committerMock] {
+		b := &beginnerMock[*committerMock]{
+			beginFn: func(context.Context) (*committerMock, error) {
+				return &committerMock{
+					commitFn: func(context.Context) error {
+						return nil
+					},
+					rollbackFn: func(context.Context) error {
+						return nil
+					},
+				}, nil
+			},
+		}
+		return NewTransactor[*beginnerMock[*committerMock], *committerMock](
+			b,
+			NewContextOperator[*beginnerMock[*committerMock], *committerMock](b),
+		)
+	}
+	handler := func(m *misuses) TxMisuseHandler {
+		return func(_ context.Context, err *TxMisuseError) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			m.errs = append(m.errs, err)
+		}
+	}
+	inGoroutine := func(fn func()) {
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn()
+		}()
+		wg.Wait()
+	}
+
+	t.Run("concurrent_use", func(t *testing.T) {
+		var (
+			m       misuses
+			ctx     = context.Background()
+			tr      = newInstance().WithDebug(handler(&m))
+			entered = make(chan struct{})
+			release = make(chan struct{})
+			wg      sync.WaitGroup
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_ = tr.WithinTx(ctx, func(context.Context) error {
+					close(entered)
+					<-release
+					return nil
+				})
+			}()
+			<-entered
+			inGoroutine(func() {
+				_, ok := tr.TryGetTx(ctx)
+				assert.True(t, ok)
+			})
+			close(release)
+			wg.Wait()
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(m.errs))
+
+		misuse := m.errs[0]
+		assert.ErrorIs(t, misuse, ErrTxConcurrentUse)
+		assert.True(t, misuse.Goroutine != misuse.OtherGoroutine)
+		assert.True(t, strings.HasPrefix(misuse.Stack, "github.com/kozmod/oniontx/mtx.Test_Transactor_WithDebug"))
+		assert.True(t, strings.HasPrefix(misuse.OtherStack, "github.com/kozmod/oniontx/mtx.Test_Transactor_WithDebug"))
+	})
+	t.Run("sequential_use", func(t *testing.T) {
+		var (
+			m   misuses
+			ctx = context.Background()
+			tr  = newInstance().WithDebug(handler(&m))
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			_, ok := tr.TryGetTx(ctx)
+			assert.True(t, ok)
+			// the owner goroutine waits for the goroutines, so the uses do not overlap.
+			inGoroutine(func() {
+				_, ok := tr.TryGetTx(ctx)
+				assert.True(t, ok)
+			})
+			inGoroutine(func() {
+				_ = tr.WithinTx(ctx, func(context.Context) error {
+					return nil
+				})
+			})
+			_, ok = tr.TryGetTx(ctx)
+			assert.True(t, ok)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(m.errs))
+	})
+	t.Run("strict_non_owner_use", func(t *testing.T) {
+		var (
+			m   misuses
+			ctx = context.Background()
+			tr  = newInstance()
+		)
+		// the transaction is checked by the nested call of another Transactor.
+		err := tr.WithStrictDebug(handler(&m)).WithinTx(ctx, func(ctx context.Context) error {
+			inGoroutine(func() {
+				_ = tr.WithinTx(ctx, func(context.Context) error {
+					return nil
+				})
+			})
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(m.errs))
+
+		misuse := m.errs[0]
+		assert.ErrorIs(t, misuse, ErrTxNonOwnerUse)
+		assert.True(t, misuse.Goroutine != misuse.OtherGoroutine)
+		assert.True(t, strings.HasPrefix(misuse.OtherStack, "github.com/kozmod/oniontx/mtx.Test_Transactor_WithDebug"))
+	})
+	t.Run("use_after_done", func(t *testing.T) {
+		var (
+			m     misuses
+			ctx   = context.Background()
+			tr    = newInstance().WithDebug(handler(&m))
+			txCtx context.Context
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			txCtx = ctx
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(m.errs))
+
+		_, ok := tr.TryGetTx(txCtx)
+		assert.True(t, ok)
+		assert.Equal(t, 1, len(m.errs))
+		assert.ErrorIs(t, m.errs[0], ErrTxUseAfterDone)
+		assert.True(t, m.errs[0].Goroutine == m.errs[0].OtherGoroutine)
+	})
+	t.Run("nil_handler_panics", func(t *testing.T) {
+		var (
+			ctx   = context.Background()
+			tr    = newInstance().WithDebug(nil)
+			txCtx context.Context
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			txCtx = ctx
+			return nil
+		})
+		assert.NoError(t, err)
+
+		var p any
+		func() {
+			defer func() {
+				p = recover()
+			}()
+			_, _ = tr.TryGetTx(txCtx)
+		}()
+		misuse, ok := p.(*TxMisuseError)
+		assert.True(t, ok)
+		assert.ErrorIs(t, misuse, ErrTxUseAfterDone)
+	})
+	t.Run("disabled", func(t *testing.T) {
+		var (
+			ctx   = context.Background()
+			tr    = newInstance()
+			txCtx context.Context
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			txCtx = ctx
+			inGoroutine(func() {
+				_, _ = tr.TryGetTx(ctx)
+			})
+			return nil
+		})
+		assert.NoError(t, err)
+		_, ok := tr.TryGetTx(txCtx)
+		assert.True(t, ok)
+	})
+}
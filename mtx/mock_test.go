@@ -48,3 +48,95 @@ func (c committerValueMock) Commit(ctx context.Context) error {
 func (c committerValueMock) Rollback(ctx context.Context) error {
 	return c.committer.commitFn(ctx)
 }
+
+// savepointMock was added to avoid to use external dependencies for mocking (SavepointTx).
+type savepointMock struct {
+	committerMock
+	savepointFn  func(ctx context.Context, name string) error
+	rollbackToFn func(ctx context.Context, name string) error
+	releaseFn    func(ctx context.Context, name string) error
+}
+
+func (s *savepointMock) Savepoint(ctx context.Context, name string) error {
+	return s.savepointFn(ctx, name)
+}
+
+func (s *savepointMock) RollbackTo(ctx context.Context, name string) error {
+	return s.rollbackToFn(ctx, name)
+}
+
+func (s *savepointMock) Release(ctx context.Context, name string) error {
+	return s.releaseFn(ctx, name)
+}
+
+// savepointCapableMock was added to avoid to use external dependencies for mocking (SavepointCapableTx).
+type savepointCapableMock struct {
+	savepointMock
+	supported bool
+}
+
+func (s *savepointCapableMock) SupportsSavepoints() bool {
+	return s.supported
+}
+
+// beginnerWithOptionsMock was added to avoid to use external dependencies for mocking (TxBeginnerWithOptions).
+type beginnerWithOptionsMock[T Tx] struct {
+	beginnerMock[T]
+	beginWithOptionsFn func(ctx context.Context, opts TxOptions) (T, error)
+}
+
+func (b *beginnerWithOptionsMock[T]) BeginTxWithOptions(ctx context.Context, opts TxOptions) (T, error) {
+	return b.beginWithOptionsFn(ctx, opts)
+}
+
+// preparableMock was added to avoid to use external dependencies for mocking (PreparableTx).
+type preparableMock struct {
+	committerMock
+	prepareFn          func(ctx context.Context, gid string) error
+	commitPreparedFn   func(ctx context.Context, gid string) error
+	rollbackPreparedFn func(ctx context.Context, gid string) error
+}
+
+func (p *preparableMock) Prepare(ctx context.Context, gid string) error {
+	return p.prepareFn(ctx, gid)
+}
+
+func (p *preparableMock) CommitPrepared(ctx context.Context, gid string) error {
+	return p.commitPreparedFn(ctx, gid)
+}
+
+func (p *preparableMock) RollbackPrepared(ctx context.Context, gid string) error {
+	return p.rollbackPreparedFn(ctx, gid)
+}
+
+// resolverMock was added to avoid to use external dependencies for mocking (PreparedResolver).
+type resolverMock struct {
+	preparedIDsFn      func(ctx context.Context) ([]string, error)
+	commitPreparedFn   func(ctx context.Context, gid string) error
+	rollbackPreparedFn func(ctx context.Context, gid string) error
+}
+
+func (r *resolverMock) PreparedIDs(ctx context.Context) ([]string, error) {
+	return r.preparedIDsFn(ctx)
+}
+
+func (r *resolverMock) CommitPrepared(ctx context.Context, gid string) error {
+	return r.commitPreparedFn(ctx, gid)
+}
+
+func (r *resolverMock) RollbackPrepared(ctx context.Context, gid string) error {
+	return r.rollbackPreparedFn(ctx, gid)
+}
+
+// decisionLogMock was added to avoid to use external dependencies for mocking (DecisionLog).
+type decisionLogMock struct {
+	*MemoryDecisionLog
+	logFn func(ctx context.Context, decision Decision) error
+}
+
+func (l *decisionLogMock) Log(ctx context.Context, decision Decision) error {
+	if l.logFn != nil {
+		return l.logFn(ctx, decision)
+	}
+	return l.MemoryDecisionLog.Log(ctx, decision)
+}
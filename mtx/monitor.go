@@ -0,0 +1,210 @@
+package mtx
+
+import (
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stackDepth is the maximum number of frames of the stacks captured by TxMonitor and the debug mode.
+const stackDepth = 32
+
+// TxInfo describes an open top-level transaction registered in TxMonitor.
+type TxInfo struct {
+	// TxID is the identifier of the transaction (see TxEvent.TxID).
+	TxID uint64
+	// Begun is the time when the transaction began.
+	Begun time.Time
+	// Duration is the time elapsed since the transaction began.
+	Duration time.Duration
+	// Options contains the options of the transaction (see Transactor.WithTxOptions).
+	Options TxOptions
+	// Stack is the stack trace of the goroutine which began the transaction
+	// (the frames of the Transactor are omitted).
+	Stack string
+}
+
+// monitoredTx is an open transaction registered in TxMonitor.
+type monitoredTx struct {
+	id      uint64
+	begun   time.Time
+	options TxOptions
+	callers []uintptr
+	timer   *time.Timer
+}
+
+// info returns the TxInfo of the transaction at the moment now.
+func (t *monitoredTx) info(now time.Time) TxInfo {
+	return TxInfo{
+		TxID:     t.id,
+		Begun:    t.begun,
+		Duration: now.Sub(t.begun),
+		Options:  t.options,
+		Stack:    formatCallers(t.callers),
+	}
+}
+
+// TxMonitor tracks the open top-level transactions of the Transactor instances configured by Transactor.WithMonitor,
+// like pg_stat_activity from inside the application.
+//
+// A transaction is registered after it begins and unregistered after it is committed or rolled back,
+// so a transaction which is never completed (for example, the function is blocked forever
+// or the transaction is left open by PanicPolicyRepanic) stays in the open set.
+// If the threshold is positive, the callback is called (once per transaction, in its own goroutine)
+// when a transaction is open longer than the threshold.
+//
+// TxMonitor is safe for concurrent use and can be shared by several Transactor instances.
+type TxMonitor struct {
+	mu         sync.Mutex
+	open       map[uint64]*monitoredTx
+	threshold  time.Duration
+	onExceeded func(info TxInfo)
+}
+
+// NewTxMonitor returns a new TxMonitor.
+// The onExceeded callback is called when a transaction is open longer than the threshold.
+// A non-positive threshold or a nil callback disables the callback.
+//
+// Example:
+//
+//	monitor := mtx.NewTxMonitor(30*time.Second, func(info mtx.TxInfo) {
+//	    log.Printf("tx [%d] is open for %s, begun at:\n%s", info.TxID, info.Duration, info.Stack)
+//	})
+//	transactor = transactor.WithMonitor(monitor)
+func NewTxMonitor(threshold time.Duration, onExceeded func(info TxInfo)) *TxMonitor {
+	return &TxMonitor{
+		open:       make(map[uint64]*monitoredTx),
+		threshold:  threshold,
+		onExceeded: onExceeded,
+	}
+}
+
+// Open returns the open transactions ordered by the begin time (the oldest first).
+func (m *TxMonitor) Open() []TxInfo {
+	now := time.Now()
+	m.mu.Lock()
+	txs := make([]*monitoredTx, 0, len(m.open))
+	for _, tx := range m.open {
+		txs = append(txs, tx)
+	}
+	m.mu.Unlock()
+
+	slices.SortFunc(txs, func(a, b *monitoredTx) int {
+		return a.begun.Compare(b.begun)
+	})
+	infos := make([]TxInfo, 0, len(txs))
+	for _, tx := range txs {
+		infos = append(infos, tx.info(now))
+	}
+	return infos
+}
+
+// OpenLongerThan returns the open transactions which are open longer than d, ordered by the begin time.
+func (m *TxMonitor) OpenLongerThan(d time.Duration) []TxInfo {
+	return slices.DeleteFunc(m.Open(), func(info TxInfo) bool {
+		return info.Duration <= d
+	})
+}
+
+// register adds the transaction of the state to the open set and captures the stack of the caller.
+func (m *TxMonitor) register(state *txState) {
+	tx := &monitoredTx{
+		id:      state.id,
+		begun:   state.begun,
+		options: state.options,
+		callers: captureCallers(1),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.open[tx.id] = tx
+	if m.threshold > 0 && m.onExceeded != nil {
+		tx.timer = time.AfterFunc(m.threshold-time.Since(tx.begun), func() {
+			m.exceeded(tx.id)
+		})
+	}
+}
+
+// unregister removes the transaction from the open set.
+func (m *TxMonitor) unregister(id uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tx, ok := m.open[id]
+	if !ok {
+		return
+	}
+	if tx.timer != nil {
+		tx.timer.Stop()
+	}
+	delete(m.open, id)
+}
+
+// exceeded calls the callback if the transaction is still open.
+func (m *TxMonitor) exceeded(id uint64) {
+	m.mu.Lock()
+	tx, ok := m.open[id]
+	m.mu.Unlock()
+	if !ok {
+		return
+	}
+	m.onExceeded(tx.info(time.Now()))
+}
+
+// captureCallers returns the program counters of the stack of the caller.
+// The skip is the number of the frames to skip above the caller.
+func captureCallers(skip int) []uintptr {
+	callers := make([]uintptr, stackDepth)
+	// skip runtime.Callers and captureCallers.
+	return callers[:runtime.Callers(skip+2, callers)]
+}
+
+// formatCallers formats the stack trace like runtime/debug.Stack.
+// The leading frames of the Transactor are omitted.
+func formatCallers(callers []uintptr) string {
+	if len(callers) == 0 {
+		return ""
+	}
+	var (
+		b       strings.Builder
+		leading = true
+		frames  = runtime.CallersFrames(callers)
+	)
+	for {
+		frame, more := frames.Next()
+		if leading && isTransactorFrame(frame.Function) {
+			if !more {
+				break
+			}
+			continue
+		}
+		leading = false
+		b.WriteString(frame.Function)
+		b.WriteString("\n\t")
+		b.WriteString(frame.File)
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(frame.Line))
+		b.WriteByte('\n')
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// isTransactorFrame reports whether the function is a method of Transactor or its retrier.
+func isTransactorFrame(function string) bool {
+	const pkg = "github.com/kozmod/oniontx/mtx."
+	name, ok := strings.CutPrefix(function, pkg)
+	return ok && (strings.HasPrefix(name, "(*Transactor[") || strings.HasPrefix(name, "(*retrier)"))
+}
+
+// WithMonitor returns a new Transactor that registers its top-level transactions in the monitor.
+// A nil monitor disables the monitoring. The original Transactor is not modified.
+func (t *Transactor[B, T]) WithMonitor(monitor *TxMonitor) *Transactor[B, T] {
+	c := *t
+	c.monitor = monitor
+	return &c
+}
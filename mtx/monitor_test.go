@@ -0,0 +1,132 @@
+package mtx
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+)
+
+func Test_TxMonitor(t *testing.T) {
+	newInstance := func(monitor *TxMonitor) *Transactor[*beginnerMock[*committerMock], *committerMock] {
+		b := &beginnerMock[*committerMock]{
+			beginFn: func(context.Context) (*committerMock, error) {
+				return &committerMock{
+					commitFn: func(context.Context) error {
+						return nil
+					},
+					rollbackFn: func(context.Context) error {
+						return nil
+					},
+				}, nil
+			},
+		}
+		return NewTransactor[*beginnerMock[*committerMock], *committerMock](
+			b,
+			NewContextOperator[*beginnerMock[*committerMock], *committerMock](b),
+		).WithMonitor(monitor)
+	}
+
+	t.Run("open", func(t *testing.T) {
+		var (
+			ctx     = context.Background()
+			monitor = NewTxMonitor(0, nil)
+			tr      = newInstance(monitor)
+			txID    uint64
+		)
+		err := tr.WithObserver(TxObserverFunc(func(_ context.Context, e TxEvent) {
+			txID = e.TxID
+		})).WithinTx(ctx, func(ctx context.Context) error {
+			return tr.WithinTx(ctx, func(ctx context.Context) error {
+				open := monitor.Open()
+				// nested calls are not registered.
+				assert.Equal(t, 1, len(open))
+				assert.Equal(t, txID, open[0].TxID)
+				assert.True(t, open[0].Duration >= 0)
+				assert.True(t, strings.HasPrefix(open[0].Stack, "github.com/kozmod/oniontx/mtx.Test_TxMonitor"))
+				return nil
+			})
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(monitor.Open()))
+	})
+	t.Run("exceeded", func(t *testing.T) {
+		var (
+			ctx      = context.Background()
+			exceeded = make(chan TxInfo, 1)
+			monitor  = NewTxMonitor(10*time.Millisecond, func(info TxInfo) {
+				exceeded <- info
+			})
+			tr = newInstance(monitor)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			info := <-exceeded
+			assert.True(t, info.Duration >= 10*time.Millisecond)
+			assert.Equal(t, 1, len(monitor.OpenLongerThan(10*time.Millisecond)))
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(monitor.Open()))
+	})
+	t.Run("not_exceeded", func(t *testing.T) {
+		var (
+			ctx     = context.Background()
+			monitor = NewTxMonitor(time.Hour, func(TxInfo) {
+				t.Fatalf("should not have been called")
+			})
+		)
+		err := newInstance(monitor).WithinTx(ctx, func(context.Context) error {
+			assert.Equal(t, 0, len(monitor.OpenLongerThan(time.Hour)))
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(monitor.Open()))
+	})
+	t.Run("leaked", func(t *testing.T) {
+		var (
+			ctx     = context.Background()
+			monitor = NewTxMonitor(0, nil)
+			tr      = newInstance(monitor)
+			begun   = make(chan struct{})
+			release = make(chan struct{})
+			done    = make(chan error)
+		)
+		go func() {
+			done <- tr.WithinTx(ctx, func(context.Context) error {
+				close(begun)
+				<-release
+				return nil
+			})
+		}()
+		<-begun
+		assert.Equal(t, 1, len(monitor.OpenLongerThan(0)))
+
+		close(release)
+		assert.NoError(t, <-done)
+		assert.Equal(t, 0, len(monitor.Open()))
+	})
+	t.Run("repanic", func(t *testing.T) {
+		var (
+			ctx     = context.Background()
+			monitor = NewTxMonitor(0, nil)
+			tr      = newInstance(monitor).WithPanicPolicy(PanicPolicyRepanic)
+		)
+		func() {
+			defer func() {
+				_ = recover()
+			}()
+			_ = tr.WithinTx(ctx, func(context.Context) error {
+				panic("tx panic")
+			})
+		}()
+		// the transaction is left open.
+		assert.Equal(t, 1, len(monitor.Open()))
+
+		_ = tr.WithPanicPolicy(PanicPolicyRecover).WithinTx(ctx, func(context.Context) error {
+			panic("tx panic")
+		})
+		assert.Equal(t, 1, len(monitor.Open()))
+	})
+}
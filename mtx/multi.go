@@ -0,0 +1,468 @@
+package mtx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/kozmod/oniontx/internal/errors"
+)
+
+var (
+	// ErrNilResource indicates that a Resource registered in MultiTransactor is nil.
+	ErrNilResource = fmt.Errorf("resource is nil")
+
+	// ErrDuplicateResource indicates that several resources are registered in MultiTransactor with the same name.
+	ErrDuplicateResource = fmt.Errorf("duplicate resource")
+
+	// ErrUnknownResource indicates that the commit order of MultiTransactor contains an unregistered resource.
+	ErrUnknownResource = fmt.Errorf("unknown resource")
+
+	// ErrHeuristicMixed indicates that some resources of MultiTransactor were committed
+	// and the others were not (heuristic mixed outcome). See HeuristicMixedError.
+	ErrHeuristicMixed = fmt.Errorf("heuristic mixed outcome")
+)
+
+// Resource is a transactional resource that can be registered in MultiTransactor.
+// It is implemented by Transactor.
+type Resource interface {
+	// beginResource starts a new transaction of the resource and injects it into the context.
+	// If the context already contains a transaction of the resource, the transaction is joined
+	// and nil resourceTx is returned.
+	beginResource(ctx context.Context, callbacks *txCallbacks) (context.Context, *resourceTx, error)
+}
+
+// resourceTx is a transaction started by a Resource.
+type resourceTx struct {
+	commit   func(ctx context.Context) error
+	rollback func(ctx context.Context) error
+	// preparable is not nil if the transaction implements PreparableTx.
+	preparable PreparableTx
+}
+
+// beginResource implements Resource.
+// The Propagation and the retry of the Transactor are not applied.
+func (t *Transactor[B, T]) beginResource(ctx context.Context, callbacks *txCallbacks) (context.Context, *resourceTx, error) {
+	var (
+		nilBeginner B
+		nilOperator CtxOperator[T] = nil
+	)
+	switch {
+	case t == nil:
+		return ctx, nil, fmt.Errorf("transactor is nil")
+	case t.beginner == nilBeginner:
+		return ctx, nil, fmt.Errorf("transactor - can't begin: %w", ErrNilTxBeginner)
+	case t.operator == nilOperator:
+		return ctx, nil, fmt.Errorf("transactor - can't try extract transaction: %w", ErrNilTxOperator)
+	}
+
+	if _, ok := t.operator.Extract(ctx); ok {
+		return ctx, nil, nil
+	}
+
+	tx, err := t.begin(ctx)
+	if err != nil {
+		return ctx, nil, err
+	}
+
+	ctx = t.operator.Inject(ctx, tx)
+	ctx = injectState(ctx, t.beginner, newTxState(t.txOptions, callbacks))
+	preparable, _ := any(tx).(PreparableTx)
+	return ctx, &resourceTx{
+		commit: tx.Commit,
+		rollback: func(ctx context.Context) error {
+			return tx.Rollback(t.rollbackCtxFactory(ctx))
+		},
+		preparable: preparable,
+	}, nil
+}
+
+// HeuristicMixedError is returned by MultiTransactor.WithinTx when a commit fails
+// after some resources were already committed (heuristic mixed outcome).
+// It satisfies errors.Is for ErrHeuristicMixed and ErrCommitFailed.
+type HeuristicMixedError struct {
+	// Committed contains the names of the committed resources in the commit order.
+	Committed []string
+	// Failed is the name of the resource whose commit failed.
+	Failed string
+	// RolledBack contains the names of the resources rolled back after the failed commit.
+	RolledBack []string
+	// RollbackFailed contains the names of the resources whose rollback failed after the failed commit.
+	RollbackFailed []string
+	// Err contains the commit error and the rollback errors.
+	Err error
+}
+
+// Error returns the error message.
+func (e *HeuristicMixedError) Error() string {
+	return fmt.Sprintf(
+		"heuristic mixed outcome: committed %v, failed [%s], rolled back %v, rollback failed %v: %v",
+		e.Committed, e.Failed, e.RolledBack, e.RollbackFailed, e.Err,
+	)
+}
+
+// Unwrap returns ErrHeuristicMixed and the commit and rollback errors.
+func (e *HeuristicMixedError) Unwrap() []error {
+	return []error{ErrHeuristicMixed, e.Err}
+}
+
+// namedResource is a Resource registered in MultiTransactor.
+type namedResource struct {
+	name     string
+	resource Resource
+}
+
+// MultiTransactor manages transactions of several resources (Transactor instances
+// with different TxBeginners: Postgres, Redis, Mongo, etc.) as one unit using
+// the best-effort one-phase commit.
+//
+// MultiTransactor begins the transactions in the registration order and injects all of them into the context,
+// so the repositories of each resource work as within Transactor.WithinTx.
+// After the function succeeds, the transactions are committed in the commit order
+// (the registration order by default, see MultiTransactor.WithCommitOrder).
+// If a commit fails, the transactions which are not committed yet are rolled back.
+// If some transactions were already committed, HeuristicMixedError is returned
+// with the names of the committed and rolled back resources.
+//
+// Use saga.Saga when partial commits are not acceptable or must be compensated.
+type MultiTransactor struct {
+	resources          []namedResource
+	commitOrder        []string
+	hookOrder          HookOrder
+	rollbackCtxFactory func(ctx context.Context) context.Context
+	panicPolicy        PanicPolicy
+	observer           TxObserver
+}
+
+// NewMultiTransactor creates a new MultiTransactor without resources (see MultiTransactor.WithResource).
+func NewMultiTransactor() *MultiTransactor {
+	return &MultiTransactor{
+		rollbackCtxFactory: func(ctx context.Context) context.Context {
+			return ctx
+		},
+	}
+}
+
+// WithResource returns a new MultiTransactor with the registered resource.
+// The name identifies the resource in the commit order and in the errors.
+// The original MultiTransactor is not modified.
+//
+// Example:
+//
+//	multi := mtx.NewMultiTransactor().
+//	    WithResource("postgres", pgTransactor).
+//	    WithResource("redis", redisTransactor)
+func (m *MultiTransactor) WithResource(name string, resource Resource) *MultiTransactor {
+	c := *m
+	c.resources = append(slices.Clone(m.resources), namedResource{name: name, resource: resource})
+	return &c
+}
+
+// WithCommitOrder returns a new MultiTransactor that commits the named resources first (in the given order)
+// and then the other resources in the registration order.
+// It is recommended to commit the resource whose failure is the most likely (or the most critical) first.
+// The original MultiTransactor is not modified.
+func (m *MultiTransactor) WithCommitOrder(names ...string) *MultiTransactor {
+	c := *m
+	c.commitOrder = slices.Clone(names)
+	return &c
+}
+
+// WithBeforeCommitOrder returns a new MultiTransactor that calls the before-commit hooks in the given order.
+// The original MultiTransactor is not modified.
+func (m *MultiTransactor) WithBeforeCommitOrder(order HookOrder) *MultiTransactor {
+	c := *m
+	c.hookOrder = order
+	return &c
+}
+
+// WithRollbackCtxFactory returns a new MultiTransactor that derives the context used for the rollbacks
+// (before the rollback context factories of the resources are applied) and the after-rollback callbacks.
+// If factory is nil or returns nil, the original operation context is used.
+// The original MultiTransactor is not modified.
+func (m *MultiTransactor) WithRollbackCtxFactory(factory func(ctx context.Context) context.Context) *MultiTransactor {
+	c := *m
+	c.rollbackCtxFactory = func(ctx context.Context) context.Context {
+		if factory != nil {
+			if newCtx := factory(ctx); newCtx != nil {
+				return newCtx
+			}
+		}
+		return ctx
+	}
+	return &c
+}
+
+// WithPanicPolicy returns a new MultiTransactor that handles the panics of the function according to the policy
+// (see Transactor.WithPanicPolicy). PanicPolicyRepanic leaves the transactions of all resources open.
+// The original MultiTransactor is not modified.
+func (m *MultiTransactor) WithPanicPolicy(policy PanicPolicy) *MultiTransactor {
+	c := *m
+	c.panicPolicy = policy
+	return &c
+}
+
+// WithObserver returns a new MultiTransactor that sends the lifecycle events to the observer
+// (see Transactor.WithObserver). The events describe all resources as one transaction:
+// the begin of all transactions, the commit in the commit order or the rollback.
+// The observers of the resources are not called. The original MultiTransactor is not modified.
+func (m *MultiTransactor) WithObserver(observer TxObserver) *MultiTransactor {
+	c := *m
+	c.observer = observer
+	return &c
+}
+
+// WithinTx executes the function within the transactions of all registered resources.
+//
+// The transactions which already exist in the context are joined: they are neither committed
+// nor rolled back by MultiTransactor. The callbacks (BeforeCommit, AfterCommit, AfterRollback)
+// are shared by all resources: after-commit callbacks are called only if all transactions are committed,
+// otherwise after-rollback callbacks are called (including the heuristic mixed outcome).
+//
+// The errors of the transactions are returned as TxError (see Transactor.WithinTx).
+//
+// Returns:
+//   - ErrBeginTx if a transaction cannot be begun and the begun transactions are rolled back
+//   - ErrRollbackSuccess or ErrRollbackFailed (with ErrPanicRecovered on panic) if the function fails
+//   - ErrRollbackSuccess with ErrRollbackOnly if the transactions were marked by SetRollbackOnly
+//   - ErrCommitFailed if the first commit fails and all transactions are rolled back
+//   - HeuristicMixedError (ErrHeuristicMixed, ErrCommitFailed) if a commit fails after other commits
+func (m *MultiTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if m == nil {
+		return fmt.Errorf("multi transactor is nil")
+	}
+	if fn == nil {
+		return fmt.Errorf("multi transactor - can't execute: %w", ErrNilTxFunc)
+	}
+	order, err := m.commitSequence()
+	if err != nil {
+		return fmt.Errorf("multi transactor - can't execute: %w", err)
+	}
+
+	ctx, recorder := takeCommitRecorder(ctx)
+	var (
+		parentCtx = ctx
+		started   = make([]*resourceTx, len(m.resources))
+		state     = newTxState(TxOptions{}, &txCallbacks{})
+	)
+	err = m.observed(ctx, state, TxEventBeginStart, TxEventBeginEnd, func() error {
+		for i, r := range m.resources {
+			var (
+				rtx  *resourceTx
+				bErr error
+			)
+			if ctx, rtx, bErr = r.resource.beginResource(ctx, state.txCallbacks); bErr != nil {
+				return fmt.Errorf("resource [%s]: %w", r.name, bErr)
+			}
+			started[i] = rtx
+		}
+		return nil
+	})
+	if err != nil {
+		if rbErr := m.rollback(ctx, state, started, nil); rbErr != nil {
+			return newTxError(
+				"multi transactor - can't begin",
+				TxError{Phase: TxPhaseBegin, BeginErr: err, RollbackErr: rbErr},
+				err, ErrRollbackFailed, rbErr,
+			)
+		}
+		return newTxError("multi transactor - can't begin", TxError{Phase: TxPhaseBegin, BeginErr: err}, err)
+	}
+	ctx = injectCurrentState(ctx, state)
+
+	defer func() {
+		p := recover()
+		if p == nil && err == nil {
+			err = runBeforeCommit(ctx, state, m.hookOrder)
+		}
+
+		switch {
+		case p != nil:
+			pErr := errors.WrapPanic(p)
+			m.observe(ctx, state, TxEvent{Type: TxEventPanicRecovered, Err: pErr})
+			if m.panicPolicy == PanicPolicyRepanic {
+				panic(p)
+			}
+			if rbErr := m.rollback(ctx, state, started, nil); rbErr != nil {
+				err = newTxError(
+					"multi transactor - panic",
+					TxError{Phase: TxPhaseRollback, RollbackErr: rbErr, Panic: p, Stack: pErr.Stack()},
+					ErrRollbackFailed, ErrPanicRecovered, rbErr, pErr,
+				)
+			} else {
+				err = newTxError(
+					"multi transactor - panic",
+					TxError{Phase: TxPhaseFn, Panic: p, Stack: pErr.Stack()},
+					ErrRollbackSuccess, ErrPanicRecovered, pErr,
+				)
+			}
+			err = runAfterRollback(parentCtx, state, err, m.rollbackCtxFactory)
+			if m.panicPolicy == PanicPolicyRollbackRepanic {
+				panic(p)
+			}
+		case err != nil:
+			if rbErr := m.rollback(ctx, state, started, nil); rbErr != nil {
+				err = newTxError(
+					"multi transactor - call",
+					TxError{Phase: TxPhaseRollback, Err: err, RollbackErr: rbErr},
+					ErrRollbackFailed, rbErr, err,
+				)
+			} else {
+				err = newTxError("multi transactor - call", TxError{Phase: TxPhaseFn, Err: err}, ErrRollbackSuccess, err)
+			}
+			err = runAfterRollback(parentCtx, state, err, m.rollbackCtxFactory)
+		default:
+			if cErr := m.observed(ctx, state, TxEventCommitStart, TxEventCommitEnd, func() error {
+				return m.commit(ctx, started, order)
+			}); cErr != nil {
+				err = newTxError("multi transactor", TxError{Phase: TxPhaseCommit, CommitErr: cErr}, cErr)
+				err = runAfterRollback(parentCtx, state, err, m.rollbackCtxFactory)
+				return
+			}
+			recorder.markCommitted()
+			err = runAfterCommit(parentCtx, state)
+		}
+	}()
+
+	err = fn(ctx)
+	return err
+}
+
+// indexResources validates the resources and returns their indexes by name.
+func indexResources(resources []namedResource) (map[string]int, error) {
+	index := make(map[string]int, len(resources))
+	for i, r := range resources {
+		if r.resource == nil {
+			return nil, fmt.Errorf("resource [%s]: %w", r.name, ErrNilResource)
+		}
+		if _, ok := index[r.name]; ok {
+			return nil, fmt.Errorf("resource [%s]: %w", r.name, ErrDuplicateResource)
+		}
+		index[r.name] = i
+	}
+	return index, nil
+}
+
+// commitSequence validates the resources and returns their indexes in the commit order.
+func (m *MultiTransactor) commitSequence() ([]int, error) {
+	index, err := indexResources(m.resources)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		order   = make([]int, 0, len(m.resources))
+		ordered = make(map[int]bool, len(m.commitOrder))
+	)
+	for _, name := range m.commitOrder {
+		i, ok := index[name]
+		if !ok {
+			return nil, fmt.Errorf("commit order [%s]: %w", name, ErrUnknownResource)
+		}
+		if ordered[i] {
+			return nil, fmt.Errorf("commit order [%s]: %w", name, ErrDuplicateResource)
+		}
+		ordered[i] = true
+		order = append(order, i)
+	}
+	for i := range m.resources {
+		if !ordered[i] {
+			order = append(order, i)
+		}
+	}
+	return order, nil
+}
+
+// commit commits the started transactions in the order.
+// If a commit fails, the remaining transactions are rolled back.
+func (m *MultiTransactor) commit(ctx context.Context, started []*resourceTx, order []int) error {
+	var committed []string
+	for pos, i := range order {
+		rtx := started[i]
+		if rtx == nil {
+			continue
+		}
+		name := m.resources[i].name
+		if cErr := rtx.commit(ctx); cErr != nil {
+			var (
+				remaining             = make([]*resourceTx, len(started))
+				rolledBack, notRolled []string
+				commitErr             = fmt.Errorf("resource [%s]: %w", name, cErr)
+				report                = func(name string, rbErr error) {
+					if rbErr != nil {
+						notRolled = append(notRolled, name)
+						return
+					}
+					rolledBack = append(rolledBack, name)
+				}
+			)
+			for _, j := range order[pos+1:] {
+				remaining[j] = started[j]
+			}
+			rbErr := rollbackResources(m.rollbackCtxFactory(ctx), m.resources, remaining, report)
+
+			if len(committed) == 0 {
+				if rbErr != nil {
+					return errors.Join(ErrCommitFailed, commitErr, ErrRollbackFailed, rbErr)
+				}
+				return errors.Join(ErrCommitFailed, commitErr)
+			}
+
+			herr := &HeuristicMixedError{
+				Committed:      committed,
+				Failed:         name,
+				RolledBack:     rolledBack,
+				RollbackFailed: notRolled,
+				Err:            errors.Join(ErrCommitFailed, commitErr),
+			}
+			if rbErr != nil {
+				herr.Err = errors.Join(ErrCommitFailed, commitErr, ErrRollbackFailed, rbErr)
+			}
+			return herr
+		}
+		committed = append(committed, name)
+	}
+	return nil
+}
+
+// rollback rolls back the started transactions in the reverse registration order.
+// The report function (if not nil) is called with the result of each rollback.
+func (m *MultiTransactor) rollback(ctx context.Context, state *txState, started []*resourceTx, report func(name string, err error)) error {
+	return m.observed(ctx, state, TxEventRollbackStart, TxEventRollbackEnd, func() error {
+		return rollbackResources(m.rollbackCtxFactory(ctx), m.resources, started, report)
+	})
+}
+
+// observe sends the event to the observer (if any).
+func (m *MultiTransactor) observe(ctx context.Context, state *txState, event TxEvent) {
+	observeTx(ctx, m.observer, state, event)
+}
+
+// observed calls the operation between the "Start" and "End" events.
+func (m *MultiTransactor) observed(ctx context.Context, state *txState, start, end TxEventType, op func() error) error {
+	return observedTx(ctx, m.observer, state, start, end, op)
+}
+
+// rollbackResources rolls back the started transactions of the resources in the reverse order.
+// The report function (if not nil) is called with the result of each rollback.
+func rollbackResources(rollbackCtx context.Context, resources []namedResource, started []*resourceTx, report func(name string, err error)) error {
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		rtx := started[i]
+		if rtx == nil {
+			continue
+		}
+		name := resources[i].name
+		rbErr := rtx.rollback(rollbackCtx)
+		if report != nil {
+			report(name, rbErr)
+		}
+		if rbErr != nil {
+			errs = append(errs, fmt.Errorf("resource [%s]: %w", name, rbErr))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,327 @@
+package mtx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+)
+
+func Test_MultiTransactor(t *testing.T) {
+	type resource struct {
+		name       string
+		beginErr   error
+		commitErr  error
+		operator   *ContextOperator[*beginnerMock[*committerMock], *committerMock]
+		transactor *Transactor[*beginnerMock[*committerMock], *committerMock]
+	}
+	type journal struct {
+		events []string
+	}
+	newResource := func(j *journal, name string) *resource {
+		r := &resource{name: name}
+		b := &beginnerMock[*committerMock]{
+			beginFn: func(context.Context) (*committerMock, error) {
+				if r.beginErr != nil {
+					return nil, r.beginErr
+				}
+				j.events = append(j.events, "begin:"+name)
+				return &committerMock{
+					commitFn: func(context.Context) error {
+						if r.commitErr != nil {
+							j.events = append(j.events, "commit_failed:"+name)
+							return r.commitErr
+						}
+						j.events = append(j.events, "commit:"+name)
+						return nil
+					},
+					rollbackFn: func(context.Context) error {
+						j.events = append(j.events, "rollback:"+name)
+						return nil
+					},
+				}, nil
+			},
+		}
+		r.operator = NewContextOperator[*beginnerMock[*committerMock], *committerMock](b)
+		r.transactor = NewTransactor[*beginnerMock[*committerMock], *committerMock](b, r.operator)
+		return r
+	}
+	newMulti := func(resources ...*resource) *MultiTransactor {
+		m := NewMultiTransactor()
+		for _, r := range resources {
+			m = m.WithResource(r.name, r.transactor)
+		}
+		return m
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		var (
+			j     journal
+			ctx   = context.Background()
+			a, b  = newResource(&j, "a"), newResource(&j, "b")
+			multi = newMulti(a, b)
+		)
+		err := multi.WithinTx(ctx, func(ctx context.Context) error {
+			_, ok := a.operator.Extract(ctx)
+			assert.True(t, ok)
+			_, ok = b.operator.Extract(ctx)
+			assert.True(t, ok)
+
+			// nested calls join the transactions of the MultiTransactor
+			err := a.transactor.WithinTx(ctx, func(ctx context.Context) error {
+				return AfterCommit(ctx, func(context.Context) error {
+					j.events = append(j.events, "after_commit")
+					return nil
+				})
+			})
+			assert.NoError(t, err)
+			return BeforeCommit(ctx, func(context.Context) error {
+				j.events = append(j.events, "before_commit")
+				return nil
+			})
+		})
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal(
+			[]string{"begin:a", "begin:b", "before_commit", "commit:a", "commit:b", "after_commit"},
+			j.events,
+		))
+	})
+	t.Run("commit_order", func(t *testing.T) {
+		var (
+			j       journal
+			ctx     = context.Background()
+			a, b, c = newResource(&j, "a"), newResource(&j, "b"), newResource(&j, "c")
+			multi   = newMulti(a, b, c).WithCommitOrder("c", "a")
+		)
+		err := multi.WithinTx(ctx, func(context.Context) error {
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal(
+			[]string{"begin:a", "begin:b", "begin:c", "commit:c", "commit:a", "commit:b"},
+			j.events,
+		))
+	})
+	t.Run("heuristic_mixed_outcome", func(t *testing.T) {
+		var (
+			j          journal
+			ctx        = context.Background()
+			a, b, c    = newResource(&j, "a"), newResource(&j, "b"), newResource(&j, "c")
+			multi      = newMulti(a, b, c)
+			expErr     = fmt.Errorf("commit error")
+			rolledBack bool
+		)
+		b.commitErr = expErr
+		err := multi.WithinTx(ctx, func(ctx context.Context) error {
+			_ = AfterCommit(ctx, func(context.Context) error {
+				t.Fatalf("should not have been called")
+				return nil
+			})
+			return AfterRollback(ctx, func(context.Context) error {
+				rolledBack = true
+				return nil
+			})
+		})
+		assert.ErrorIs(t, err, ErrHeuristicMixed)
+		assert.ErrorIs(t, err, ErrCommitFailed)
+		assert.ErrorIs(t, err, expErr)
+		assert.True(t, rolledBack)
+
+		var herr *HeuristicMixedError
+		assert.True(t, errors.As(err, &herr))
+		assert.True(t, slices.Equal([]string{"a"}, herr.Committed))
+		assert.Equal(t, "b", herr.Failed)
+		assert.True(t, slices.Equal([]string{"c"}, herr.RolledBack))
+		assert.Len(t, herr.RollbackFailed, 0)
+		assert.True(t, slices.Equal(
+			[]string{"begin:a", "begin:b", "begin:c", "commit:a", "commit_failed:b", "rollback:c"},
+			j.events,
+		))
+	})
+	t.Run("first_commit_failed", func(t *testing.T) {
+		var (
+			j      journal
+			ctx    = context.Background()
+			a, b   = newResource(&j, "a"), newResource(&j, "b")
+			multi  = newMulti(a, b)
+			expErr = fmt.Errorf("commit error")
+		)
+		a.commitErr = expErr
+		err := multi.WithinTx(ctx, func(context.Context) error {
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrCommitFailed)
+		assert.ErrorIs(t, err, expErr)
+		assert.ErrorIsNot(t, err, ErrHeuristicMixed)
+		assert.True(t, slices.Equal(
+			[]string{"begin:a", "begin:b", "commit_failed:a", "rollback:b"},
+			j.events,
+		))
+	})
+	t.Run("rollback_on_error_and_panic", func(t *testing.T) {
+		var (
+			j      journal
+			ctx    = context.Background()
+			a, b   = newResource(&j, "a"), newResource(&j, "b")
+			multi  = newMulti(a, b)
+			expErr = fmt.Errorf("call error")
+		)
+		err := multi.WithinTx(ctx, func(context.Context) error {
+			return expErr
+		})
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.ErrorIs(t, err, expErr)
+
+		err = multi.WithinTx(ctx, func(context.Context) error {
+			panic("multi panic")
+		})
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.ErrorIs(t, err, ErrPanicRecovered)
+		assert.True(t, slices.Equal(
+			[]string{"begin:a", "begin:b", "rollback:b", "rollback:a", "begin:a", "begin:b", "rollback:b", "rollback:a"},
+			j.events,
+		))
+	})
+	t.Run("tx_error", func(t *testing.T) {
+		var (
+			j      journal
+			ctx    = context.Background()
+			a, b   = newResource(&j, "a"), newResource(&j, "b")
+			multi  = newMulti(a, b)
+			expErr = fmt.Errorf("call error")
+			txErr  *TxError
+		)
+		err := multi.WithinTx(ctx, func(context.Context) error {
+			return expErr
+		})
+		assert.True(t, errors.As(err, &txErr))
+		assert.Equal(t, TxPhaseFn, txErr.Phase)
+		assert.ErrorIs(t, txErr.Err, expErr)
+
+		a.commitErr = fmt.Errorf("commit error")
+		err = multi.WithinTx(ctx, func(context.Context) error {
+			return nil
+		})
+		assert.True(t, errors.As(err, &txErr))
+		assert.Equal(t, TxPhaseCommit, txErr.Phase)
+		assert.ErrorIs(t, txErr.CommitErr, ErrCommitFailed)
+
+		err = multi.WithinTx(ctx, func(context.Context) error {
+			panic("multi panic")
+		})
+		assert.True(t, errors.As(err, &txErr))
+		assert.Equal(t, TxPhaseFn, txErr.Phase)
+		assert.Equal(t, any("multi panic"), txErr.Panic)
+		assert.True(t, len(txErr.Stack) > 0)
+	})
+	t.Run("panic_policy", func(t *testing.T) {
+		for _, tc := range []struct {
+			policy PanicPolicy
+			events []string
+		}{
+			{policy: PanicPolicyRollbackRepanic, events: []string{"begin:a", "rollback:a", "after_rollback"}},
+			{policy: PanicPolicyRepanic, events: []string{"begin:a"}},
+		} {
+			t.Run(tc.policy.String(), func(t *testing.T) {
+				var (
+					j     journal
+					ctx   = context.Background()
+					multi = newMulti(newResource(&j, "a")).WithPanicPolicy(tc.policy)
+					p     any
+				)
+				func() {
+					defer func() {
+						p = recover()
+					}()
+					_ = multi.WithinTx(ctx, func(ctx context.Context) error {
+						_ = AfterRollback(ctx, func(context.Context) error {
+							j.events = append(j.events, "after_rollback")
+							return nil
+						})
+						panic("multi panic")
+					})
+				}()
+				assert.Equal(t, any("multi panic"), p)
+				assert.True(t, slices.Equal(tc.events, j.events))
+			})
+		}
+	})
+	t.Run("observer", func(t *testing.T) {
+		var (
+			j      journal
+			ctx    = context.Background()
+			a, b   = newResource(&j, "a"), newResource(&j, "b")
+			events []TxEventType
+			ids    = map[uint64]bool{}
+			multi  = newMulti(a, b).WithObserver(TxObserverFunc(func(_ context.Context, e TxEvent) {
+				events = append(events, e.Type)
+				ids[e.TxID] = true
+			}))
+		)
+		assert.NoError(t, multi.WithinTx(ctx, func(context.Context) error {
+			return nil
+		}))
+		assert.Error(t, multi.WithinTx(ctx, func(context.Context) error {
+			panic("multi panic")
+		}))
+		assert.True(t, slices.Equal(
+			[]TxEventType{
+				TxEventBeginStart, TxEventBeginEnd, TxEventCommitStart, TxEventCommitEnd,
+				TxEventBeginStart, TxEventBeginEnd, TxEventPanicRecovered, TxEventRollbackStart, TxEventRollbackEnd,
+			},
+			events,
+		))
+		assert.Equal(t, 2, len(ids))
+	})
+	t.Run("begin_failed", func(t *testing.T) {
+		var (
+			j      journal
+			ctx    = context.Background()
+			a, b   = newResource(&j, "a"), newResource(&j, "b")
+			multi  = newMulti(a, b)
+			expErr = fmt.Errorf("begin error")
+		)
+		b.beginErr = expErr
+		err := multi.WithinTx(ctx, func(context.Context) error {
+			t.Fatalf("should not have been called")
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrBeginTx)
+		assert.ErrorIs(t, err, expErr)
+		assert.True(t, slices.Equal([]string{"begin:a", "rollback:a"}, j.events))
+	})
+	t.Run("join_existing_tx", func(t *testing.T) {
+		var (
+			j     journal
+			ctx   = context.Background()
+			a, b  = newResource(&j, "a"), newResource(&j, "b")
+			multi = newMulti(a, b)
+		)
+		err := a.transactor.WithinTx(ctx, func(ctx context.Context) error {
+			return multi.WithinTx(ctx, func(context.Context) error {
+				return nil
+			})
+		})
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal([]string{"begin:a", "begin:b", "commit:b", "commit:a"}, j.events))
+	})
+	t.Run("validation", func(t *testing.T) {
+		var (
+			j    journal
+			ctx  = context.Background()
+			a, b = newResource(&j, "a"), newResource(&j, "b")
+			fn   = func(context.Context) error {
+				return nil
+			}
+		)
+		assert.ErrorIs(t, newMulti(a, b).WithinTx(ctx, nil), ErrNilTxFunc)
+		assert.ErrorIs(t, newMulti(a, a).WithinTx(ctx, fn), ErrDuplicateResource)
+		assert.ErrorIs(t, newMulti(a, b).WithCommitOrder("c").WithinTx(ctx, fn), ErrUnknownResource)
+		assert.ErrorIs(t, newMulti(a, b).WithCommitOrder("a", "a").WithinTx(ctx, fn), ErrDuplicateResource)
+		assert.ErrorIs(t, NewMultiTransactor().WithResource("nil", nil).WithinTx(ctx, fn), ErrNilResource)
+		assert.Len(t, j.events, 0)
+	})
+}
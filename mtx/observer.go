@@ -0,0 +1,159 @@
+package mtx
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// txIDSeq generates the identifiers of the top-level transactions (see TxEvent.TxID).
+var txIDSeq atomic.Uint64
+
+// TxEventType is the type of TxEvent.
+type TxEventType uint8
+
+const (
+	// TxEventBeginStart is sent before a top-level transaction begins.
+	TxEventBeginStart TxEventType = iota
+	// TxEventBeginEnd is sent after a top-level transaction begins (or fails to begin).
+	TxEventBeginEnd
+	// TxEventJoin is sent when a nested WithinTx call joins the existing transaction.
+	TxEventJoin
+	// TxEventCommitStart is sent before a top-level transaction is committed.
+	TxEventCommitStart
+	// TxEventCommitEnd is sent after a top-level transaction is committed (or fails to commit).
+	TxEventCommitEnd
+	// TxEventRollbackStart is sent before a transaction is rolled back
+	// (or a nested call is rolled back to its savepoint).
+	TxEventRollbackStart
+	// TxEventRollbackEnd is sent after a transaction is rolled back
+	// (or a nested call is rolled back to its savepoint).
+	TxEventRollbackEnd
+	// TxEventPanicRecovered is sent when a panic of the transaction function is recovered.
+	TxEventPanicRecovered
+)
+
+// String returns the name of the event type.
+func (t TxEventType) String() string {
+	switch t {
+	case TxEventBeginStart:
+		return "BeginStart"
+	case TxEventBeginEnd:
+		return "BeginEnd"
+	case TxEventJoin:
+		return "Join"
+	case TxEventCommitStart:
+		return "CommitStart"
+	case TxEventCommitEnd:
+		return "CommitEnd"
+	case TxEventRollbackStart:
+		return "RollbackStart"
+	case TxEventRollbackEnd:
+		return "RollbackEnd"
+	case TxEventPanicRecovered:
+		return "PanicRecovered"
+	default:
+		return fmt.Sprintf("TxEventType(%d)", t)
+	}
+}
+
+// TxEvent is a lifecycle event of a transaction managed by Transactor.
+type TxEvent struct {
+	// Type is the type of the event.
+	Type TxEventType
+	// TxID is the identifier of the top-level transaction (unique within the process).
+	// It is zero if the transaction was not started by a Transactor.
+	TxID uint64
+	// Depth is the nesting depth of the WithinTx call: 0 for the top-level transaction.
+	Depth int
+	// Duration is the duration of the operation (begin, commit or rollback) for the "End" events.
+	Duration time.Duration
+	// TxDuration is the time elapsed since the top-level transaction began.
+	TxDuration time.Duration
+	// Err is the error of the operation for the "End" events or the recovered panic for TxEventPanicRecovered.
+	Err error
+}
+
+type (
+	// TxObserver receives the lifecycle events of the transactions (see Transactor.WithObserver).
+	// OnTxEvent is called synchronously, so it should be fast and must not panic.
+	TxObserver interface {
+		OnTxEvent(ctx context.Context, event TxEvent)
+	}
+
+	// TxObserverFunc is an adapter to use an ordinary function as a TxObserver.
+	TxObserverFunc func(ctx context.Context, event TxEvent)
+)
+
+// OnTxEvent calls f(ctx, event).
+func (f TxObserverFunc) OnTxEvent(ctx context.Context, event TxEvent) {
+	f(ctx, event)
+}
+
+// WithObserver returns a new Transactor that sends the lifecycle events of its transactions to the observer.
+// A nil observer disables the events. The original Transactor is not modified.
+//
+// Example:
+//
+//	transactor = transactor.WithObserver(mtx.TxObserverFunc(func(ctx context.Context, e mtx.TxEvent) {
+//	    if e.Type == mtx.TxEventRollbackEnd && e.Err != nil {
+//	        alert(ctx, e.TxID, e.Err)
+//	    }
+//	}))
+func (t *Transactor[B, T]) WithObserver(observer TxObserver) *Transactor[B, T] {
+	c := *t
+	c.observer = observer
+	return &c
+}
+
+// observe sends the event to the observer (if any).
+// TxID, Depth and TxDuration are filled from the state and the context.
+func (t *Transactor[B, T]) observe(ctx context.Context, state *txState, event TxEvent) {
+	observeTx(ctx, t.observer, state, event)
+}
+
+// observed calls the operation between the "Start" and "End" events.
+func (t *Transactor[B, T]) observed(ctx context.Context, state *txState, start, end TxEventType, op func() error) error {
+	return observedTx(ctx, t.observer, state, start, end, op)
+}
+
+// observeTx sends the event to the observer (if any).
+// It is shared by Transactor, MultiTransactor and TwoPhaseCoordinator.
+func observeTx(ctx context.Context, observer TxObserver, state *txState, event TxEvent) {
+	if observer == nil {
+		return
+	}
+	event.Depth = extractDepth(ctx)
+	if state != nil {
+		event.TxID = state.id
+		event.TxDuration = time.Since(state.begun)
+	}
+	observer.OnTxEvent(ctx, event)
+}
+
+// observedTx calls the operation between the "Start" and "End" events.
+func observedTx(ctx context.Context, observer TxObserver, state *txState, start, end TxEventType, op func() error) error {
+	if observer == nil {
+		return op()
+	}
+	observeTx(ctx, observer, state, TxEvent{Type: start})
+	begun := time.Now()
+	err := op()
+	observeTx(ctx, observer, state, TxEvent{Type: end, Duration: time.Since(begun), Err: err})
+	return err
+}
+
+// txDepthKey is the context key of the nesting depth of the WithinTx call.
+type txDepthKey struct{}
+
+// injectDepth stores the nesting depth in the context.
+func injectDepth(ctx context.Context, depth int) context.Context {
+	return context.WithValue(ctx, txDepthKey{}, depth)
+}
+
+// extractDepth returns the nesting depth from the context (0 if absent).
+func extractDepth(ctx context.Context) int {
+	depth, _ := ctx.Value(txDepthKey{}).(int)
+	return depth
+}
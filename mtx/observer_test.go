@@ -0,0 +1,187 @@
+package mtx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+)
+
+func Test_Transactor_WithObserver(t *testing.T) {
+	type recorder struct {
+		events []TxEvent
+	}
+	var (
+		types = func(events []TxEvent) []TxEventType {
+			res := make([]TxEventType, 0, len(events))
+			for _, e := range events {
+				res = append(res, e.Type)
+			}
+			return res
+		}
+		depths = func(events []TxEvent) []int {
+			res := make([]int, 0, len(events))
+			for _, e := range events {
+				res = append(res, e.Depth)
+			}
+			return res
+		}
+		newObserver = func(r *recorder) TxObserver {
+			return TxObserverFunc(func(_ context.Context, e TxEvent) {
+				r.events = append(r.events, e)
+			})
+		}
+		newInstance = func(beginErr, commitErr, rollbackErr error) *Transactor[*beginnerMock[*committerMock], *committerMock] {
+			b := &beginnerMock[*committerMock]{
+				beginFn: func(context.Context) (*committerMock, error) {
+					return &committerMock{
+						commitFn: func(context.Context) error {
+							return commitErr
+						},
+						rollbackFn: func(context.Context) error {
+							return rollbackErr
+						},
+					}, beginErr
+				},
+			}
+			return NewTransactor[*beginnerMock[*committerMock], *committerMock](
+				b,
+				NewContextOperator[*beginnerMock[*committerMock], *committerMock](b),
+			)
+		}
+	)
+
+	t.Run("commit", func(t *testing.T) {
+		var (
+			r   recorder
+			ctx = context.Background()
+			tr  = newInstance(nil, nil, nil).WithObserver(newObserver(&r))
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			return tr.WithinTx(ctx, func(ctx context.Context) error {
+				return tr.WithinTx(ctx, func(context.Context) error {
+					return nil
+				})
+			})
+		})
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal(
+			[]TxEventType{TxEventBeginStart, TxEventBeginEnd, TxEventJoin, TxEventJoin, TxEventCommitStart, TxEventCommitEnd},
+			types(r.events),
+		))
+		assert.True(t, slices.Equal([]int{0, 0, 1, 2, 0, 0}, depths(r.events)))
+		for _, e := range r.events {
+			assert.Equal(t, r.events[0].TxID, e.TxID)
+			assert.NoError(t, e.Err)
+		}
+		assert.True(t, r.events[0].TxID != 0)
+		assert.True(t, r.events[5].TxDuration >= r.events[5].Duration)
+
+		// the next transaction has another identifier
+		assert.NoError(t, tr.WithinTx(ctx, func(context.Context) error {
+			return nil
+		}))
+		assert.True(t, r.events[0].TxID != r.events[len(r.events)-1].TxID)
+	})
+	t.Run("rollback_failed", func(t *testing.T) {
+		var (
+			r     recorder
+			ctx   = context.Background()
+			rbErr = fmt.Errorf("rollback error")
+			tr    = newInstance(nil, nil, rbErr).WithObserver(newObserver(&r))
+		)
+		err := tr.WithinTx(ctx, func(context.Context) error {
+			return fmt.Errorf("call error")
+		})
+		assert.ErrorIs(t, err, ErrRollbackFailed)
+		assert.True(t, slices.Equal(
+			[]TxEventType{TxEventBeginStart, TxEventBeginEnd, TxEventRollbackStart, TxEventRollbackEnd},
+			types(r.events),
+		))
+		assert.ErrorIs(t, r.events[3].Err, rbErr)
+	})
+	t.Run("panic", func(t *testing.T) {
+		var (
+			r   recorder
+			ctx = context.Background()
+			tr  = newInstance(nil, nil, nil).WithObserver(newObserver(&r))
+		)
+		err := tr.WithinTx(ctx, func(context.Context) error {
+			panic("observer panic")
+		})
+		assert.ErrorIs(t, err, ErrPanicRecovered)
+		assert.True(t, slices.Equal(
+			[]TxEventType{TxEventBeginStart, TxEventBeginEnd, TxEventPanicRecovered, TxEventRollbackStart, TxEventRollbackEnd},
+			types(r.events),
+		))
+		assert.Error(t, r.events[2].Err)
+		assert.NoError(t, r.events[4].Err)
+	})
+	t.Run("begin_and_commit_failed", func(t *testing.T) {
+		var (
+			r         recorder
+			ctx       = context.Background()
+			beginErr  = fmt.Errorf("begin error")
+			commitErr = fmt.Errorf("commit error")
+			fn        = func(context.Context) error {
+				return nil
+			}
+		)
+		err := newInstance(beginErr, nil, nil).WithObserver(newObserver(&r)).WithinTx(ctx, fn)
+		assert.ErrorIs(t, err, beginErr)
+		assert.True(t, slices.Equal([]TxEventType{TxEventBeginStart, TxEventBeginEnd}, types(r.events)))
+		assert.ErrorIs(t, r.events[1].Err, beginErr)
+
+		r.events = nil
+		err = newInstance(nil, commitErr, nil).WithObserver(newObserver(&r)).WithinTx(ctx, fn)
+		assert.ErrorIs(t, err, ErrCommitFailed)
+		assert.Equal(t, TxEventCommitEnd, r.events[3].Type)
+		assert.ErrorIs(t, r.events[3].Err, commitErr)
+	})
+	t.Run("savepoint_rollback", func(t *testing.T) {
+		var (
+			r   recorder
+			ctx = context.Background()
+			tx  = &savepointMock{
+				committerMock: committerMock{
+					commitFn: func(context.Context) error {
+						return nil
+					},
+				},
+				savepointFn: func(context.Context, string) error {
+					return nil
+				},
+				rollbackToFn: func(context.Context, string) error {
+					return nil
+				},
+			}
+			b = &beginnerMock[*savepointMock]{
+				beginFn: func(context.Context) (*savepointMock, error) {
+					return tx, nil
+				},
+			}
+			tr = NewTransactor[*beginnerMock[*savepointMock], *savepointMock](
+				b,
+				NewContextOperator[*beginnerMock[*savepointMock], *savepointMock](b),
+			).WithObserver(newObserver(&r))
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			_ = tr.WithinTx(ctx, func(context.Context) error {
+				return fmt.Errorf("nested error")
+			})
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal(
+			[]TxEventType{TxEventBeginStart, TxEventBeginEnd, TxEventJoin, TxEventRollbackStart, TxEventRollbackEnd, TxEventCommitStart, TxEventCommitEnd},
+			types(r.events),
+		))
+		assert.True(t, slices.Equal([]int{0, 0, 1, 1, 1, 0, 0}, depths(r.events)))
+	})
+	t.Run("event_type_string", func(t *testing.T) {
+		assert.Equal(t, "RollbackEnd", TxEventRollbackEnd.String())
+		assert.Equal(t, "TxEventType(100)", TxEventType(100).String())
+	})
+}
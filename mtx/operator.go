@@ -15,6 +15,9 @@ type ContextOperator[K comparable, T Tx] struct {
 	key K
 }
 
+// removedTx is stored in context.Context by ContextOperator.Remove instead of a transaction.
+type removedTx struct{}
+
 // NewContextOperator returns a pointer to a new ContextOperator instance.
 //
 // It accepts a key of a comparable type that will be used for both injecting
@@ -69,3 +72,11 @@ func (o *ContextOperator[K, T]) Extract(ctx context.Context) (T, bool) {
 	c, ok := ctx.Value(o.key).(T)
 	return c, ok
 }
+
+// Remove returns a new context in which Extract does not find a transaction.
+// The transaction stored in the parent context is not modified.
+//
+// This method is used by Transactor to execute functions with PropagationNotSupported.
+func (o *ContextOperator[K, T]) Remove(ctx context.Context) context.Context {
+	return context.WithValue(ctx, o.key, removedTx{})
+}
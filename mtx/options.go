@@ -0,0 +1,117 @@
+package mtx
+
+import (
+	"context"
+	"fmt"
+)
+
+// IsolationLevel is the transaction isolation level.
+// The values are equal to the corresponding [database/sql.IsolationLevel] values,
+// so adapters can convert them directly.
+type IsolationLevel int
+
+const (
+	// LevelDefault is the default isolation level of the database driver.
+	LevelDefault IsolationLevel = iota
+	LevelReadUncommitted
+	LevelReadCommitted
+	LevelWriteCommitted
+	LevelRepeatableRead
+	LevelSnapshot
+	LevelSerializable
+	LevelLinearizable
+)
+
+// String returns the name of the IsolationLevel.
+func (l IsolationLevel) String() string {
+	switch l {
+	case LevelDefault:
+		return "Default"
+	case LevelReadUncommitted:
+		return "Read Uncommitted"
+	case LevelReadCommitted:
+		return "Read Committed"
+	case LevelWriteCommitted:
+		return "Write Committed"
+	case LevelRepeatableRead:
+		return "Repeatable Read"
+	case LevelSnapshot:
+		return "Snapshot"
+	case LevelSerializable:
+		return "Serializable"
+	case LevelLinearizable:
+		return "Linearizable"
+	default:
+		return fmt.Sprintf("IsolationLevel(%d)", l)
+	}
+}
+
+// TxOptions holds the options used to begin a transaction.
+// The zero value means "driver defaults".
+type TxOptions struct {
+	Isolation  IsolationLevel
+	ReadOnly   bool
+	Deferrable bool
+}
+
+// TxOption configures TxOptions.
+type TxOption func(opts *TxOptions)
+
+// Isolation sets the transaction isolation level.
+func Isolation(level IsolationLevel) TxOption {
+	return func(opts *TxOptions) {
+		opts.Isolation = level
+	}
+}
+
+// ReadOnly marks the transaction as read-only.
+func ReadOnly() TxOption {
+	return func(opts *TxOptions) {
+		opts.ReadOnly = true
+	}
+}
+
+// Deferrable marks the transaction as deferrable
+// (for example, PostgreSQL SERIALIZABLE READ ONLY DEFERRABLE).
+func Deferrable() TxOption {
+	return func(opts *TxOptions) {
+		opts.Deferrable = true
+	}
+}
+
+// TxBeginnerWithOptions is an optional TxBeginner extension.
+// It is required when the Transactor is configured with non-default TxOptions (see Transactor.WithTxOptions).
+type TxBeginnerWithOptions[T Tx] interface {
+	BeginTxWithOptions(ctx context.Context, opts TxOptions) (T, error)
+}
+
+// IsolationLevelError is returned when a nested WithinTx call requires
+// a stricter isolation level than the isolation level of the active transaction.
+//
+// It wraps ErrIsolationLevel.
+type IsolationLevelError struct {
+	Active   IsolationLevel
+	Required IsolationLevel
+}
+
+// Error returns the error message.
+func (e *IsolationLevelError) Error() string {
+	return fmt.Sprintf("%v: required [%s], active [%s]", ErrIsolationLevel, e.Required, e.Active)
+}
+
+// Unwrap returns ErrIsolationLevel.
+func (e *IsolationLevelError) Unwrap() error {
+	return ErrIsolationLevel
+}
+
+// checkIsolation returns IsolationLevelError if the required isolation level is stricter than the active one.
+// The LevelDefault of the active transaction is unknown, so it satisfies only LevelDefault.
+func checkIsolation(active, required IsolationLevel) error {
+	if required == LevelDefault || required <= active {
+		return nil
+	}
+	return &IsolationLevelError{
+		Active:   active,
+		Required: required,
+	}
+}
@@ -0,0 +1,63 @@
+package mtx
+
+import (
+	"fmt"
+
+	"github.com/kozmod/oniontx/internal/errors"
+)
+
+// PanicError is the error created from a panic recovered by Transactor, MultiTransactor, TwoPhaseCoordinator
+// or a callback (see AfterCommit). It is wrapped by the returned error together with ErrPanicRecovered.
+// PanicError.Value returns the original panic value and PanicError.Stack returns the stack trace captured at the recovery:
+//
+//	var pErr *mtx.PanicError
+//	if errors.As(err, &pErr) {
+//	    log.Printf("panic: %v\n%s", pErr.Value(), pErr.Stack())
+//	}
+type PanicError = errors.PanicError
+
+// PanicPolicy defines how Transactor.WithinTx handles a panic of the function.
+type PanicPolicy uint8
+
+const (
+	// PanicPolicyRecover recovers the panic, rolls back the transaction (or the savepoint)
+	// and returns an error with ErrPanicRecovered and PanicError (default).
+	PanicPolicyRecover PanicPolicy = iota
+
+	// PanicPolicyRollbackRepanic rolls back the transaction (or the savepoint),
+	// calls the after-rollback callbacks and re-panics with the original panic value.
+	PanicPolicyRollbackRepanic
+
+	// PanicPolicyRepanic re-panics with the original panic value without the rollback.
+	// The transaction stays open until the connection is closed,
+	// so the policy suits the applications that crash on a panic.
+	PanicPolicyRepanic
+)
+
+// String returns the name of the PanicPolicy.
+func (p PanicPolicy) String() string {
+	switch p {
+	case PanicPolicyRecover:
+		return "Recover"
+	case PanicPolicyRollbackRepanic:
+		return "RollbackRepanic"
+	case PanicPolicyRepanic:
+		return "Repanic"
+	default:
+		return fmt.Sprintf("PanicPolicy(%d)", p)
+	}
+}
+
+// WithPanicPolicy returns a new Transactor that handles the panics of the function according to the policy.
+// The original Transactor is not modified.
+//
+// The policy is applied at the level of the call: a nested call of a Transactor with PanicPolicyRecover
+// converts the panic to an error, which is returned to the outer function as usual.
+//
+//	// rolls back the transaction and crashes with the original panic.
+//	transactor = transactor.WithPanicPolicy(mtx.PanicPolicyRollbackRepanic)
+func (t *Transactor[B, T]) WithPanicPolicy(policy PanicPolicy) *Transactor[B, T] {
+	c := *t
+	c.panicPolicy = policy
+	return &c
+}
@@ -0,0 +1,152 @@
+package mtx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+)
+
+func Test_Transactor_WithPanicPolicy(t *testing.T) {
+	type calls struct {
+		commit, rollback, rollbackTo, afterRollback int
+	}
+	newInstance := func(c *calls) *Transactor[*beginnerMock[*savepointMock], *savepointMock] {
+		b := &beginnerMock[*savepointMock]{
+			beginFn: func(context.Context) (*savepointMock, error) {
+				return &savepointMock{
+					committerMock: committerMock{
+						commitFn: func(context.Context) error {
+							c.commit++
+							return nil
+						},
+						rollbackFn: func(context.Context) error {
+							c.rollback++
+							return nil
+						},
+					},
+					savepointFn: func(context.Context, string) error {
+						return nil
+					},
+					rollbackToFn: func(context.Context, string) error {
+						c.rollbackTo++
+						return nil
+					},
+					releaseFn: func(context.Context, string) error {
+						return nil
+					},
+				}, nil
+			},
+		}
+		return NewTransactor[*beginnerMock[*savepointMock], *savepointMock](
+			b,
+			NewContextOperator[*beginnerMock[*savepointMock], *savepointMock](b),
+		)
+	}
+	recovered := func(fn func()) (p any) {
+		defer func() {
+			p = recover()
+		}()
+		fn()
+		return nil
+	}
+	panicFn := func(c *calls) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			_ = AfterRollback(ctx, func(context.Context) error {
+				c.afterRollback++
+				return nil
+			})
+			panic("tx panic")
+		}
+	}
+
+	t.Run("recover", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+		)
+		err := newInstance(&c).WithinTx(ctx, panicFn(&c))
+		assert.ErrorIs(t, err, ErrPanicRecovered)
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.Equal(t, 1, c.rollback)
+		assert.Equal(t, 1, c.afterRollback)
+
+		var pErr *PanicError
+		assert.True(t, errors.As(err, &pErr))
+		assert.Equal[any](t, "tx panic", pErr.Value())
+		assert.True(t, strings.Contains(string(pErr.Stack()), "Test_Transactor_WithPanicPolicy"))
+	})
+	t.Run("rollback_repanic", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+			tr  = newInstance(&c).WithPanicPolicy(PanicPolicyRollbackRepanic)
+		)
+		p := recovered(func() {
+			_ = tr.WithinTx(ctx, panicFn(&c))
+		})
+		assert.Equal[any](t, "tx panic", p)
+		assert.Equal(t, 0, c.commit)
+		assert.Equal(t, 1, c.rollback)
+		assert.Equal(t, 1, c.afterRollback)
+	})
+	t.Run("repanic", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+			tr  = newInstance(&c).WithPanicPolicy(PanicPolicyRepanic)
+		)
+		p := recovered(func() {
+			_ = tr.WithinTx(ctx, panicFn(&c))
+		})
+		assert.Equal[any](t, "tx panic", p)
+		assert.Equal(t, 0, c.commit)
+		assert.Equal(t, 0, c.rollback)
+		assert.Equal(t, 0, c.afterRollback)
+	})
+	t.Run("nested_savepoint_rollback_repanic", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+			tr  = newInstance(&c)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			return tr.WithPanicPolicy(PanicPolicyRollbackRepanic).WithinTx(ctx, func(context.Context) error {
+				panic("nested panic")
+			})
+		})
+		// the panic of the nested call is recovered by the top-level call.
+		assert.ErrorIs(t, err, ErrPanicRecovered)
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.Equal(t, 1, c.rollbackTo)
+		assert.Equal(t, 1, c.rollback)
+
+		var txErr *TxError
+		assert.True(t, errors.As(err, &txErr))
+		assert.Equal(t, 0, txErr.Depth)
+		assert.Equal[any](t, "nested panic", txErr.Panic)
+	})
+	t.Run("nested_repanic", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+			tr  = newInstance(&c).WithPanicPolicy(PanicPolicyRepanic)
+		)
+		p := recovered(func() {
+			_ = tr.WithinTx(ctx, func(ctx context.Context) error {
+				return tr.WithinTx(ctx, func(context.Context) error {
+					panic("nested panic")
+				})
+			})
+		})
+		assert.Equal[any](t, "nested panic", p)
+		assert.Equal(t, 0, c.rollbackTo)
+		assert.Equal(t, 0, c.rollback)
+	})
+	t.Run("string", func(t *testing.T) {
+		assert.Equal(t, "RollbackRepanic", PanicPolicyRollbackRepanic.String())
+		assert.Equal(t, "PanicPolicy(10)", PanicPolicy(10).String())
+	})
+}
@@ -0,0 +1,58 @@
+package mtx
+
+import "fmt"
+
+// Propagation defines how Transactor.WithinTx behaves depending on
+// whether a transaction already exists in the context.
+// A function executed without a transaction does not see the transactions of other TxBeginners
+// through the package level functions (AfterCommit, BeforeCommit, etc. return ErrTxNotFound).
+type Propagation uint8
+
+const (
+	// PropagationRequired reuses the transaction from the context
+	// or begins a new one (default).
+	PropagationRequired Propagation = iota
+
+	// PropagationRequiresNew suspends the transaction from the context (if any)
+	// and begins a new independent transaction.
+	// The new transaction is committed or rolled back when the function returns,
+	// independently of the suspended one.
+	PropagationRequiresNew
+
+	// PropagationMandatory reuses the transaction from the context
+	// and returns ErrTxNotFound if there is none.
+	PropagationMandatory
+
+	// PropagationNever executes the function without a transaction
+	// and returns ErrTxExists if a transaction exists in the context.
+	PropagationNever
+
+	// PropagationSupports reuses the transaction from the context (if any),
+	// otherwise executes the function without a transaction.
+	PropagationSupports
+
+	// PropagationNotSupported executes the function without a transaction.
+	// The transaction from the context (if any) is removed from the context
+	// passed to the function, so the CtxOperator must implement CtxRemover.
+	PropagationNotSupported
+)
+
+// String returns the name of the Propagation.
+func (p Propagation) String() string {
+	switch p {
+	case PropagationRequired:
+		return "Required"
+	case PropagationRequiresNew:
+		return "RequiresNew"
+	case PropagationMandatory:
+		return "Mandatory"
+	case PropagationNever:
+		return "Never"
+	case PropagationSupports:
+		return "Supports"
+	case PropagationNotSupported:
+		return "NotSupported"
+	default:
+		return fmt.Sprintf("Propagation(%d)", p)
+	}
+}
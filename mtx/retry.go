@@ -0,0 +1,125 @@
+package mtx
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/kozmod/oniontx/internal/errors"
+)
+
+var (
+	// ErrRetryFailed indicates that all retry attempts have been exhausted without success.
+	ErrRetryFailed = fmt.Errorf("retry failed")
+
+	// ErrRetryContextDone indicates that the context was cancelled or timed out
+	// before a retry attempt or while waiting between attempts.
+	ErrRetryContextDone = fmt.Errorf("retry context done")
+)
+
+type (
+	// RetryPolicy defines the number of retry attempts after the initial call
+	// and the delay before each retry attempt.
+	//
+	// The interface is equal to the saga.RetryPolicy, so the saga retry policies
+	// (with backoff and jitter) can be used with Transactor.WithRetry.
+	RetryPolicy interface {
+		Attempts() uint32
+		Delay(attempt uint32) time.Duration
+	}
+
+	// RetryClassifier reports whether the transaction should be re-executed after the error.
+	// The error contains the whole chain returned by the top-level transaction
+	// (including ErrRollbackSuccess, ErrCommitFailed, etc.), so errors.Is and errors.As
+	// can be used to check the driver's error.
+	RetryClassifier func(err error) bool
+)
+
+// RetryOn returns a RetryClassifier that reports errors matching any of targets (errors.Is) as retryable.
+func RetryOn(targets ...error) RetryClassifier {
+	return func(err error) bool {
+		for _, target := range targets {
+			if stderrors.Is(err, target) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RetryError is returned by Transactor.WithinTx when a transaction configured
+// by Transactor.WithRetry fails.
+type RetryError struct {
+	// Attempts is the number of the transaction executions (including the initial one).
+	Attempts uint32
+	// Err is the error of the last execution.
+	// It wraps ErrRetryFailed if all retry attempts were exhausted.
+	Err error
+}
+
+// Error returns the error message.
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("retry - attempts [%d]: %v", e.Attempts, e.Err)
+}
+
+// Unwrap returns the error of the last execution.
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// retrier re-executes a function according to the RetryPolicy.
+type retrier struct {
+	policy     RetryPolicy
+	classifier RetryClassifier
+}
+
+// do makes the initial call of exec and then up to policy.Attempts() retry calls
+// while exec returns a retryable error. Reports whether the last execution committed the transaction.
+// The committed transaction is never re-executed, even if an after-commit callback fails,
+// since the classifier sees the whole error chain.
+func (r *retrier) do(
+	ctx context.Context,
+	exec func(ctx context.Context, fn func(ctx context.Context) error) (committed bool, err error),
+	fn func(ctx context.Context) error,
+) (bool, error) {
+	var (
+		maxAttempts = r.policy.Attempts()
+		attempts    uint32
+	)
+	for {
+		committed, err := exec(ctx, fn)
+		attempts++
+		switch {
+		case err == nil:
+			return committed, nil
+		case committed || !r.classifier(err):
+			return committed, &RetryError{Attempts: attempts, Err: err}
+		case attempts > maxAttempts:
+			return false, &RetryError{Attempts: attempts, Err: errors.Join(ErrRetryFailed, err)}
+		}
+
+		if waitErr := waitRetryDelay(ctx, r.policy.Delay(attempts-1)); waitErr != nil {
+			return false, &RetryError{Attempts: attempts, Err: errors.Join(ErrRetryContextDone, waitErr, err)}
+		}
+	}
+}
+
+func waitRetryDelay(ctx context.Context, delay time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,213 @@
+package mtx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+)
+
+// retryPolicyMock was added to avoid to use external dependencies for mocking.
+type retryPolicyMock struct {
+	attempts uint32
+	delay    time.Duration
+	calls    []uint32
+}
+
+func (p *retryPolicyMock) Attempts() uint32 {
+	return p.attempts
+}
+
+func (p *retryPolicyMock) Delay(attempt uint32) time.Duration {
+	p.calls = append(p.calls, attempt)
+	return p.delay
+}
+
+func Test_Transactor_WithRetry(t *testing.T) {
+	var (
+		errRetryable    = fmt.Errorf("serialization failure")
+		errNonRetryable = fmt.Errorf("business error")
+	)
+
+	type calls struct {
+		begin, commit, rollback int
+	}
+	newInstance := func(c *calls, commitErrs ...error) *Transactor[*beginnerMock[*committerMock], *committerMock] {
+		var (
+			b = &beginnerMock[*committerMock]{
+				beginFn: func(context.Context) (*committerMock, error) {
+					c.begin++
+					return &committerMock{
+						commitFn: func(context.Context) error {
+							c.commit++
+							if len(commitErrs) >= c.commit {
+								return commitErrs[c.commit-1]
+							}
+							return nil
+						},
+						rollbackFn: func(context.Context) error {
+							c.rollback++
+							return nil
+						},
+					}, nil
+				},
+			}
+			o = NewContextOperator[*beginnerMock[*committerMock], *committerMock](b)
+		)
+		return NewTransactor[*beginnerMock[*committerMock], *committerMock](b, o)
+	}
+
+	t.Run("success_after_retries", func(t *testing.T) {
+		var (
+			c      calls
+			ctx    = context.Background()
+			policy = &retryPolicyMock{attempts: 3}
+			tr     = newInstance(&c).WithRetry(policy, RetryOn(errRetryable))
+			execs  int
+		)
+		err := tr.WithinTx(ctx, func(context.Context) error {
+			execs++
+			if execs < 3 {
+				return errRetryable
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, execs)
+		assert.Equal(t, 3, c.begin)
+		assert.Equal(t, 2, c.rollback)
+		assert.Equal(t, 1, c.commit)
+		assert.True(t, slices.Equal([]uint32{0, 1}, policy.calls))
+	})
+	t.Run("retry_failed_commit", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+			tr  = newInstance(&c, errRetryable).WithRetry(&retryPolicyMock{attempts: 1}, RetryOn(errRetryable))
+		)
+		err := tr.WithinTx(ctx, func(context.Context) error {
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, c.begin)
+		assert.Equal(t, 2, c.commit)
+	})
+	t.Run("error_when_attempts_exhausted", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+			tr  = newInstance(&c).WithRetry(&retryPolicyMock{attempts: 2}, RetryOn(errRetryable))
+		)
+		err := tr.WithinTx(ctx, func(context.Context) error {
+			return errRetryable
+		})
+		var retryErr *RetryError
+		assert.True(t, errors.As(err, &retryErr))
+		assert.Equal(t, 3, retryErr.Attempts)
+		assert.ErrorIs(t, err, ErrRetryFailed)
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.ErrorIs(t, err, errRetryable)
+		assert.Equal(t, 3, c.begin)
+		assert.Equal(t, 3, c.rollback)
+	})
+	t.Run("non_retryable_error", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+			tr  = newInstance(&c).WithRetry(&retryPolicyMock{attempts: 2}, RetryOn(errRetryable))
+		)
+		err := tr.WithinTx(ctx, func(context.Context) error {
+			return errNonRetryable
+		})
+		var retryErr *RetryError
+		assert.True(t, errors.As(err, &retryErr))
+		assert.Equal(t, 1, retryErr.Attempts)
+		assert.ErrorIsNot(t, err, ErrRetryFailed)
+		assert.ErrorIs(t, err, errNonRetryable)
+		assert.Equal(t, 1, c.begin)
+	})
+	t.Run("committed_tx_is_not_retried", func(t *testing.T) {
+		var (
+			c     calls
+			ctx   = context.Background()
+			tr    = newInstance(&c).WithRetry(&retryPolicyMock{attempts: 3}, RetryOn(errRetryable))
+			execs int
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			execs++
+			return AfterCommit(ctx, func(context.Context) error {
+				return errRetryable
+			})
+		})
+		var retryErr *RetryError
+		assert.True(t, errors.As(err, &retryErr))
+		assert.Equal(t, 1, retryErr.Attempts)
+		assert.ErrorIs(t, err, ErrAfterCommitFailed)
+		assert.ErrorIs(t, err, errRetryable)
+		assert.Equal(t, 1, execs)
+		assert.Equal(t, 1, c.begin)
+		assert.Equal(t, 1, c.commit)
+	})
+	t.Run("nested_call_is_not_retried", func(t *testing.T) {
+		var (
+			c           calls
+			ctx         = context.Background()
+			tr          = newInstance(&c).WithRetry(&retryPolicyMock{attempts: 2}, RetryOn(errRetryable))
+			topExecs    int
+			nestedExecs int
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			topExecs++
+			err := tr.WithinTx(ctx, func(context.Context) error {
+				nestedExecs++
+				return errRetryable
+			})
+			var retryErr *RetryError
+			assert.False(t, errors.As(err, &retryErr))
+			return err
+		})
+		assert.ErrorIs(t, err, ErrRetryFailed)
+		assert.Equal(t, 3, topExecs)
+		assert.Equal(t, 3, nestedExecs)
+		assert.Equal(t, 3, c.begin)
+	})
+	t.Run("context_done_while_waiting", func(t *testing.T) {
+		var (
+			c           calls
+			ctx, cancel = context.WithCancel(context.Background())
+			tr          = newInstance(&c).WithRetry(&retryPolicyMock{attempts: 2, delay: time.Hour}, RetryOn(errRetryable))
+		)
+		err := tr.WithinTx(ctx, func(context.Context) error {
+			cancel()
+			return errRetryable
+		})
+		assert.ErrorIs(t, err, ErrRetryContextDone)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorIs(t, err, errRetryable)
+		assert.Equal(t, 1, c.begin)
+	})
+	t.Run("disabled_with_nil_policy_or_classifier", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+			tr  = newInstance(&c).WithRetry(&retryPolicyMock{attempts: 2}, RetryOn(errRetryable))
+		)
+		for _, disabled := range []*Transactor[*beginnerMock[*committerMock], *committerMock]{
+			tr.WithRetry(nil, RetryOn(errRetryable)),
+			tr.WithRetry(&retryPolicyMock{attempts: 2}, nil),
+		} {
+			c = calls{}
+			err := disabled.WithinTx(ctx, func(context.Context) error {
+				return errRetryable
+			})
+			var retryErr *RetryError
+			assert.False(t, errors.As(err, &retryErr))
+			assert.ErrorIs(t, err, errRetryable)
+			assert.Equal(t, 1, c.begin)
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package mtx
+
+import (
+	"context"
+	"fmt"
+)
+
+// ErrRollbackOnly indicates that the top-level transaction was marked as rollback-only
+// (see SetRollbackOnly) and was rolled back instead of being committed.
+var ErrRollbackOnly = fmt.Errorf("tx is rollback-only")
+
+// SetRollbackOnly marks the transaction from the context as rollback-only,
+// so it is rolled back instead of being committed. It can be called from any (top-level or nested) WithinTx call
+// and marks the transaction begun by the top-level call (or by the call with PropagationRequiresNew).
+//
+// The function continues its execution, the remaining before-commit hooks are not called
+// and the after-rollback callbacks are called after the rollback.
+// The top-level WithinTx returns an error wrapped with ErrRollbackSuccess and ErrRollbackOnly
+// or nil if the top-level Transactor is configured by WithSilentRollbackOnly.
+// The mark is not removed when a nested call is rolled back to its savepoint.
+//
+// SetRollbackOnly returns ErrTxNotFound if the context does not contain a transaction managed by a Transactor.
+//
+// Example (dry-run):
+//
+//	err := transactor.WithSilentRollbackOnly(true).WithinTx(ctx, func(ctx context.Context) error {
+//	    if err := useCase.Import(ctx, rows); err != nil {
+//	        return err
+//	    }
+//	    if dryRun {
+//	        return mtx.SetRollbackOnly(ctx)
+//	    }
+//	    return nil
+//	})
+func SetRollbackOnly(ctx context.Context) error {
+	state, ok := extractCurrentState(ctx)
+	if !ok {
+		return fmt.Errorf("set rollback only: %w", ErrTxNotFound)
+	}
+	state.setRollbackOnly()
+	return nil
+}
+
+// IsRollbackOnly reports whether the transaction from the context is marked as rollback-only.
+func IsRollbackOnly(ctx context.Context) bool {
+	state, ok := extractCurrentState(ctx)
+	return ok && state.isRollbackOnly()
+}
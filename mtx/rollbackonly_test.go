@@ -0,0 +1,209 @@
+package mtx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+)
+
+func Test_SetRollbackOnly(t *testing.T) {
+	type calls struct {
+		commit, rollback int
+	}
+	newInstance := func(c *calls) *Transactor[*beginnerMock[*committerMock], *committerMock] {
+		b := &beginnerMock[*committerMock]{
+			beginFn: func(context.Context) (*committerMock, error) {
+				return &committerMock{
+					commitFn: func(context.Context) error {
+						c.commit++
+						return nil
+					},
+					rollbackFn: func(context.Context) error {
+						c.rollback++
+						return nil
+					},
+				}, nil
+			},
+		}
+		return NewTransactor[*beginnerMock[*committerMock], *committerMock](
+			b,
+			NewContextOperator[*beginnerMock[*committerMock], *committerMock](b),
+		)
+	}
+
+	t.Run("nested_mark", func(t *testing.T) {
+		var (
+			c      calls
+			ctx    = context.Background()
+			tr     = newInstance(&c)
+			called []string
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			assert.False(t, IsRollbackOnly(ctx))
+			err := tr.WithinTx(ctx, func(ctx context.Context) error {
+				return SetRollbackOnly(ctx)
+			})
+			assert.NoError(t, err)
+			assert.True(t, IsRollbackOnly(ctx))
+			_ = AfterCommit(ctx, func(context.Context) error {
+				t.Fatalf("should not have been called")
+				return nil
+			})
+			return AfterRollback(ctx, func(context.Context) error {
+				called = append(called, "after_rollback")
+				return nil
+			})
+		})
+		assert.ErrorIs(t, err, ErrRollbackOnly)
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.Equal(t, 0, c.commit)
+		assert.Equal(t, 1, c.rollback)
+		assert.True(t, slices.Equal([]string{"after_rollback"}, called))
+	})
+	t.Run("silent", func(t *testing.T) {
+		var (
+			c      calls
+			ctx    = context.Background()
+			tr     = newInstance(&c).WithSilentRollbackOnly(true)
+			called int
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			_ = AfterRollback(ctx, func(context.Context) error {
+				called++
+				return nil
+			})
+			return SetRollbackOnly(ctx)
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, c.commit)
+		assert.Equal(t, 1, c.rollback)
+		assert.Equal(t, 1, called)
+	})
+	t.Run("silent_does_not_hide_errors", func(t *testing.T) {
+		var (
+			c      calls
+			ctx    = context.Background()
+			tr     = newInstance(&c).WithSilentRollbackOnly(true)
+			expErr = fmt.Errorf("call error")
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			assert.NoError(t, SetRollbackOnly(ctx))
+			return expErr
+		})
+		assert.ErrorIs(t, err, expErr)
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.Equal(t, 1, c.rollback)
+	})
+	t.Run("skips_before_commit", func(t *testing.T) {
+		var (
+			c      calls
+			ctx    = context.Background()
+			tr     = newInstance(&c)
+			called []string
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			_ = BeforeCommit(ctx, func(ctx context.Context) error {
+				called = append(called, "first")
+				return SetRollbackOnly(ctx)
+			})
+			return BeforeCommit(ctx, func(context.Context) error {
+				called = append(called, "second")
+				return nil
+			})
+		})
+		assert.ErrorIs(t, err, ErrRollbackOnly)
+		assert.Equal(t, 0, c.commit)
+		assert.Equal(t, 1, c.rollback)
+		assert.True(t, slices.Equal([]string{"first"}, called))
+	})
+	t.Run("savepoint_rollback_keeps_mark", func(t *testing.T) {
+		var (
+			ctx      = context.Background()
+			rollback int
+			tx       = &savepointMock{
+				committerMock: committerMock{
+					commitFn: func(context.Context) error {
+						t.Fatalf("should not have been called")
+						return nil
+					},
+					rollbackFn: func(context.Context) error {
+						rollback++
+						return nil
+					},
+				},
+				savepointFn: func(context.Context, string) error {
+					return nil
+				},
+				rollbackToFn: func(context.Context, string) error {
+					return nil
+				},
+				releaseFn: func(context.Context, string) error {
+					return nil
+				},
+			}
+			b = &beginnerMock[*savepointMock]{
+				beginFn: func(context.Context) (*savepointMock, error) {
+					return tx, nil
+				},
+			}
+			tr = NewTransactor[*beginnerMock[*savepointMock], *savepointMock](
+				b,
+				NewContextOperator[*beginnerMock[*savepointMock], *savepointMock](b),
+			)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			err := tr.WithinTx(ctx, func(ctx context.Context) error {
+				assert.NoError(t, SetRollbackOnly(ctx))
+				return fmt.Errorf("nested error")
+			})
+			assert.ErrorIs(t, err, ErrRollbackToSavepointSuccess)
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrRollbackOnly)
+		assert.Equal(t, 1, rollback)
+	})
+	t.Run("requires_new", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+			tr  = newInstance(&c)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			err := tr.WithPropagation(PropagationRequiresNew).WithinTx(ctx, func(ctx context.Context) error {
+				return SetRollbackOnly(ctx)
+			})
+			assert.ErrorIs(t, err, ErrRollbackOnly)
+			assert.False(t, IsRollbackOnly(ctx))
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, c.commit)
+		assert.Equal(t, 1, c.rollback)
+	})
+	t.Run("tx_not_found", func(t *testing.T) {
+		ctx := context.Background()
+		assert.ErrorIs(t, SetRollbackOnly(ctx), ErrTxNotFound)
+		assert.False(t, IsRollbackOnly(ctx))
+	})
+	t.Run("multi_transactor", func(t *testing.T) {
+		var (
+			ca, cb calls
+			ctx    = context.Background()
+			a, b   = newInstance(&ca), newInstance(&cb)
+			multi  = NewMultiTransactor().WithResource("a", a).WithResource("b", b)
+		)
+		err := multi.WithinTx(ctx, func(ctx context.Context) error {
+			return b.WithinTx(ctx, func(ctx context.Context) error {
+				return SetRollbackOnly(ctx)
+			})
+		})
+		assert.ErrorIs(t, err, ErrRollbackOnly)
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.Equal(t, 0, ca.commit+cb.commit)
+		assert.Equal(t, 1, ca.rollback)
+		assert.Equal(t, 1, cb.rollback)
+	})
+}
@@ -0,0 +1,309 @@
+package mtx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrNilPrimary indicates that Router was created without a primary Transactor.
+	ErrNilPrimary = fmt.Errorf("primary transactor is nil")
+
+	// ErrReplicaTxWrite indicates that a read-write call of Router was nested
+	// into a read-only transaction of a replica.
+	ErrReplicaTxWrite = fmt.Errorf("write within replica tx")
+)
+
+// ReplicaSelection defines how Router chooses a replica for a read-only transaction.
+type ReplicaSelection uint8
+
+const (
+	// ReplicaRoundRobin chooses the replicas in turn (default).
+	ReplicaRoundRobin ReplicaSelection = iota
+	// ReplicaLeastLoaded chooses the replica with the least number of active transactions of the Router.
+	ReplicaLeastLoaded
+)
+
+// String returns the name of the ReplicaSelection.
+func (s ReplicaSelection) String() string {
+	switch s {
+	case ReplicaRoundRobin:
+		return "RoundRobin"
+	case ReplicaLeastLoaded:
+		return "LeastLoaded"
+	default:
+		return fmt.Sprintf("ReplicaSelection(%d)", s)
+	}
+}
+
+// Router routes the transactions between the primary and the read replicas.
+// The primary and the replicas are Transactor instances with different TxBeginners
+// (for example, *sql.DB of the primary and of each replica). The Transactors can share a CtxOperator
+// or have their own ones (like the adapter transactors): Router.TryGetTx checks the primary and each replica,
+// so the repositories get the transaction from the context regardless of the chosen database.
+//
+// Routing rules:
+//   - a call with the ReadOnly option (see WithTxOptions) begins a transaction on a replica
+//     chosen according to the ReplicaSelection (see WithReplicaSelection);
+//   - other calls begin a transaction on the primary;
+//   - a nested call stays on the database of the transaction from the context:
+//     a nested call within a primary transaction stays on the primary
+//     and a read-write call within a replica transaction returns ErrReplicaTxWrite;
+//   - read-your-writes: a read-only call is routed to the primary during the stickiness window
+//     after a commit on the primary within the same session (see WithStickiness, WithRouterSession, WithSessionKey).
+//
+// The Propagation, the retry and the other options of the primary and the replicas are applied
+// by the corresponding Transactor. The TxOptions of the Router are applied over the TxOptions of the chosen Transactor
+// (for example, a primary with LevelSerializable keeps the isolation level),
+// so the TxBeginners must implement TxBeginnerWithOptions to begin read-only transactions.
+type Router[B TxBeginner[T], T Tx] struct {
+	primary    *Transactor[B, T]
+	replicas   []*Transactor[B, T]
+	txOptions  TxOptions
+	txOpts     []TxOption
+	selection  ReplicaSelection
+	stickiness time.Duration
+	sessionKey func(ctx context.Context) (string, bool)
+	stats      *routerStats
+	now        func() time.Time
+}
+
+// routerStats holds the state shared by the copies of Router.
+type routerStats struct {
+	next   atomic.Uint64
+	active []atomic.Int64
+
+	mu          sync.Mutex
+	commits     map[string]time.Time
+	nextCleanup time.Time
+}
+
+// NewRouter returns a new Router.
+func NewRouter[B TxBeginner[T], T Tx](primary *Transactor[B, T], replicas ...*Transactor[B, T]) *Router[B, T] {
+	return &Router[B, T]{
+		primary:  primary,
+		replicas: replicas,
+		stats: &routerStats{
+			active:  make([]atomic.Int64, len(replicas)),
+			commits: make(map[string]time.Time),
+		},
+		now: time.Now,
+	}
+}
+
+// WithTxOptions returns a new Router that begins transactions with the given TxOptions.
+// The original Router is not modified.
+// The ReadOnly option routes the transactions to the replicas.
+//
+//	reader := router.WithTxOptions(mtx.ReadOnly())
+func (r *Router[B, T]) WithTxOptions(opts ...TxOption) *Router[B, T] {
+	c := *r
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&c.txOptions)
+		}
+	}
+	c.txOpts = append(slices.Clip(r.txOpts), opts...)
+	return &c
+}
+
+// WithReplicaSelection returns a new Router that chooses the replicas according to the selection.
+// The original Router is not modified.
+func (r *Router[B, T]) WithReplicaSelection(selection ReplicaSelection) *Router[B, T] {
+	c := *r
+	c.selection = selection
+	return &c
+}
+
+// WithStickiness returns a new Router that routes the read-only calls to the primary
+// during the window after a commit on the primary within the same session (read-your-writes).
+// The session is defined by the context (see WithRouterSession) or by the session key (see WithSessionKey).
+// The original Router is not modified. A non-positive window disables the stickiness.
+func (r *Router[B, T]) WithStickiness(window time.Duration) *Router[B, T] {
+	c := *r
+	c.stickiness = window
+	return &c
+}
+
+// WithSessionKey returns a new Router that identifies the read-your-writes session
+// by the key returned by fn (for example, the user identifier), see WithStickiness.
+// The original Router is not modified.
+// The commits of the keys are shared by all copies of the Router.
+func (r *Router[B, T]) WithSessionKey(fn func(ctx context.Context) (string, bool)) *Router[B, T] {
+	c := *r
+	c.sessionKey = fn
+	return &c
+}
+
+// WithinTx executes the function within a transaction of the primary or of a replica (see Router).
+func (r *Router[B, T]) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if r == nil {
+		return fmt.Errorf("router is nil")
+	}
+	if r.primary == nil {
+		return fmt.Errorf("router - can't route: %w", ErrNilPrimary)
+	}
+
+	if active, ok := r.active(ctx); ok {
+		// the nested call stays on the database of the active transaction.
+		if active != r.primary && !r.txOptions.ReadOnly {
+			return fmt.Errorf("router - nested: %w", ErrReplicaTxWrite)
+		}
+		return r.configure(active).WithinTx(ctx, fn)
+	}
+
+	if r.txOptions.ReadOnly && len(r.replicas) > 0 && !r.sticky(ctx) {
+		i := r.replica()
+		r.stats.active[i].Add(1)
+		defer r.stats.active[i].Add(-1)
+		return r.configure(r.replicas[i]).WithinTx(ctx, fn)
+	}
+
+	// the commit is recorded even if an after-commit callback fails.
+	ctx, recorder := withCommitRecorder(ctx)
+	err := r.configure(r.primary).WithinTx(ctx, fn)
+	if recorder.isCommitted() && !r.txOptions.ReadOnly {
+		r.recordCommit(ctx)
+	}
+	return err
+}
+
+// TryGetTx attempts to retrieve a transaction (of the primary or of a replica) from the given context.
+func (r *Router[B, T]) TryGetTx(ctx context.Context) (T, bool) {
+	if active, ok := r.active(ctx); ok {
+		return active.TryGetTx(ctx)
+	}
+	var tx T
+	return tx, false
+}
+
+// active returns the Transactor (the primary or a replica) of the transaction from the context.
+// A replica is identified by both its CtxOperator and its txState,
+// so the Transactors can share the CtxOperator.
+func (r *Router[B, T]) active(ctx context.Context) (*Transactor[B, T], bool) {
+	for _, replica := range r.replicas {
+		if replica == nil || replica.operator == nil {
+			continue
+		}
+		if _, ok := replica.operator.Extract(ctx); !ok {
+			continue
+		}
+		if _, ok := extractState(ctx, replica.beginner); ok {
+			return replica, true
+		}
+	}
+	if r.primary == nil || r.primary.operator == nil {
+		return nil, false
+	}
+	if _, ok := r.primary.operator.Extract(ctx); !ok {
+		return nil, false
+	}
+	return r.primary, true
+}
+
+// configure returns a copy of the Transactor with the TxOptions of the Router applied over its own TxOptions.
+func (r *Router[B, T]) configure(t *Transactor[B, T]) *Transactor[B, T] {
+	if t == nil {
+		return nil
+	}
+	return t.WithTxOptions(r.txOpts...)
+}
+
+// replica returns the index of the replica for a new read-only transaction.
+func (r *Router[B, T]) replica() int {
+	if r.selection == ReplicaLeastLoaded {
+		var (
+			index     = 0
+			minActive = r.stats.active[0].Load()
+		)
+		for i := 1; i < len(r.stats.active); i++ {
+			if active := r.stats.active[i].Load(); active < minActive {
+				index, minActive = i, active
+			}
+		}
+		return index
+	}
+	return int((r.stats.next.Add(1) - 1) % uint64(len(r.replicas)))
+}
+
+// sticky reports whether the session of the context committed on the primary within the stickiness window.
+func (r *Router[B, T]) sticky(ctx context.Context) bool {
+	if r.stickiness <= 0 {
+		return false
+	}
+	deadline := r.now().Add(-r.stickiness)
+	if session, ok := ctx.Value(routerSessionKey{}).(*routerSession); ok {
+		if commit := session.lastCommit.Load(); commit != 0 && time.Unix(0, commit).After(deadline) {
+			return true
+		}
+	}
+	if r.sessionKey == nil {
+		return false
+	}
+	key, ok := r.sessionKey(ctx)
+	if !ok {
+		return false
+	}
+	r.stats.mu.Lock()
+	defer r.stats.mu.Unlock()
+	commit, ok := r.stats.commits[key]
+	return ok && commit.After(deadline)
+}
+
+// recordCommit records the commit on the primary for the session of the context.
+func (r *Router[B, T]) recordCommit(ctx context.Context) {
+	if r.stickiness <= 0 {
+		return
+	}
+	now := r.now()
+	if session, ok := ctx.Value(routerSessionKey{}).(*routerSession); ok {
+		session.lastCommit.Store(now.UnixNano())
+	}
+	if r.sessionKey == nil {
+		return
+	}
+	key, ok := r.sessionKey(ctx)
+	if !ok {
+		return
+	}
+	r.stats.mu.Lock()
+	defer r.stats.mu.Unlock()
+	r.stats.commits[key] = now
+	if now.After(r.stats.nextCleanup) {
+		// the expired commits are removed at most once per the stickiness window.
+		deadline := now.Add(-r.stickiness)
+		for k, commit := range r.stats.commits {
+			if !commit.After(deadline) {
+				delete(r.stats.commits, k)
+			}
+		}
+		r.stats.nextCleanup = now.Add(r.stickiness)
+	}
+}
+
+type (
+	// routerSessionKey is the context key of routerSession.
+	routerSessionKey struct{}
+
+	// routerSession holds the time of the last commit on the primary within the session (see WithRouterSession).
+	routerSession struct {
+		lastCommit atomic.Int64
+	}
+)
+
+// WithRouterSession returns a context with a new read-your-writes session of Router (see Router.WithStickiness).
+// The read-only calls with the context (or derived contexts) are routed to the primary
+// during the stickiness window after a commit on the primary with the context.
+//
+// Example:
+//
+//	ctx = mtx.WithRouterSession(ctx)
+//	_ = router.WithinTx(ctx, createOrder)                            // primary
+//	_ = router.WithTxOptions(mtx.ReadOnly()).WithinTx(ctx, getOrder) // primary (within the window)
+func WithRouterSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, routerSessionKey{}, &routerSession{})
+}
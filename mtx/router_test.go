@@ -0,0 +1,290 @@
+package mtx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+)
+
+func Test_Router(t *testing.T) {
+	type (
+		routerKey  struct{}
+		replicaKey struct{}
+		recorder   struct {
+			begun   []string
+			options []TxOptions
+		}
+	)
+	var (
+		newBeginner = func(name string, r *recorder) *beginnerWithOptionsMock[*committerMock] {
+			begin := func(context.Context) (*committerMock, error) {
+				r.begun = append(r.begun, name)
+				return &committerMock{
+					commitFn: func(context.Context) error {
+						return nil
+					},
+					rollbackFn: func(context.Context) error {
+						return nil
+					},
+				}, nil
+			}
+			return &beginnerWithOptionsMock[*committerMock]{
+				beginnerMock: beginnerMock[*committerMock]{
+					beginFn: begin,
+				},
+				beginWithOptionsFn: func(ctx context.Context, opts TxOptions) (*committerMock, error) {
+					r.options = append(r.options, opts)
+					return begin(ctx)
+				},
+			}
+		}
+		newRouter = func(r *recorder, replicas ...string) *Router[*beginnerWithOptionsMock[*committerMock], *committerMock] {
+			var (
+				operator = NewContextOperator[routerKey, *committerMock](routerKey{})
+				primary  = NewTransactor[*beginnerWithOptionsMock[*committerMock], *committerMock](newBeginner("primary", r), operator)
+				list     = make([]*Transactor[*beginnerWithOptionsMock[*committerMock], *committerMock], 0, len(replicas))
+			)
+			for _, name := range replicas {
+				list = append(list, NewTransactor[*beginnerWithOptionsMock[*committerMock], *committerMock](newBeginner(name, r), operator))
+			}
+			return NewRouter(primary, list...)
+		}
+		noop = func(context.Context) error {
+			return nil
+		}
+	)
+
+	t.Run("round_robin", func(t *testing.T) {
+		var (
+			r      recorder
+			ctx    = context.Background()
+			writer = newRouter(&r, "replica_0", "replica_1")
+			reader = writer.WithTxOptions(ReadOnly())
+		)
+		assert.NoError(t, writer.WithinTx(ctx, noop))
+		for range 3 {
+			assert.NoError(t, reader.WithinTx(ctx, noop))
+		}
+		assert.True(t, slices.Equal([]string{"primary", "replica_0", "replica_1", "replica_0"}, r.begun))
+	})
+	t.Run("least_loaded", func(t *testing.T) {
+		var (
+			r      recorder
+			ctx    = context.Background()
+			reader = newRouter(&r, "replica_0", "replica_1").
+				WithTxOptions(ReadOnly()).
+				WithReplicaSelection(ReplicaLeastLoaded)
+		)
+		err := reader.WithinTx(ctx, func(context.Context) error {
+			// replica_0 is busy, so a new independent transaction begins on replica_1.
+			return reader.WithinTx(context.Background(), noop)
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, reader.WithinTx(ctx, noop))
+		assert.True(t, slices.Equal([]string{"replica_0", "replica_1", "replica_0"}, r.begun))
+	})
+	t.Run("nested_within_primary", func(t *testing.T) {
+		var (
+			r      recorder
+			ctx    = context.Background()
+			writer = newRouter(&r, "replica_0")
+			reader = writer.WithTxOptions(ReadOnly())
+		)
+		err := writer.WithinTx(ctx, func(ctx context.Context) error {
+			return reader.WithinTx(ctx, func(ctx context.Context) error {
+				state, ok := extractCurrentState(ctx)
+				assert.True(t, ok)
+				assert.False(t, state.options.ReadOnly)
+				return nil
+			})
+		})
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal([]string{"primary"}, r.begun))
+	})
+	t.Run("nested_within_replica", func(t *testing.T) {
+		var (
+			r      recorder
+			ctx    = context.Background()
+			writer = newRouter(&r, "replica_0")
+			reader = writer.WithTxOptions(ReadOnly())
+		)
+		err := reader.WithinTx(ctx, func(ctx context.Context) error {
+			assert.NoError(t, reader.WithinTx(ctx, noop))
+			return writer.WithinTx(ctx, noop)
+		})
+		assert.ErrorIs(t, err, ErrReplicaTxWrite)
+		assert.True(t, slices.Equal([]string{"replica_0"}, r.begun))
+	})
+	t.Run("distinct_operators", func(t *testing.T) {
+		var (
+			r       recorder
+			ctx     = context.Background()
+			primary = NewTransactor[*beginnerWithOptionsMock[*committerMock], *committerMock](
+				newBeginner("primary", &r),
+				NewContextOperator[routerKey, *committerMock](routerKey{}),
+			)
+			replica = NewTransactor[*beginnerWithOptionsMock[*committerMock], *committerMock](
+				newBeginner("replica_0", &r),
+				NewContextOperator[replicaKey, *committerMock](replicaKey{}),
+			)
+			writer = NewRouter(primary, replica)
+			reader = writer.WithTxOptions(ReadOnly())
+		)
+		err := reader.WithinTx(ctx, func(ctx context.Context) error {
+			tx, ok := writer.TryGetTx(ctx)
+			assert.True(t, ok)
+			replicaTx, _ := replica.TryGetTx(ctx)
+			assert.True(t, tx == replicaTx)
+
+			assert.NoError(t, reader.WithinTx(ctx, noop))
+			return writer.WithinTx(ctx, noop)
+		})
+		assert.ErrorIs(t, err, ErrReplicaTxWrite)
+
+		err = writer.WithinTx(ctx, func(ctx context.Context) error {
+			tx, ok := writer.TryGetTx(ctx)
+			assert.True(t, ok)
+			primaryTx, _ := primary.TryGetTx(ctx)
+			assert.True(t, tx == primaryTx)
+			return reader.WithinTx(ctx, noop)
+		})
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal([]string{"replica_0", "primary"}, r.begun))
+
+		_, ok := writer.TryGetTx(ctx)
+		assert.False(t, ok)
+	})
+	t.Run("tx_options_merged", func(t *testing.T) {
+		var (
+			r       recorder
+			ctx     = context.Background()
+			primary = NewTransactor[*beginnerWithOptionsMock[*committerMock], *committerMock](
+				newBeginner("primary", &r),
+				NewContextOperator[routerKey, *committerMock](routerKey{}),
+			).WithTxOptions(Isolation(LevelSerializable))
+			writer = NewRouter(primary)
+		)
+		assert.NoError(t, writer.WithinTx(ctx, noop))
+		assert.NoError(t, writer.WithTxOptions(ReadOnly()).WithinTx(ctx, noop))
+		assert.True(t, slices.Equal([]TxOptions{
+			{Isolation: LevelSerializable},
+			{Isolation: LevelSerializable, ReadOnly: true},
+		}, r.options))
+	})
+	t.Run("read_your_writes_session", func(t *testing.T) {
+		var (
+			r      recorder
+			now    = time.Now()
+			ctx    = WithRouterSession(context.Background())
+			writer = newRouter(&r, "replica_0").WithStickiness(time.Second)
+			reader = writer.WithTxOptions(ReadOnly())
+		)
+		writer.now = func() time.Time {
+			return now
+		}
+		reader.now = writer.now
+
+		assert.NoError(t, reader.WithinTx(ctx, noop))
+		assert.NoError(t, writer.WithinTx(ctx, noop))
+		assert.NoError(t, reader.WithinTx(ctx, noop))
+		// another session is not sticky.
+		assert.NoError(t, reader.WithinTx(context.Background(), noop))
+
+		now = now.Add(2 * time.Second)
+		assert.NoError(t, reader.WithinTx(ctx, noop))
+		assert.True(t, slices.Equal([]string{"replica_0", "primary", "primary", "replica_0", "replica_0"}, r.begun))
+	})
+	t.Run("read_your_writes_session_key", func(t *testing.T) {
+		type userKey struct{}
+		var (
+			r      recorder
+			now    = time.Now()
+			writer = newRouter(&r, "replica_0").
+				WithStickiness(time.Second).
+				WithSessionKey(func(ctx context.Context) (string, bool) {
+					user, ok := ctx.Value(userKey{}).(string)
+					return user, ok
+				})
+			reader = writer.WithTxOptions(ReadOnly())
+			alice  = context.WithValue(context.Background(), userKey{}, "alice")
+			bob    = context.WithValue(context.Background(), userKey{}, "bob")
+		)
+		writer.now = func() time.Time {
+			return now
+		}
+		reader.now = writer.now
+
+		assert.NoError(t, writer.WithinTx(alice, noop))
+		assert.NoError(t, reader.WithinTx(context.WithValue(context.Background(), userKey{}, "alice"), noop))
+		assert.NoError(t, reader.WithinTx(bob, noop))
+
+		now = now.Add(2 * time.Second)
+		assert.NoError(t, writer.WithinTx(bob, noop))
+		assert.Equal(t, 1, len(writer.stats.commits))
+		assert.NoError(t, reader.WithinTx(alice, noop))
+		assert.True(t, slices.Equal([]string{"primary", "primary", "replica_0", "primary", "replica_0"}, r.begun))
+	})
+	t.Run("failed_write_is_not_sticky", func(t *testing.T) {
+		var (
+			r      recorder
+			ctx    = WithRouterSession(context.Background())
+			writer = newRouter(&r, "replica_0").WithStickiness(time.Minute)
+		)
+		assert.Error(t, writer.WithinTx(ctx, func(context.Context) error {
+			return fmt.Errorf("write error")
+		}))
+		assert.NoError(t, writer.WithTxOptions(ReadOnly()).WithinTx(ctx, noop))
+		assert.True(t, slices.Equal([]string{"primary", "replica_0"}, r.begun))
+	})
+	t.Run("after_commit_failed_write_is_sticky", func(t *testing.T) {
+		var (
+			r      recorder
+			ctx    = WithRouterSession(context.Background())
+			writer = newRouter(&r, "replica_0").WithStickiness(time.Minute)
+		)
+		err := writer.WithinTx(ctx, func(ctx context.Context) error {
+			return AfterCommit(ctx, func(context.Context) error {
+				return fmt.Errorf("after commit error")
+			})
+		})
+		assert.ErrorIs(t, err, ErrAfterCommitFailed)
+		assert.NoError(t, writer.WithTxOptions(ReadOnly()).WithinTx(ctx, noop))
+		assert.True(t, slices.Equal([]string{"primary", "primary"}, r.begun))
+	})
+	t.Run("rollback_only_write_is_not_sticky", func(t *testing.T) {
+		var (
+			r      recorder
+			ctx    = WithRouterSession(context.Background())
+			writer = newRouter(&r, "replica_0").WithStickiness(time.Minute)
+		)
+		writer.primary = writer.primary.WithSilentRollbackOnly(true)
+		assert.NoError(t, writer.WithinTx(ctx, func(ctx context.Context) error {
+			return SetRollbackOnly(ctx)
+		}))
+		assert.NoError(t, writer.WithTxOptions(ReadOnly()).WithinTx(ctx, noop))
+		assert.True(t, slices.Equal([]string{"primary", "replica_0"}, r.begun))
+	})
+	t.Run("without_replicas", func(t *testing.T) {
+		var (
+			r   recorder
+			ctx = context.Background()
+		)
+		assert.NoError(t, newRouter(&r).WithTxOptions(ReadOnly()).WithinTx(ctx, noop))
+		assert.True(t, slices.Equal([]string{"primary"}, r.begun))
+	})
+	t.Run("nil_primary", func(t *testing.T) {
+		var router *Router[*beginnerWithOptionsMock[*committerMock], *committerMock]
+		assert.Error(t, router.WithinTx(context.Background(), noop))
+
+		router = NewRouter[*beginnerWithOptionsMock[*committerMock], *committerMock](nil)
+		assert.ErrorIs(t, router.WithinTx(context.Background(), noop), ErrNilPrimary)
+	})
+	t.Run("replica_selection_string", func(t *testing.T) {
+		assert.Equal(t, "LeastLoaded", ReplicaLeastLoaded.String())
+		assert.Equal(t, "ReplicaSelection(100)", ReplicaSelection(100).String())
+	})
+}
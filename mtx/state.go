@@ -0,0 +1,226 @@
+package mtx
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// txState holds the state of a top-level transaction.
+// It is shared with nested calls through context.Context.
+type txState struct {
+	id      uint64
+	begun   time.Time
+	options TxOptions
+	guard   *txGuard
+	*txCallbacks
+}
+
+// newTxState creates a new txState with a new transaction identifier.
+// The callbacks can be shared between several transactions (see MultiTransactor).
+func newTxState(options TxOptions, callbacks *txCallbacks) *txState {
+	return &txState{
+		id:          txIDSeq.Add(1),
+		begun:       time.Now(),
+		options:     options,
+		txCallbacks: callbacks,
+	}
+}
+
+// txCallbacks holds the callbacks registered within a top-level transaction
+// and the rollback-only mark of the transaction (see SetRollbackOnly).
+type txCallbacks struct {
+	mu            sync.Mutex
+	beforeCommit  []func(ctx context.Context) error
+	afterCommit   []func(ctx context.Context) error
+	afterRollback []func(ctx context.Context) error
+	rollbackOnly  bool
+}
+
+// setRollbackOnly marks the transaction as rollback-only.
+func (s *txCallbacks) setRollbackOnly() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rollbackOnly = true
+}
+
+// isRollbackOnly reports whether the transaction is marked as rollback-only.
+func (s *txCallbacks) isRollbackOnly() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rollbackOnly
+}
+
+// stateMark holds the number of registered callbacks (see txCallbacks.mark).
+type stateMark struct {
+	beforeCommit  int
+	afterCommit   int
+	afterRollback int
+}
+
+// addBeforeCommit registers a before-commit hook.
+func (s *txCallbacks) addBeforeCommit(fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.beforeCommit = append(s.beforeCommit, fn)
+}
+
+// addAfterCommit registers an after-commit callback.
+func (s *txCallbacks) addAfterCommit(fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.afterCommit = append(s.afterCommit, fn)
+}
+
+// addAfterRollback registers an after-rollback callback.
+func (s *txCallbacks) addAfterRollback(fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.afterRollback = append(s.afterRollback, fn)
+}
+
+// mark returns the number of registered before-commit hooks, after-commit and after-rollback callbacks.
+// It is used to discard the callbacks registered within a savepoint which was rolled back (see discard).
+func (s *txCallbacks) mark() stateMark {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return stateMark{
+		beforeCommit:  len(s.beforeCommit),
+		afterCommit:   len(s.afterCommit),
+		afterRollback: len(s.afterRollback),
+	}
+}
+
+// discard removes the before-commit hooks, after-commit and after-rollback callbacks registered after the mark.
+func (s *txCallbacks) discard(mark stateMark) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if mark.beforeCommit < len(s.beforeCommit) {
+		clear(s.beforeCommit[mark.beforeCommit:])
+		s.beforeCommit = s.beforeCommit[:mark.beforeCommit]
+	}
+	if mark.afterCommit < len(s.afterCommit) {
+		clear(s.afterCommit[mark.afterCommit:])
+		s.afterCommit = s.afterCommit[:mark.afterCommit]
+	}
+	if mark.afterRollback < len(s.afterRollback) {
+		clear(s.afterRollback[mark.afterRollback:])
+		s.afterRollback = s.afterRollback[:mark.afterRollback]
+	}
+}
+
+// takeBeforeCommit returns the before-commit hooks.
+func (s *txCallbacks) takeBeforeCommit() []func(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fns := s.beforeCommit
+	s.beforeCommit = nil
+	return fns
+}
+
+// takeAfterCommit returns the after-commit callbacks.
+func (s *txCallbacks) takeAfterCommit() []func(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fns := s.afterCommit
+	s.afterCommit = nil
+	return fns
+}
+
+// takeAfterRollback returns the after-rollback callbacks.
+func (s *txCallbacks) takeAfterRollback() []func(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fns := s.afterRollback
+	s.afterRollback = nil
+	return fns
+}
+
+type (
+	// txStateKey is the context key of txState. Each TxBeginner has its own txState.
+	txStateKey[B comparable] struct {
+		beginner B
+	}
+
+	// currentTxStateKey is the context key of the txState of the innermost WithinTx call.
+	// It is used by the package level functions (BeforeCommit, AfterCommit, AfterRollback, etc.).
+	currentTxStateKey struct{}
+
+	// commitRecorderKey is the context key of commitRecorder.
+	commitRecorderKey struct{}
+)
+
+// injectState stores the txState of the beginner in the context.
+// The txState also becomes the current one.
+func injectState[B comparable](ctx context.Context, beginner B, state *txState) context.Context {
+	ctx = context.WithValue(ctx, txStateKey[B]{beginner: beginner}, state)
+	return injectCurrentState(ctx, state)
+}
+
+// extractState returns the txState of the beginner from the context.
+func extractState[B comparable](ctx context.Context, beginner B) (*txState, bool) {
+	state, ok := ctx.Value(txStateKey[B]{beginner: beginner}).(*txState)
+	return state, ok && state != nil
+}
+
+// injectCurrentState stores the current txState in the context.
+// A nil state means that there is no current transaction.
+func injectCurrentState(ctx context.Context, state *txState) context.Context {
+	return context.WithValue(ctx, currentTxStateKey{}, state)
+}
+
+// withoutCurrentState returns a context without the current txState,
+// so the package level functions do not attach to a transaction of another TxBeginner
+// within a non-transactional call (see PropagationNever, PropagationSupports and PropagationNotSupported).
+func withoutCurrentState(ctx context.Context) context.Context {
+	if _, ok := extractCurrentState(ctx); !ok {
+		return ctx
+	}
+	return injectCurrentState(ctx, nil)
+}
+
+// extractCurrentState returns the current txState from the context.
+func extractCurrentState(ctx context.Context) (*txState, bool) {
+	state, ok := ctx.Value(currentTxStateKey{}).(*txState)
+	return state, ok && state != nil
+}
+
+// commitRecorder records whether the top-level transaction started by an Executor is committed.
+// The error is not enough to detect the commit: the function can return an error wrapping
+// ErrAfterCommitFailed (for example, the error of a nested PropagationRequiresNew call),
+// while the transaction is rolled back.
+type commitRecorder struct {
+	committed bool
+	// parent is the recorder of the outer caller (see withCommitRecorder).
+	parent *commitRecorder
+}
+
+// withCommitRecorder returns a context with a new commitRecorder.
+// The recorder of the context (if any) becomes the parent, so both are marked by the commit.
+func withCommitRecorder(ctx context.Context) (context.Context, *commitRecorder) {
+	parent, _ := ctx.Value(commitRecorderKey{}).(*commitRecorder)
+	recorder := &commitRecorder{parent: parent}
+	return context.WithValue(ctx, commitRecorderKey{}, recorder), recorder
+}
+
+// takeCommitRecorder returns the commitRecorder of the context and the context without it,
+// so the transactions started within the function of an Executor do not mark the recorder of the Executor.
+func takeCommitRecorder(ctx context.Context) (context.Context, *commitRecorder) {
+	recorder, _ := ctx.Value(commitRecorderKey{}).(*commitRecorder)
+	if recorder == nil {
+		return ctx, nil
+	}
+	return context.WithValue(ctx, commitRecorderKey{}, (*commitRecorder)(nil)), recorder
+}
+
+// markCommitted marks the recorder and its parents as committed. A nil recorder is ignored.
+func (r *commitRecorder) markCommitted() {
+	for ; r != nil; r = r.parent {
+		r.committed = true
+	}
+}
+
+// isCommitted reports whether the transaction is committed.
+func (r *commitRecorder) isCommitted() bool {
+	return r != nil && r.committed
+}
@@ -6,6 +6,8 @@ package mtx
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
+	"time"
 
 	"github.com/kozmod/oniontx/internal/errors"
 )
@@ -41,8 +43,55 @@ var (
 	// ErrPanicRecovered indicates that a panic was recovered and converted to an error.
 	// It wraps the original panic value to provide context about what caused the panic.
 	ErrPanicRecovered = fmt.Errorf("panic recovered")
+
+	// ErrSavepointFailed indicates that creating a savepoint for a nested call has failed.
+	// This error wraps the underlying error from the database driver.
+	ErrSavepointFailed = fmt.Errorf("savepoint failed")
+
+	// ErrReleaseSavepointFailed indicates that releasing a savepoint after
+	// a successful nested call has failed.
+	ErrReleaseSavepointFailed = fmt.Errorf("release savepoint failed")
+
+	// ErrRollbackToSavepointFailed indicates that rolling back to a savepoint
+	// after a failed nested call has failed.
+	ErrRollbackToSavepointFailed = fmt.Errorf("rollback to savepoint failed")
+
+	// ErrRollbackToSavepointSuccess indicates that a failed nested call was
+	// successfully rolled back to its savepoint. The outer transaction is still active.
+	ErrRollbackToSavepointSuccess = fmt.Errorf("tx rolled back to savepoint")
+
+	// ErrTxNotFound indicates that a transaction is required but was not found in the context.
+	// This error is returned for PropagationMandatory.
+	ErrTxNotFound = fmt.Errorf("tx not found")
+
+	// ErrTxExists indicates that a transaction was found in the context when none is allowed.
+	// This error is returned for PropagationNever.
+	ErrTxExists = fmt.Errorf("tx exists")
+
+	// ErrInvalidPropagation indicates that the Transactor was configured with an unknown Propagation.
+	ErrInvalidPropagation = fmt.Errorf("invalid propagation")
+
+	// ErrTxOptionsNotSupported indicates that the Transactor was configured with TxOptions,
+	// but the TxBeginner does not implement TxBeginnerWithOptions.
+	ErrTxOptionsNotSupported = fmt.Errorf("tx beginner does not support tx options")
+
+	// ErrIsolationLevel indicates that a nested call requires a stricter isolation level
+	// than the isolation level of the active transaction (see IsolationLevelError).
+	ErrIsolationLevel = fmt.Errorf("isolation level is stricter than active")
+
+	// ErrCtxRemoveNotSupported indicates that the CtxOperator cannot remove a transaction
+	// from the context (it does not implement CtxRemover).
+	// This error is returned for PropagationNotSupported.
+	ErrCtxRemoveNotSupported = fmt.Errorf("ctx operator does not support tx removal")
+
+	// ErrTxTimeout indicates that the top-level transaction exceeded the timeout
+	// configured by Transactor.WithTxTimeout and was rolled back.
+	ErrTxTimeout = fmt.Errorf("tx timeout")
 )
 
+// savepointSeq is used to generate unique savepoint names.
+var savepointSeq atomic.Uint64
+
 type (
 	// TxBeginner is responsible for creating new Tx.
 	TxBeginner[T Tx] interface {
@@ -56,12 +105,36 @@ type (
 		Commit(ctx context.Context) error
 	}
 
+	// SavepointTx is an optional Tx extension.
+	// When the transaction implements it, nested WithinTx calls are executed
+	// within a savepoint, so a nested failure rolls back only to that savepoint
+	// and the outer transaction can continue.
+	SavepointTx interface {
+		Savepoint(ctx context.Context, name string) error
+		RollbackTo(ctx context.Context, name string) error
+		Release(ctx context.Context, name string) error
+	}
+
+	// SavepointCapableTx is an optional SavepointTx extension for the transactions
+	// whose savepoint support is known only at run time (for example, it depends on the driver).
+	// If SupportsSavepoints returns false, nested WithinTx calls join the transaction without savepoints.
+	SavepointCapableTx interface {
+		SupportsSavepoints() bool
+	}
+
 	// CtxOperator is responsible for transaction propagation through context.Context.
 	// It provides methods to inject a transaction into context and extract it back.
 	CtxOperator[T Tx] interface {
 		Inject(ctx context.Context, tx T) context.Context
 		Extract(ctx context.Context) (T, bool)
 	}
+
+	// CtxRemover is an optional CtxOperator extension.
+	// It returns a context in which the transaction is not found by Extract.
+	// It is required by PropagationNotSupported.
+	CtxRemover interface {
+		Remove(ctx context.Context) context.Context
+	}
 )
 
 // Transactor manages transactions for a single TxBeginner instance.
@@ -75,6 +148,18 @@ type Transactor[B TxBeginner[T], T Tx] struct {
 	beginner           B
 	operator           CtxOperator[T]
 	rollbackCtxFactory func(ctx context.Context) context.Context
+	propagation        Propagation
+	txOptions          TxOptions
+	retrier            *retrier
+	hookOrder          HookOrder
+	observer           TxObserver
+	txTimeout          time.Duration
+	silentRollbackOnly bool
+	panicPolicy        PanicPolicy
+	monitor            *TxMonitor
+	debug              bool
+	strictDebug        bool
+	misuseHandler      TxMisuseHandler
 }
 
 // NewTransactor returns new Transactor.
@@ -91,25 +176,121 @@ func NewTransactor[B TxBeginner[T], T Tx](
 }
 
 // WithRollbackCtxFactory returns a new Transactor that derives the context used
-// for top-level rollback operations. The original Transactor is not modified.
+// for top-level rollback operations and rollbacks to savepoints.
+// The original Transactor is not modified.
 //
 // This is useful when the operation context may be canceled before rollback.
 // For example, context.WithoutCancel allows a rollback to outlive request
 // cancellation. If factory is nil or returns nil, rollback uses the original
 // operation context.
 func (t *Transactor[B, T]) WithRollbackCtxFactory(factory func(ctx context.Context) context.Context) *Transactor[B, T] {
-	return &Transactor[B, T]{
-		beginner: t.beginner,
-		operator: t.operator,
-		rollbackCtxFactory: func(ctx context.Context) context.Context {
-			if factory != nil {
-				if newCtx := factory(ctx); newCtx != nil {
-					return newCtx
-				}
+	c := *t
+	c.rollbackCtxFactory = func(ctx context.Context) context.Context {
+		if factory != nil {
+			if newCtx := factory(ctx); newCtx != nil {
+				return newCtx
 			}
-			return ctx
-		},
+		}
+		return ctx
+	}
+	return &c
+}
+
+// WithPropagation returns a new Transactor that executes WithinTx with the given Propagation.
+// The original Transactor is not modified, so it is cheap to derive
+// a Transactor with the required Propagation per call:
+//
+//	err := transactor.WithPropagation(mtx.PropagationRequiresNew).WithinTx(ctx, writeAuditLog)
+func (t *Transactor[B, T]) WithPropagation(propagation Propagation) *Transactor[B, T] {
+	c := *t
+	c.propagation = propagation
+	return &c
+}
+
+// WithTxOptions returns a new Transactor that begins transactions with the given options.
+// The options are applied to the options of the original Transactor, which is not modified.
+//
+// Non-default options require the TxBeginner to implement TxBeginnerWithOptions,
+// otherwise WithinTx returns ErrTxOptionsNotSupported.
+// Nested calls reuse the active transaction, so they fail with IsolationLevelError
+// when they require a stricter isolation level than the active transaction has.
+//
+//	reportTransactor := transactor.WithTxOptions(mtx.Isolation(mtx.LevelRepeatableRead), mtx.ReadOnly())
+func (t *Transactor[B, T]) WithTxOptions(opts ...TxOption) *Transactor[B, T] {
+	c := *t
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&c.txOptions)
+		}
 	}
+	return &c
+}
+
+// WithRetry returns a new Transactor that re-executes the whole top-level transaction
+// (begin, fn, commit) when the classifier reports the error as retryable,
+// for example on serialization failures or deadlocks.
+// The original Transactor is not modified.
+//
+// Nested calls are never re-executed: their errors are returned to the top-level call,
+// which decides whether the transaction is retried.
+// The committed transaction is never re-executed, even if an after-commit callback fails.
+// The delays between attempts are defined by the policy (see RetryPolicy).
+// If policy or classifier is nil, retries are disabled.
+//
+// When the transaction fails, WithinTx returns *RetryError with the number of attempts.
+// If all attempts were exhausted, the error wraps ErrRetryFailed.
+//
+//	transactor = transactor.WithRetry(saga.NewBaseRetryPolicy(3, 10*time.Millisecond), isSerializationFailure)
+func (t *Transactor[B, T]) WithRetry(policy RetryPolicy, classifier RetryClassifier) *Transactor[B, T] {
+	c := *t
+	c.retrier = nil
+	if policy != nil && classifier != nil {
+		c.retrier = &retrier{
+			policy:     policy,
+			classifier: classifier,
+		}
+	}
+	return &c
+}
+
+// WithTxTimeout returns a new Transactor that limits the duration of its top-level transactions.
+// The original Transactor is not modified. A non-positive timeout disables the limit.
+//
+// The transaction is begun and executed with a context whose deadline is the timeout
+// (or the deadline of the parent context if it is earlier).
+// If the timeout is exceeded, the transaction is rolled back (see WithRollbackCtxFactory,
+// the rollback context is derived from the expired context) and WithinTx returns an error
+// wrapped with ErrTxTimeout and context.DeadlineExceeded, even if fn swallowed the context error.
+//
+// Nested calls reuse the context of the top-level transaction, so they cannot extend the deadline:
+// the timeout of a nested Transactor is ignored, and a new transaction of PropagationRequiresNew
+// cannot outlive the deadline of the parent context.
+//
+//	transactor = transactor.WithTxTimeout(5 * time.Second).WithRollbackCtxFactory(context.WithoutCancel)
+func (t *Transactor[B, T]) WithTxTimeout(timeout time.Duration) *Transactor[B, T] {
+	c := *t
+	c.txTimeout = timeout
+	return &c
+}
+
+// WithSilentRollbackOnly returns a new Transactor whose top-level WithinTx returns nil
+// instead of ErrRollbackOnly when the transaction was marked as rollback-only and successfully rolled back
+// (see SetRollbackOnly). The original Transactor is not modified.
+//
+// The option of the Transactor that begins the transaction is used; the option of a nested Transactor is ignored.
+func (t *Transactor[B, T]) WithSilentRollbackOnly(silent bool) *Transactor[B, T] {
+	c := *t
+	c.silentRollbackOnly = silent
+	return &c
+}
+
+// WithBeforeCommitOrder returns a new Transactor that calls the before-commit hooks
+// of its top-level transactions in the given order (see BeforeCommit).
+// The original Transactor is not modified.
+func (t *Transactor[B, T]) WithBeforeCommitOrder(order HookOrder) *Transactor[B, T] {
+	c := *t
+	c.hookOrder = order
+	return &c
 }
 
 // WithinTx executes the provided function within a transaction context.
@@ -119,19 +300,36 @@ func (t *Transactor[B, T]) WithRollbackCtxFactory(factory func(ctx context.Conte
 //   - Nested transaction support: When called recursively, only the top-level
 //     call creates and manages the actual transaction. Inner calls reuse the existing
 //     transaction from the context.
+//   - Savepoints: If the transaction implements SavepointTx (see SavepointCapableTx), every nested call
+//     creates a savepoint. A nested error or panic rolls back only to that savepoint
+//     and is returned to the caller, so the outer function may continue
+//     the transaction (see SavepointTx).
 //   - Automatic rollback: If the function returns an error or panics, the
 //     transaction is automatically rolled back.
 //   - Automatic commit: If the function completes without error, the transaction
 //     is automatically committed (only at the top level).
 //   - Panic recovery: Panics are recovered and converted to errors with
-//     ErrPanicRecovered. Higher-level panics override lower-level ones.
+//     ErrPanicRecovered and PanicError. Higher-level panics override lower-level ones.
+//     The transaction can re-panic instead (see WithPanicPolicy).
 //   - Context propagation: The transaction is injected into the context for
 //     inner function calls.
+//   - Retry: The top-level transaction can be re-executed on retryable errors (see WithRetry).
+//   - Timeout: The top-level transaction can be limited in time and rolled back with ErrTxTimeout (see WithTxTimeout).
+//   - Callbacks: Hooks registered by BeforeCommit are called just before the top-level
+//     commit and can veto it. Functions registered by AfterCommit and AfterRollback
+//     are called after the top-level transaction is completed.
+//   - Rollback-only: A nested call can force the rollback of the top-level
+//     transaction by SetRollbackOnly (see WithSilentRollbackOnly).
+//   - Observability: Lifecycle events are sent to the TxObserver (see WithObserver)
+//     and the open transactions are tracked by the TxMonitor (see WithMonitor).
+//   - Debug: The concurrent use of a transaction or the use after completion
+//     can be detected (see WithDebug).
 //
-// The function follows these rules:
+// The function follows these rules (PropagationRequired, default):
 //   - If a transaction exists in the context, it is reused (nested call)
 //   - Otherwise, a new transaction is created (top-level call)
-//   - Errors from the function or from commit/rollback are properly wrapped
+//   - Other rules can be configured by WithPropagation (see Propagation)
+//   - Errors from the function or from commit/rollback are properly wrapped (see TxError)
 //   - Panics are handled gracefully without crashing the application
 //
 // Example:
@@ -180,70 +378,351 @@ func (t *Transactor[B, T]) WithinTx(ctx context.Context, fn func(ctx context.Con
 		return fmt.Errorf("transactor - can't try extract transaction: %w", ErrNilTxOperator)
 	}
 
+	ctx, recorder := takeCommitRecorder(ctx)
 	tx, ok := t.operator.Extract(ctx)
+
+	switch t.propagation {
+	case PropagationRequired:
+	case PropagationRequiresNew:
+		// the existing transaction is suspended: the new one shadows it in the context.
+		ok = false
+	case PropagationMandatory:
+		if !ok {
+			return fmt.Errorf("transactor - %s: %w", t.propagation, ErrTxNotFound)
+		}
+	case PropagationNever:
+		if ok {
+			return fmt.Errorf("transactor - %s: %w", t.propagation, ErrTxExists)
+		}
+		return t.call(withoutCurrentState(ctx), fn)
+	case PropagationSupports:
+		if !ok {
+			return t.call(withoutCurrentState(ctx), fn)
+		}
+	case PropagationNotSupported:
+		if ok {
+			remover, isRemover := t.operator.(CtxRemover)
+			if !isRemover {
+				return fmt.Errorf("transactor - %s: %w", t.propagation, ErrCtxRemoveNotSupported)
+			}
+			ctx = remover.Remove(ctx)
+		}
+		return t.call(withoutCurrentState(ctx), fn)
+	default:
+		return fmt.Errorf("transactor - %s: %w", t.propagation, ErrInvalidPropagation)
+	}
+
 	if !ok {
-		tx, err = t.beginner.BeginTx(ctx)
-		if err != nil {
-			return fmt.Errorf("transactor - cannot begin: %w", errors.Join(ErrBeginTx, err))
+		committed, err := t.withinNewTx(ctx, fn)
+		if committed {
+			recorder.markCommitted()
 		}
+		return err
+	}
+
+	defer t.enterUse(ctx)()
+	state, hasState := extractState(ctx, t.beginner)
+	if hasState {
+		if err = checkIsolation(state.options.Isolation, t.txOptions.Isolation); err != nil {
+			return fmt.Errorf("transactor - nested: %w", err)
+		}
+	}
+	// the transaction of the Transactor becomes the current one for the package level functions (AfterCommit, etc.).
+	ctx = injectCurrentState(ctx, state)
+	ctx = injectDepth(ctx, extractDepth(ctx)+1)
+	t.observe(ctx, state, TxEvent{Type: TxEventJoin})
+
+	if sp, isSavepointTx := savepointTx(tx); isSavepointTx {
+		return t.withinSavepoint(ctx, sp, state, fn)
+	}
+	return t.call(ctx, fn)
+}
+
+// savepointTx returns the SavepointTx of the transaction if the transaction supports savepoints (see SavepointCapableTx).
+func savepointTx(tx any) (SavepointTx, bool) {
+	sp, ok := tx.(SavepointTx)
+	if !ok {
+		return nil, false
+	}
+	if capable, ok := tx.(SavepointCapableTx); ok && !capable.SupportsSavepoints() {
+		return nil, false
+	}
+	return sp, true
+}
+
+// withinNewTx executes the function within a new (top-level) transaction.
+// The whole transaction is re-executed according to the retry configuration (see WithRetry).
+// Reports whether the transaction is committed.
+func (t *Transactor[B, T]) withinNewTx(ctx context.Context, fn func(ctx context.Context) error) (bool, error) {
+	if t.retrier == nil {
+		return t.execute(ctx, fn)
+	}
+	return t.retrier.do(ctx, t.execute, fn)
+}
+
+// execute begins a new transaction and executes the function within it.
+// The transaction is committed if the function succeeds, otherwise it is rolled back.
+// The committed result reports whether the commit succeeded:
+// the error of the committed transaction can be returned only by the after-commit callbacks.
+func (t *Transactor[B, T]) execute(ctx context.Context, fn func(ctx context.Context) error) (committed bool, err error) {
+	ctx = injectDepth(ctx, 0)
+	var (
+		tx        T
+		parentCtx = ctx
+		state     = newTxState(t.txOptions, &txCallbacks{})
+	)
+
+	if t.txTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, t.txTimeout, ErrTxTimeout)
+		defer cancel()
+	}
+
+	err = t.observed(ctx, state, TxEventBeginStart, TxEventBeginEnd, func() (bErr error) {
+		tx, bErr = t.begin(ctx)
+		return bErr
+	})
+	if err != nil {
+		return false, err
+	}
+	if t.monitor != nil {
+		t.monitor.register(state)
+	}
+	if t.debug {
+		state.guard = newTxGuard(t.misuseHandler, t.strictDebug)
 	}
 
 	defer func() {
-		switch p := recover(); {
+		p := recover()
+		if state.guard != nil {
+			defer state.guard.complete()
+		}
+		if t.monitor != nil && (p == nil || t.panicPolicy != PanicPolicyRepanic) {
+			// the transaction left open by PanicPolicyRepanic stays in the monitor.
+			defer t.monitor.unregister(state.id)
+		}
+		if p == nil && err == nil {
+			// before-commit hooks can veto the commit: their error leads to the rollback.
+			err = runBeforeCommit(ctx, state, t.hookOrder)
+		}
+		if p == nil {
+			// the transaction is rolled back after the timeout even if fn swallowed the context error.
+			err = timeoutError(ctx, err)
+		}
+		// the rollback-only mark is not an error of fn, so it can be silenced.
+		silent := t.silentRollbackOnly && err == ErrRollbackOnly
+
+		switch {
 		case p != nil:
-			if ok {
-				err = fmt.Errorf(
-					"transactor - panic: %w",
-					errors.Join(ErrPanicRecovered, errors.WrapPanic(p)),
-				)
-				return
+			pErr := errors.WrapPanic(p)
+			t.observe(ctx, state, TxEvent{Type: TxEventPanicRecovered, Err: pErr})
+			if t.panicPolicy == PanicPolicyRepanic {
+				panic(p)
 			}
-
-			rollbackCtx := t.rollbackCtxFactory(ctx)
-			if rbErr := tx.Rollback(rollbackCtx); rbErr != nil {
-				err = fmt.Errorf(
-					"transactor - panic: %w",
-					errors.Join(ErrRollbackFailed, ErrPanicRecovered, rbErr, errors.WrapPanic(p)),
+			if rbErr := t.rollback(ctx, tx, state); rbErr != nil {
+				err = newTxError(
+					"transactor - panic",
+					TxError{Phase: TxPhaseRollback, RollbackErr: rbErr, Panic: p, Stack: pErr.Stack()},
+					ErrRollbackFailed, ErrPanicRecovered, rbErr, pErr,
 				)
 			} else {
-				err = fmt.Errorf(
-					"transactor - panic: %w",
-					errors.Join(ErrRollbackSuccess, ErrPanicRecovered, errors.WrapPanic(p)),
+				err = newTxError(
+					"transactor - panic",
+					TxError{Phase: TxPhaseFn, Panic: p, Stack: pErr.Stack()},
+					ErrRollbackSuccess, ErrPanicRecovered, pErr,
 				)
 			}
-		case err != nil:
-			if ok {
-				return
+			err = runAfterRollback(parentCtx, state, err, t.rollbackCtxFactory)
+			if t.panicPolicy == PanicPolicyRollbackRepanic {
+				panic(p)
 			}
-
-			rollbackCtx := t.rollbackCtxFactory(ctx)
-			if rbErr := tx.Rollback(rollbackCtx); rbErr != nil {
-				err = fmt.Errorf("transactor - call: %w", errors.Join(ErrRollbackFailed, rbErr, err))
+		case err != nil:
+			if rbErr := t.rollback(ctx, tx, state); rbErr != nil {
+				err = newTxError(
+					"transactor - call",
+					TxError{Phase: TxPhaseRollback, Err: err, RollbackErr: rbErr},
+					ErrRollbackFailed, rbErr, err,
+				)
+			} else if silent {
+				err = nil
 			} else {
-				err = fmt.Errorf("transactor - call: %w", errors.Join(ErrRollbackSuccess, err))
+				err = newTxError("transactor - call", TxError{Phase: TxPhaseFn, Err: err}, ErrRollbackSuccess, err)
 			}
+			err = runAfterRollback(parentCtx, state, err, t.rollbackCtxFactory)
 		default:
-			if ok {
+			if cErr := t.observed(ctx, state, TxEventCommitStart, TxEventCommitEnd, func() error {
+				return tx.Commit(ctx)
+			}); cErr != nil {
+				err = newTxError("transactor", TxError{Phase: TxPhaseCommit, CommitErr: cErr}, ErrCommitFailed, cErr)
+				err = runAfterRollback(parentCtx, state, err, t.rollbackCtxFactory)
 				return
 			}
-			if err = tx.Commit(ctx); err != nil {
-				err = fmt.Errorf("transactor: %w", errors.Join(ErrCommitFailed, err))
+			committed = true
+			err = runAfterCommit(parentCtx, state)
+		}
+	}()
+
+	ctx = t.operator.Inject(ctx, tx)
+	ctx = injectState(ctx, t.beginner, state)
+
+	err = fn(ctx)
+	return false, err
+}
+
+// timeoutError returns the error with ErrTxTimeout if the timeout of the transaction (see WithTxTimeout) is exceeded.
+// The deadline of the parent context is not the timeout of the transaction.
+func timeoutError(ctx context.Context, err error) error {
+	if ctx.Err() == nil || context.Cause(ctx) != ErrTxTimeout {
+		return err
+	}
+	return errors.Join(ErrTxTimeout, context.DeadlineExceeded, err)
+}
+
+// rollback rolls back the transaction with the rollback context (see WithRollbackCtxFactory).
+func (t *Transactor[B, T]) rollback(ctx context.Context, tx T, state *txState) error {
+	return t.observed(ctx, state, TxEventRollbackStart, TxEventRollbackEnd, func() error {
+		return tx.Rollback(t.rollbackCtxFactory(ctx))
+	})
+}
+
+// begin starts a new transaction with the configured TxOptions.
+func (t *Transactor[B, T]) begin(ctx context.Context) (T, error) {
+	var (
+		tx  T
+		err error
+	)
+	switch beginner, isBeginnerWithOptions := any(t.beginner).(TxBeginnerWithOptions[T]); {
+	case t.txOptions == TxOptions{}:
+		tx, err = t.beginner.BeginTx(ctx)
+	case isBeginnerWithOptions:
+		tx, err = beginner.BeginTxWithOptions(ctx, t.txOptions)
+	default:
+		err = ErrTxOptionsNotSupported
+	}
+	if err != nil {
+		return tx, newTxError("transactor - cannot begin", TxError{Phase: TxPhaseBegin, BeginErr: err}, ErrBeginTx, err)
+	}
+	return tx, nil
+}
+
+// call executes the function without managing a transaction
+// (without a transaction or within an existing one).
+// Panics are recovered and converted to errors with ErrPanicRecovered.
+func (t *Transactor[B, T]) call(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			pErr := errors.WrapPanic(p)
+			state, _ := extractCurrentState(ctx)
+			t.observe(ctx, state, TxEvent{Type: TxEventPanicRecovered, Err: pErr})
+			if t.panicPolicy != PanicPolicyRecover {
+				// there is nothing to roll back: the transaction (if any) is managed by the outer call.
+				panic(p)
 			}
+			err = newTxError(
+				"transactor - panic",
+				TxError{Phase: TxPhaseFn, Panic: p, Stack: pErr.Stack(), Depth: extractDepth(ctx)},
+				ErrPanicRecovered, pErr,
+			)
 		}
 	}()
 
-	if !ok {
-		ctx = t.operator.Inject(ctx, tx)
+	err = fn(ctx)
+	return err
+}
+
+// withinSavepoint executes the nested function within a savepoint of the existing transaction.
+// On error or panic the transaction is rolled back to the savepoint, otherwise the savepoint is released.
+// The before-commit hooks, after-commit and after-rollback callbacks registered within the rolled back savepoint are discarded.
+func (t *Transactor[B, T]) withinSavepoint(ctx context.Context, tx SavepointTx, state *txState, fn func(ctx context.Context) error) (err error) {
+	name := fmt.Sprintf("oniontx_sp_%d", savepointSeq.Add(1))
+	depth := extractDepth(ctx)
+	if err = tx.Savepoint(ctx, name); err != nil {
+		return newTxError(
+			"transactor - cannot create savepoint",
+			TxError{Phase: TxPhaseBegin, BeginErr: err, Depth: depth, Savepoint: true},
+			ErrSavepointFailed, err,
+		)
+	}
+
+	var mark stateMark
+	if state != nil {
+		mark = state.mark()
 	}
 
+	defer func() {
+		p := recover()
+		if (p != nil || err != nil) && state != nil {
+			state.discard(mark)
+		}
+
+		switch {
+		case p != nil:
+			pErr := errors.WrapPanic(p)
+			t.observe(ctx, state, TxEvent{Type: TxEventPanicRecovered, Err: pErr})
+			if t.panicPolicy == PanicPolicyRepanic {
+				panic(p)
+			}
+			rbErr := t.rollbackTo(ctx, tx, name, state)
+			if t.panicPolicy == PanicPolicyRollbackRepanic {
+				panic(p)
+			}
+			if rbErr != nil {
+				err = newTxError(
+					"transactor - savepoint panic",
+					TxError{Phase: TxPhaseRollback, RollbackErr: rbErr, Panic: p, Stack: pErr.Stack(), Depth: depth, Savepoint: true},
+					ErrRollbackToSavepointFailed, ErrPanicRecovered, rbErr, pErr,
+				)
+			} else {
+				err = newTxError(
+					"transactor - savepoint panic",
+					TxError{Phase: TxPhaseFn, Panic: p, Stack: pErr.Stack(), Depth: depth, Savepoint: true},
+					ErrRollbackToSavepointSuccess, ErrPanicRecovered, pErr,
+				)
+			}
+		case err != nil:
+			if rbErr := t.rollbackTo(ctx, tx, name, state); rbErr != nil {
+				err = newTxError(
+					"transactor - savepoint call",
+					TxError{Phase: TxPhaseRollback, Err: err, RollbackErr: rbErr, Depth: depth, Savepoint: true},
+					ErrRollbackToSavepointFailed, rbErr, err,
+				)
+			} else {
+				err = newTxError(
+					"transactor - savepoint call",
+					TxError{Phase: TxPhaseFn, Err: err, Depth: depth, Savepoint: true},
+					ErrRollbackToSavepointSuccess, err,
+				)
+			}
+		default:
+			if rlErr := tx.Release(ctx, name); rlErr != nil {
+				err = newTxError(
+					"transactor - savepoint",
+					TxError{Phase: TxPhaseCommit, CommitErr: rlErr, Depth: depth, Savepoint: true},
+					ErrReleaseSavepointFailed, rlErr,
+				)
+			}
+		}
+	}()
+
 	err = fn(ctx)
 	return err
 }
 
+// rollbackTo rolls back the transaction to the savepoint with the rollback context (see WithRollbackCtxFactory).
+func (t *Transactor[B, T]) rollbackTo(ctx context.Context, tx SavepointTx, name string, state *txState) error {
+	return t.observed(ctx, state, TxEventRollbackStart, TxEventRollbackEnd, func() error {
+		return tx.RollbackTo(t.rollbackCtxFactory(ctx), name)
+	})
+}
+
 // TryGetTx attempts to retrieve a transaction from the given context.
 // It returns the transaction and true if found, or a zero value and false otherwise.
 func (t *Transactor[B, T]) TryGetTx(ctx context.Context) (T, bool) {
 	tx, ok := t.operator.Extract(ctx)
+	if ok {
+		// the extraction is an instant use: it is checked against the in-flight uses.
+		t.enterUse(ctx)()
+	}
 	return tx, ok
 }
 
@@ -2,9 +2,11 @@ package mtx
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/kozmod/oniontx/internal/testtool/assert"
 )
@@ -876,3 +878,797 @@ func Test_Transactor_recursive_call(t *testing.T) { //nolint: dupl
 		})
 	})
 }
+
+func Test_Transactor_savepoint(t *testing.T) {
+	type calls struct {
+		commit, rollback, savepoint, rollbackTo, release int
+		// names is a stack of active savepoints.
+		names []string
+	}
+	newInstance := func(c *calls) (*savepointMock, *Transactor[*beginnerMock[*savepointMock], *savepointMock]) {
+		var (
+			tx = &savepointMock{
+				committerMock: committerMock{
+					commitFn: func(context.Context) error {
+						c.commit++
+						return nil
+					},
+					rollbackFn: func(context.Context) error {
+						c.rollback++
+						return nil
+					},
+				},
+				savepointFn: func(_ context.Context, name string) error {
+					c.savepoint++
+					c.names = append(c.names, name)
+					return nil
+				},
+				rollbackToFn: func(_ context.Context, name string) error {
+					c.rollbackTo++
+					assert.Equal(t, c.names[len(c.names)-1], name)
+					c.names = c.names[:len(c.names)-1]
+					return nil
+				},
+				releaseFn: func(_ context.Context, name string) error {
+					c.release++
+					assert.Equal(t, c.names[len(c.names)-1], name)
+					c.names = c.names[:len(c.names)-1]
+					return nil
+				},
+			}
+			b = &beginnerMock[*savepointMock]{
+				beginFn: func(context.Context) (*savepointMock, error) {
+					return tx, nil
+				},
+			}
+			o = NewContextOperator[*beginnerMock[*savepointMock], *savepointMock](b)
+		)
+		return tx, NewTransactor[*beginnerMock[*savepointMock], *savepointMock](b, o)
+	}
+
+	t.Run("nested_error_rolls_back_to_savepoint_and_outer_commits", func(t *testing.T) {
+		var (
+			c      calls
+			ctx    = context.Background()
+			expErr = fmt.Errorf("nested action failed")
+			_, tr  = newInstance(&c)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			nestedErr := tr.WithinTx(ctx, func(context.Context) error {
+				return expErr
+			})
+			assert.ErrorIs(t, nestedErr, ErrRollbackToSavepointSuccess)
+			assert.ErrorIs(t, nestedErr, expErr)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, c.savepoint)
+		assert.Equal(t, 1, c.rollbackTo)
+		assert.Equal(t, 0, c.release)
+		assert.Equal(t, 1, c.commit)
+		assert.Equal(t, 0, c.rollback)
+	})
+	t.Run("savepoints_not_supported_join", func(t *testing.T) {
+		var (
+			c      calls
+			ctx    = context.Background()
+			expErr = fmt.Errorf("nested action failed")
+			sp, _  = newInstance(&c)
+			tx     = &savepointCapableMock{savepointMock: *sp}
+			b      = &beginnerMock[*savepointCapableMock]{
+				beginFn: func(context.Context) (*savepointCapableMock, error) {
+					return tx, nil
+				},
+			}
+			tr = NewTransactor[*beginnerMock[*savepointCapableMock], *savepointCapableMock](
+				b,
+				NewContextOperator[*beginnerMock[*savepointCapableMock], *savepointCapableMock](b),
+			)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			nestedErr := tr.WithinTx(ctx, func(context.Context) error {
+				return expErr
+			})
+			assert.ErrorIsNot(t, nestedErr, ErrRollbackToSavepointSuccess)
+			assert.ErrorIs(t, nestedErr, expErr)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, c.savepoint)
+		assert.Equal(t, 0, c.rollbackTo)
+		assert.Equal(t, 1, c.commit)
+	})
+	t.Run("nested_success_releases_savepoint", func(t *testing.T) {
+		var (
+			c     calls
+			ctx   = context.Background()
+			_, tr = newInstance(&c)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			return tr.WithinTx(ctx, func(ctx context.Context) error {
+				return tr.WithinTx(ctx, func(context.Context) error {
+					return nil
+				})
+			})
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, c.savepoint)
+		assert.Equal(t, 2, c.release)
+		assert.Equal(t, 0, c.rollbackTo)
+		assert.Equal(t, 1, c.commit)
+		assert.Len(t, c.names, 0)
+	})
+	t.Run("nested_panic_rolls_back_to_savepoint", func(t *testing.T) {
+		const (
+			expPanicMsg = "nested_panic"
+		)
+		var (
+			c     calls
+			ctx   = context.Background()
+			_, tr = newInstance(&c)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			nestedErr := tr.WithinTx(ctx, func(context.Context) error {
+				panic(expPanicMsg)
+			})
+			assert.ErrorIs(t, nestedErr, ErrRollbackToSavepointSuccess)
+			assert.ErrorIs(t, nestedErr, ErrPanicRecovered)
+			assert.True(t, strings.Contains(nestedErr.Error(), expPanicMsg))
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, c.rollbackTo)
+		assert.Equal(t, 1, c.commit)
+	})
+	t.Run("outer_rolls_back_when_nested_error_is_returned", func(t *testing.T) {
+		var (
+			c      calls
+			ctx    = context.Background()
+			expErr = fmt.Errorf("nested action failed")
+			_, tr  = newInstance(&c)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			return tr.WithinTx(ctx, func(context.Context) error {
+				return expErr
+			})
+		})
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.ErrorIs(t, err, ErrRollbackToSavepointSuccess)
+		assert.ErrorIs(t, err, expErr)
+		assert.Equal(t, 1, c.rollbackTo)
+		assert.Equal(t, 1, c.rollback)
+		assert.Equal(t, 0, c.commit)
+	})
+	t.Run("failed_savepoint", func(t *testing.T) {
+		var (
+			c      calls
+			ctx    = context.Background()
+			expErr = fmt.Errorf("savepoint error")
+			tx, tr = newInstance(&c)
+			called bool
+		)
+		tx.savepointFn = func(context.Context, string) error {
+			return expErr
+		}
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			return tr.WithinTx(ctx, func(context.Context) error {
+				called = true
+				return nil
+			})
+		})
+		assert.ErrorIs(t, err, ErrSavepointFailed)
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.ErrorIs(t, err, expErr)
+		assert.False(t, called)
+	})
+	t.Run("failed_rollback_to_savepoint", func(t *testing.T) {
+		var (
+			c       calls
+			ctx     = context.Background()
+			expErr  = fmt.Errorf("nested action failed")
+			rbErr   = fmt.Errorf("rollback to error")
+			tx, tr  = newInstance(&c)
+			nestErr error
+		)
+		tx.rollbackToFn = func(context.Context, string) error {
+			return rbErr
+		}
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			nestErr = tr.WithinTx(ctx, func(context.Context) error {
+				return expErr
+			})
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.ErrorIs(t, nestErr, ErrRollbackToSavepointFailed)
+		assert.ErrorIs(t, nestErr, rbErr)
+		assert.ErrorIs(t, nestErr, expErr)
+	})
+	t.Run("failed_release_savepoint", func(t *testing.T) {
+		var (
+			c      calls
+			ctx    = context.Background()
+			relErr = fmt.Errorf("release error")
+			tx, tr = newInstance(&c)
+		)
+		tx.releaseFn = func(context.Context, string) error {
+			return relErr
+		}
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			return tr.WithinTx(ctx, func(context.Context) error {
+				return nil
+			})
+		})
+		assert.ErrorIs(t, err, ErrReleaseSavepointFailed)
+		assert.ErrorIs(t, err, relErr)
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.Equal(t, 0, c.commit)
+	})
+}
+
+func Test_Transactor_propagation(t *testing.T) {
+	type calls struct {
+		begin, commit, rollback int
+	}
+	newInstance := func(c *calls) (
+		*ContextOperator[*beginnerMock[*committerMock], *committerMock],
+		*Transactor[*beginnerMock[*committerMock], *committerMock]) {
+		var (
+			b = &beginnerMock[*committerMock]{
+				beginFn: func(context.Context) (*committerMock, error) {
+					c.begin++
+					return &committerMock{
+						commitFn: func(context.Context) error {
+							c.commit++
+							return nil
+						},
+						rollbackFn: func(context.Context) error {
+							c.rollback++
+							return nil
+						},
+					}, nil
+				},
+			}
+			o = NewContextOperator[*beginnerMock[*committerMock], *committerMock](b)
+		)
+		return o, NewTransactor[*beginnerMock[*committerMock], *committerMock](b, o)
+	}
+
+	t.Run("required_is_default", func(t *testing.T) {
+		var (
+			c     calls
+			ctx   = context.Background()
+			o, tr = newInstance(&c)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			outerTx, _ := o.Extract(ctx)
+			return tr.WithPropagation(PropagationRequired).WithinTx(ctx, func(ctx context.Context) error {
+				innerTx, ok := o.Extract(ctx)
+				assert.True(t, ok)
+				assert.True(t, outerTx == innerTx)
+				return nil
+			})
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, c.begin)
+		assert.Equal(t, 1, c.commit)
+	})
+	t.Run("requires_new_commits_independently", func(t *testing.T) {
+		var (
+			c      calls
+			ctx    = context.Background()
+			expErr = fmt.Errorf("business error")
+			o, tr  = newInstance(&c)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			outerTx, _ := o.Extract(ctx)
+			err := tr.WithPropagation(PropagationRequiresNew).WithinTx(ctx, func(ctx context.Context) error {
+				innerTx, ok := o.Extract(ctx)
+				assert.True(t, ok)
+				assert.True(t, outerTx != innerTx)
+				return nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, 1, c.commit)
+
+			resumedTx, ok := o.Extract(ctx)
+			assert.True(t, ok)
+			assert.True(t, outerTx == resumedTx)
+			return expErr
+		})
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.ErrorIs(t, err, expErr)
+		assert.Equal(t, 2, c.begin)
+		assert.Equal(t, 1, c.commit)
+		assert.Equal(t, 1, c.rollback)
+	})
+	t.Run("mandatory", func(t *testing.T) {
+		t.Run("error_without_tx", func(t *testing.T) {
+			var (
+				c      calls
+				called bool
+				ctx    = context.Background()
+				_, tr  = newInstance(&c)
+			)
+			err := tr.WithPropagation(PropagationMandatory).WithinTx(ctx, func(context.Context) error {
+				called = true
+				return nil
+			})
+			assert.ErrorIs(t, err, ErrTxNotFound)
+			assert.False(t, called)
+			assert.Equal(t, 0, c.begin)
+		})
+		t.Run("reuse_tx", func(t *testing.T) {
+			var (
+				c     calls
+				ctx   = context.Background()
+				o, tr = newInstance(&c)
+			)
+			err := tr.WithinTx(ctx, func(ctx context.Context) error {
+				outerTx, _ := o.Extract(ctx)
+				return tr.WithPropagation(PropagationMandatory).WithinTx(ctx, func(ctx context.Context) error {
+					innerTx, ok := o.Extract(ctx)
+					assert.True(t, ok)
+					assert.True(t, outerTx == innerTx)
+					return nil
+				})
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, 1, c.begin)
+			assert.Equal(t, 1, c.commit)
+		})
+	})
+	t.Run("never", func(t *testing.T) {
+		t.Run("error_with_tx", func(t *testing.T) {
+			var (
+				c     calls
+				ctx   = context.Background()
+				_, tr = newInstance(&c)
+			)
+			err := tr.WithinTx(ctx, func(ctx context.Context) error {
+				return tr.WithPropagation(PropagationNever).WithinTx(ctx, func(context.Context) error {
+					t.Fatalf("should not have been called")
+					return nil
+				})
+			})
+			assert.ErrorIs(t, err, ErrTxExists)
+			assert.ErrorIs(t, err, ErrRollbackSuccess)
+		})
+		t.Run("call_without_tx", func(t *testing.T) {
+			var (
+				c     calls
+				ctx   = context.Background()
+				o, tr = newInstance(&c)
+			)
+			err := tr.WithPropagation(PropagationNever).WithinTx(ctx, func(ctx context.Context) error {
+				_, ok := o.Extract(ctx)
+				assert.False(t, ok)
+				return nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, 0, c.begin)
+		})
+	})
+	t.Run("supports", func(t *testing.T) {
+		t.Run("call_without_tx", func(t *testing.T) {
+			var (
+				c      calls
+				ctx    = context.Background()
+				expErr = fmt.Errorf("some error")
+				o, tr  = newInstance(&c)
+			)
+			err := tr.WithPropagation(PropagationSupports).WithinTx(ctx, func(ctx context.Context) error {
+				_, ok := o.Extract(ctx)
+				assert.False(t, ok)
+				return expErr
+			})
+			assert.ErrorIs(t, err, expErr)
+			assert.ErrorIsNot(t, err, ErrRollbackSuccess)
+			assert.Equal(t, 0, c.begin)
+		})
+		t.Run("reuse_tx", func(t *testing.T) {
+			var (
+				c     calls
+				ctx   = context.Background()
+				o, tr = newInstance(&c)
+			)
+			err := tr.WithinTx(ctx, func(ctx context.Context) error {
+				return tr.WithPropagation(PropagationSupports).WithinTx(ctx, func(ctx context.Context) error {
+					_, ok := o.Extract(ctx)
+					assert.True(t, ok)
+					return nil
+				})
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, 1, c.begin)
+			assert.Equal(t, 1, c.commit)
+		})
+		t.Run("panic_recovered_without_tx", func(t *testing.T) {
+			const (
+				expPanicMsg = "some_panic"
+			)
+			var (
+				c     calls
+				ctx   = context.Background()
+				_, tr = newInstance(&c)
+			)
+			err := tr.WithPropagation(PropagationSupports).WithinTx(ctx, func(context.Context) error {
+				panic(expPanicMsg)
+			})
+			assert.ErrorIs(t, err, ErrPanicRecovered)
+			assert.True(t, strings.Contains(err.Error(), expPanicMsg))
+		})
+	})
+	t.Run("not_supported", func(t *testing.T) {
+		t.Run("call_with_removed_tx", func(t *testing.T) {
+			var (
+				c     calls
+				ctx   = context.Background()
+				o, tr = newInstance(&c)
+			)
+			err := tr.WithinTx(ctx, func(ctx context.Context) error {
+				err := tr.WithPropagation(PropagationNotSupported).WithinTx(ctx, func(ctx context.Context) error {
+					_, ok := o.Extract(ctx)
+					assert.False(t, ok)
+					return nil
+				})
+				assert.NoError(t, err)
+
+				_, ok := o.Extract(ctx)
+				assert.True(t, ok)
+				return nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, 1, c.begin)
+			assert.Equal(t, 1, c.commit)
+		})
+		t.Run("error_when_operator_is_not_remover", func(t *testing.T) {
+			var (
+				c     calls
+				ctx   = context.Background()
+				o, tr = newInstance(&c)
+			)
+			tr.operator = struct {
+				CtxOperator[*committerMock]
+			}{o}
+			err := tr.WithinTx(ctx, func(ctx context.Context) error {
+				return tr.WithPropagation(PropagationNotSupported).WithinTx(ctx, func(context.Context) error {
+					t.Fatalf("should not have been called")
+					return nil
+				})
+			})
+			assert.ErrorIs(t, err, ErrCtxRemoveNotSupported)
+		})
+	})
+	t.Run("non_transactional_call_within_tx_of_another_beginner", func(t *testing.T) {
+		for _, propagation := range []Propagation{PropagationNever, PropagationSupports, PropagationNotSupported} {
+			t.Run(propagation.String(), func(t *testing.T) {
+				var (
+					outerCalls, innerCalls calls
+					ctx                    = context.Background()
+					_, outer               = newInstance(&outerCalls)
+					_, inner               = newInstance(&innerCalls)
+					called                 bool
+				)
+				err := outer.WithinTx(ctx, func(ctx context.Context) error {
+					return inner.WithPropagation(propagation).WithinTx(ctx, func(ctx context.Context) error {
+						assert.ErrorIs(t, AfterCommit(ctx, func(context.Context) error {
+							called = true
+							return nil
+						}), ErrTxNotFound)
+						assert.ErrorIs(t, BeforeCommit(ctx, func(context.Context) error {
+							called = true
+							return nil
+						}), ErrTxNotFound)
+						return nil
+					})
+				})
+				assert.NoError(t, err)
+				assert.False(t, called)
+				assert.Equal(t, 1, outerCalls.commit)
+				assert.Equal(t, 0, innerCalls.begin)
+			})
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		var (
+			c     calls
+			ctx   = context.Background()
+			_, tr = newInstance(&c)
+		)
+		err := tr.WithPropagation(Propagation(100)).WithinTx(ctx, func(context.Context) error {
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrInvalidPropagation)
+		assert.True(t, strings.Contains(err.Error(), "Propagation(100)"))
+	})
+	t.Run("original_transactor_is_not_modified", func(t *testing.T) {
+		var (
+			c     calls
+			_, tr = newInstance(&c)
+		)
+		derived := tr.WithPropagation(PropagationNever)
+		assert.Equal(t, PropagationRequired, tr.propagation)
+		assert.Equal(t, PropagationNever, derived.propagation)
+	})
+}
+
+func Test_Transactor_TxOptions(t *testing.T) {
+	type calls struct {
+		begin, beginWithOptions int
+		options                 []TxOptions
+	}
+	newCommitter := func() *committerMock {
+		return &committerMock{
+			commitFn: func(context.Context) error {
+				return nil
+			},
+			rollbackFn: func(context.Context) error {
+				return nil
+			},
+		}
+	}
+	newInstance := func(c *calls) *Transactor[*beginnerWithOptionsMock[*committerMock], *committerMock] {
+		var (
+			b = &beginnerWithOptionsMock[*committerMock]{
+				beginnerMock: beginnerMock[*committerMock]{
+					beginFn: func(context.Context) (*committerMock, error) {
+						c.begin++
+						return newCommitter(), nil
+					},
+				},
+				beginWithOptionsFn: func(_ context.Context, opts TxOptions) (*committerMock, error) {
+					c.beginWithOptions++
+					c.options = append(c.options, opts)
+					return newCommitter(), nil
+				},
+			}
+			o = NewContextOperator[*beginnerWithOptionsMock[*committerMock], *committerMock](b)
+		)
+		return NewTransactor[*beginnerWithOptionsMock[*committerMock], *committerMock](b, o)
+	}
+
+	t.Run("default_options_use_BeginTx", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+			tr  = newInstance(&c)
+		)
+		err := tr.WithinTx(ctx, func(context.Context) error {
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, c.begin)
+		assert.Equal(t, 0, c.beginWithOptions)
+	})
+	t.Run("options_use_BeginTxWithOptions", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+			tr  = newInstance(&c)
+		)
+		err := tr.WithTxOptions(Isolation(LevelRepeatableRead), ReadOnly()).
+			WithTxOptions(Deferrable()).
+			WithinTx(ctx, func(context.Context) error {
+				return nil
+			})
+		assert.NoError(t, err)
+		assert.Equal(t, 0, c.begin)
+		assert.Equal(t, 1, c.beginWithOptions)
+		assert.Equal(t, TxOptions{
+			Isolation:  LevelRepeatableRead,
+			ReadOnly:   true,
+			Deferrable: true,
+		}, c.options[0])
+		assert.Equal(t, TxOptions{}, tr.txOptions)
+	})
+	t.Run("error_when_options_are_not_supported", func(t *testing.T) {
+		var (
+			ctx = context.Background()
+			b   = &beginnerMock[*committerMock]{
+				beginFn: func(context.Context) (*committerMock, error) {
+					t.Fatalf("should not have been called")
+					return nil, nil
+				},
+			}
+			o  = NewContextOperator[*beginnerMock[*committerMock], *committerMock](b)
+			tr = NewTransactor[*beginnerMock[*committerMock], *committerMock](b, o)
+		)
+		err := tr.WithTxOptions(ReadOnly()).WithinTx(ctx, func(context.Context) error {
+			t.Fatalf("should not have been called")
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrBeginTx)
+		assert.ErrorIs(t, err, ErrTxOptionsNotSupported)
+	})
+	t.Run("nested_call", func(t *testing.T) {
+		testCases := []struct {
+			name     string
+			active   IsolationLevel
+			required IsolationLevel
+			expErr   bool
+		}{
+			{name: "default_in_default", active: LevelDefault, required: LevelDefault},
+			{name: "default_in_serializable", active: LevelSerializable, required: LevelDefault},
+			{name: "equal", active: LevelRepeatableRead, required: LevelRepeatableRead},
+			{name: "weaker", active: LevelSerializable, required: LevelReadCommitted},
+			{name: "stricter", active: LevelReadCommitted, required: LevelSerializable, expErr: true},
+			{name: "stricter_than_default", active: LevelDefault, required: LevelReadCommitted, expErr: true},
+		}
+		for i, testCase := range testCases {
+			t.Run(fmt.Sprintf("%d_%s", i, testCase.name), func(t *testing.T) {
+				var (
+					c      calls
+					called bool
+					ctx    = context.Background()
+					tr     = newInstance(&c)
+				)
+				err := tr.WithTxOptions(Isolation(testCase.active)).WithinTx(ctx, func(ctx context.Context) error {
+					return tr.WithTxOptions(Isolation(testCase.required)).WithinTx(ctx, func(context.Context) error {
+						called = true
+						return nil
+					})
+				})
+				if !testCase.expErr {
+					assert.NoError(t, err)
+					assert.True(t, called)
+					return
+				}
+
+				var isolationErr *IsolationLevelError
+				assert.True(t, errors.As(err, &isolationErr))
+				assert.Equal(t, testCase.active, isolationErr.Active)
+				assert.Equal(t, testCase.required, isolationErr.Required)
+				assert.ErrorIs(t, err, ErrIsolationLevel)
+				assert.ErrorIs(t, err, ErrRollbackSuccess)
+				assert.False(t, called)
+			})
+		}
+	})
+	t.Run("requires_new_with_stricter_isolation", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+			tr  = newInstance(&c)
+		)
+		err := tr.WithTxOptions(Isolation(LevelReadCommitted)).WithinTx(ctx, func(ctx context.Context) error {
+			return tr.WithPropagation(PropagationRequiresNew).
+				WithTxOptions(Isolation(LevelSerializable)).
+				WithinTx(ctx, func(context.Context) error {
+					return nil
+				})
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, c.beginWithOptions)
+		assert.Equal(t, LevelSerializable, c.options[1].Isolation)
+	})
+}
+
+func Test_Transactor_WithTxTimeout(t *testing.T) {
+	type calls struct {
+		commit, rollback int
+		rollbackCtxErr   error
+		beginDeadline    bool
+	}
+	newInstance := func(c *calls) *Transactor[*beginnerMock[*committerMock], *committerMock] {
+		b := &beginnerMock[*committerMock]{
+			beginFn: func(ctx context.Context) (*committerMock, error) {
+				_, c.beginDeadline = ctx.Deadline()
+				return &committerMock{
+					commitFn: func(context.Context) error {
+						c.commit++
+						return nil
+					},
+					rollbackFn: func(ctx context.Context) error {
+						c.rollback++
+						c.rollbackCtxErr = ctx.Err()
+						return nil
+					},
+				}, nil
+			},
+		}
+		return NewTransactor[*beginnerMock[*committerMock], *committerMock](
+			b,
+			NewContextOperator[*beginnerMock[*committerMock], *committerMock](b),
+		)
+	}
+
+	t.Run("swallowed_ctx_error", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+			tr  = newInstance(&c).
+				WithTxTimeout(10 * time.Millisecond).
+				WithRollbackCtxFactory(context.WithoutCancel)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrTxTimeout)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.True(t, c.beginDeadline)
+		assert.Equal(t, 0, c.commit)
+		assert.Equal(t, 1, c.rollback)
+		assert.NoError(t, c.rollbackCtxErr)
+	})
+	t.Run("returned_ctx_error", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+			tr  = newInstance(&c).WithTxTimeout(10 * time.Millisecond)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		assert.ErrorIs(t, err, ErrTxTimeout)
+		assert.Equal(t, 1, c.rollback)
+		assert.ErrorIs(t, c.rollbackCtxErr, context.DeadlineExceeded)
+	})
+	t.Run("within_timeout", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+			tr  = newInstance(&c).WithTxTimeout(time.Minute)
+		)
+		err := tr.WithinTx(ctx, func(context.Context) error {
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, c.commit)
+
+		c = calls{}
+		err = tr.WithTxTimeout(0).WithinTx(ctx, func(context.Context) error {
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.False(t, c.beginDeadline)
+	})
+	t.Run("nested_calls_do_not_extend_deadline", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+			tr  = newInstance(&c).WithTxTimeout(time.Minute)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			deadline, _ := ctx.Deadline()
+			// the timeout of the joined call is ignored.
+			err := tr.WithTxTimeout(time.Hour).WithinTx(ctx, func(ctx context.Context) error {
+				nested, ok := ctx.Deadline()
+				assert.True(t, ok)
+				assert.Equal(t, deadline, nested)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			// the new transaction cannot outlive the parent deadline.
+			return tr.WithPropagation(PropagationRequiresNew).WithTxTimeout(time.Hour).WithinTx(ctx, func(ctx context.Context) error {
+				nested, ok := ctx.Deadline()
+				assert.True(t, ok)
+				assert.False(t, nested.After(deadline))
+				return nil
+			})
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, c.commit)
+	})
+	t.Run("parent_deadline_is_not_tx_timeout", func(t *testing.T) {
+		var (
+			c           calls
+			ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+			tr          = newInstance(&c).WithTxTimeout(time.Minute)
+		)
+		defer cancel()
+
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorIsNot(t, err, ErrTxTimeout)
+		assert.Equal(t, 1, c.rollback)
+	})
+}
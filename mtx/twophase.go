@@ -0,0 +1,576 @@
+package mtx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kozmod/oniontx/internal/errors"
+)
+
+// DefaultTwoPhaseIDPrefix is the default prefix of the global transaction identifiers of TwoPhaseCoordinator.
+const DefaultTwoPhaseIDPrefix = "oniontx"
+
+var (
+	// ErrNilDecisionLog indicates that TwoPhaseCoordinator was created without a DecisionLog.
+	ErrNilDecisionLog = fmt.Errorf("decision log is nil")
+
+	// ErrPrepareNotSupported indicates that the transaction of a participant does not implement PreparableTx.
+	ErrPrepareNotSupported = fmt.Errorf("prepare is not supported")
+
+	// ErrPrepareFailed indicates that a participant failed to prepare its transaction,
+	// so all transactions were rolled back.
+	ErrPrepareFailed = fmt.Errorf("prepare failed")
+
+	// ErrDecisionLogFailed indicates that the commit decision cannot be stored in the DecisionLog,
+	// so all prepared transactions were rolled back.
+	ErrDecisionLogFailed = fmt.Errorf("decision log failed")
+
+	// ErrInDoubt indicates that the commit decision was made, but some prepared transactions
+	// were not committed. They stay prepared (in doubt) until TwoPhaseCoordinator.Recover commits them.
+	ErrInDoubt = fmt.Errorf("transaction in doubt")
+
+	// ErrRecoveryFailed indicates that TwoPhaseCoordinator.Recover failed to resolve some in-doubt transactions.
+	ErrRecoveryFailed = fmt.Errorf("recovery failed")
+)
+
+type (
+	// PreparableTx is a transaction that supports the two-phase commit
+	// (for example, PostgreSQL PREPARE TRANSACTION or MySQL XA).
+	//
+	// After Prepare succeeds, the transaction is completed only by CommitPrepared or RollbackPrepared
+	// with the same global transaction identifier (gid): Commit and Rollback are not called.
+	PreparableTx interface {
+		Tx
+		Prepare(ctx context.Context, gid string) error
+		CommitPrepared(ctx context.Context, gid string) error
+		RollbackPrepared(ctx context.Context, gid string) error
+	}
+
+	// PreparedResolver completes the prepared transactions of a participant without the transaction instance
+	// (for example, after a crash). It is used by TwoPhaseCoordinator.Recover.
+	PreparedResolver interface {
+		// PreparedIDs returns the global transaction identifiers of the prepared transactions
+		// (for example, from pg_prepared_xacts).
+		PreparedIDs(ctx context.Context) ([]string, error)
+		CommitPrepared(ctx context.Context, gid string) error
+		RollbackPrepared(ctx context.Context, gid string) error
+	}
+
+	// DecisionLog durably stores the commit decisions of TwoPhaseCoordinator.
+	// Only the commit decisions are stored (presumed abort): a prepared transaction
+	// without a decision is rolled back by the recovery.
+	DecisionLog interface {
+		// Log stores the decision. The decision must be durable when Log returns.
+		Log(ctx context.Context, decision Decision) error
+		// Forget removes the decision after all prepared transactions are committed.
+		Forget(ctx context.Context, id string) error
+		// Decisions returns all stored decisions.
+		Decisions(ctx context.Context) ([]Decision, error)
+	}
+)
+
+// Decision is the commit decision of a global transaction.
+type Decision struct {
+	// ID is the identifier of the global transaction.
+	ID string
+	// Participants contains the names of the participants.
+	Participants []string
+}
+
+// RecoveryResult contains the global transaction identifiers of the participant transactions
+// resolved by TwoPhaseCoordinator.Recover.
+type RecoveryResult struct {
+	Committed  []string
+	RolledBack []string
+}
+
+// MemoryDecisionLog is an in-memory DecisionLog.
+// It is not durable, so it is useful only for tests.
+type MemoryDecisionLog struct {
+	mu        sync.Mutex
+	decisions map[string]Decision
+}
+
+// NewMemoryDecisionLog creates a new MemoryDecisionLog.
+func NewMemoryDecisionLog() *MemoryDecisionLog {
+	return &MemoryDecisionLog{
+		decisions: make(map[string]Decision),
+	}
+}
+
+// Log stores the decision.
+func (l *MemoryDecisionLog) Log(_ context.Context, decision Decision) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	decision.Participants = slices.Clone(decision.Participants)
+	l.decisions[decision.ID] = decision
+	return nil
+}
+
+// Forget removes the decision.
+func (l *MemoryDecisionLog) Forget(_ context.Context, id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.decisions, id)
+	return nil
+}
+
+// Decisions returns the stored decisions ordered by ID.
+func (l *MemoryDecisionLog) Decisions(_ context.Context) ([]Decision, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	decisions := make([]Decision, 0, len(l.decisions))
+	for _, decision := range l.decisions {
+		decision.Participants = slices.Clone(decision.Participants)
+		decisions = append(decisions, decision)
+	}
+	slices.SortFunc(decisions, func(a, b Decision) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return decisions, nil
+}
+
+// participant is a Resource registered in TwoPhaseCoordinator.
+type participant struct {
+	namedResource
+	resolver PreparedResolver
+}
+
+// TwoPhaseCoordinator manages transactions of several participants using the two-phase commit.
+//
+// The coordinator begins the transactions of all participants and injects them into the context
+// (as MultiTransactor does). After the function succeeds, it prepares all transactions (phase one),
+// stores the commit decision in the DecisionLog and then commits all prepared transactions (phase two).
+// If any transaction cannot be prepared, all transactions are rolled back.
+//
+// The transactions of all participants must implement PreparableTx.
+// The transactions which already exist in the context cannot participate (ErrTxExists).
+type TwoPhaseCoordinator struct {
+	log                DecisionLog
+	participants       []participant
+	prefix             string
+	seq                *atomic.Uint64
+	hookOrder          HookOrder
+	rollbackCtxFactory func(ctx context.Context) context.Context
+	panicPolicy        PanicPolicy
+	observer           TxObserver
+}
+
+// NewTwoPhaseCoordinator creates a new TwoPhaseCoordinator without participants
+// (see TwoPhaseCoordinator.WithParticipant) with DefaultTwoPhaseIDPrefix.
+func NewTwoPhaseCoordinator(log DecisionLog) *TwoPhaseCoordinator {
+	return &TwoPhaseCoordinator{
+		log:    log,
+		prefix: DefaultTwoPhaseIDPrefix,
+		seq:    &atomic.Uint64{},
+		rollbackCtxFactory: func(ctx context.Context) context.Context {
+			return ctx
+		},
+	}
+}
+
+// WithParticipant returns a new TwoPhaseCoordinator with the registered participant.
+// The resolver is used by TwoPhaseCoordinator.Recover; a participant without a resolver is not recovered.
+// The original TwoPhaseCoordinator is not modified.
+func (c *TwoPhaseCoordinator) WithParticipant(name string, resource Resource, resolver PreparedResolver) *TwoPhaseCoordinator {
+	cp := *c
+	cp.participants = append(slices.Clone(c.participants), participant{
+		namedResource: namedResource{name: name, resource: resource},
+		resolver:      resolver,
+	})
+	return &cp
+}
+
+// WithIDPrefix returns a new TwoPhaseCoordinator with the prefix of the global transaction identifiers.
+// TwoPhaseCoordinator.Recover resolves only the prepared transactions with the prefix,
+// so the coordinators of different applications sharing a database must use different prefixes.
+// The original TwoPhaseCoordinator is not modified.
+func (c *TwoPhaseCoordinator) WithIDPrefix(prefix string) *TwoPhaseCoordinator {
+	cp := *c
+	cp.prefix = prefix
+	return &cp
+}
+
+// WithBeforeCommitOrder returns a new TwoPhaseCoordinator that calls the before-commit hooks in the given order.
+// The original TwoPhaseCoordinator is not modified.
+func (c *TwoPhaseCoordinator) WithBeforeCommitOrder(order HookOrder) *TwoPhaseCoordinator {
+	cp := *c
+	cp.hookOrder = order
+	return &cp
+}
+
+// WithRollbackCtxFactory returns a new TwoPhaseCoordinator that derives the context used for the rollbacks
+// (before the rollback context factories of the participants are applied) and the after-rollback callbacks.
+// If factory is nil or returns nil, the original operation context is used.
+// The original TwoPhaseCoordinator is not modified.
+func (c *TwoPhaseCoordinator) WithRollbackCtxFactory(factory func(ctx context.Context) context.Context) *TwoPhaseCoordinator {
+	cp := *c
+	cp.rollbackCtxFactory = func(ctx context.Context) context.Context {
+		if factory != nil {
+			if newCtx := factory(ctx); newCtx != nil {
+				return newCtx
+			}
+		}
+		return ctx
+	}
+	return &cp
+}
+
+// WithPanicPolicy returns a new TwoPhaseCoordinator that handles the panics of the function according to the policy
+// (see Transactor.WithPanicPolicy). PanicPolicyRepanic leaves the transactions of all participants open.
+// The original TwoPhaseCoordinator is not modified.
+func (c *TwoPhaseCoordinator) WithPanicPolicy(policy PanicPolicy) *TwoPhaseCoordinator {
+	cp := *c
+	cp.panicPolicy = policy
+	return &cp
+}
+
+// WithObserver returns a new TwoPhaseCoordinator that sends the lifecycle events to the observer
+// (see Transactor.WithObserver). The events describe all participants as one transaction:
+// the commit events enclose both phases. The observers of the participants are not called.
+// The original TwoPhaseCoordinator is not modified.
+func (c *TwoPhaseCoordinator) WithObserver(observer TxObserver) *TwoPhaseCoordinator {
+	cp := *c
+	cp.observer = observer
+	return &cp
+}
+
+// WithinTx executes the function within the transactions of all participants
+// and completes them using the two-phase commit.
+//
+// The callbacks (BeforeCommit, AfterCommit, AfterRollback) are shared by all participants.
+// After-commit callbacks are called only if all prepared transactions are committed,
+// after-rollback callbacks are called if the transactions are rolled back.
+// If the outcome is in doubt (ErrInDoubt), no callbacks are called.
+// The errors of the transactions are returned as TxError (see Transactor.WithinTx).
+//
+// Returns:
+//   - ErrBeginTx, ErrTxExists or ErrPrepareNotSupported if a transaction cannot be begun
+//     and the begun transactions are rolled back
+//   - ErrRollbackSuccess or ErrRollbackFailed (with ErrPanicRecovered on panic) if the function fails
+//   - ErrRollbackSuccess with ErrRollbackOnly if the transactions were marked by SetRollbackOnly
+//   - ErrPrepareFailed or ErrDecisionLogFailed if the transactions are rolled back during the commit
+//   - ErrCommitFailed and ErrInDoubt if some prepared transactions are not committed after the commit decision
+func (c *TwoPhaseCoordinator) WithinTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if c == nil {
+		return fmt.Errorf("two phase coordinator is nil")
+	}
+	if fn == nil {
+		return fmt.Errorf("two phase coordinator - can't execute: %w", ErrNilTxFunc)
+	}
+	if c.log == nil {
+		return fmt.Errorf("two phase coordinator - can't execute: %w", ErrNilDecisionLog)
+	}
+	resources := c.resources()
+	if _, err = indexResources(resources); err != nil {
+		return fmt.Errorf("two phase coordinator - can't execute: %w", err)
+	}
+
+	ctx, recorder := takeCommitRecorder(ctx)
+	var (
+		parentCtx = ctx
+		started   = make([]*resourceTx, len(resources))
+		state     = newTxState(TxOptions{}, &txCallbacks{})
+	)
+	err = c.observed(ctx, state, TxEventBeginStart, TxEventBeginEnd, func() error {
+		for i, r := range resources {
+			var (
+				rtx  *resourceTx
+				bErr error
+			)
+			ctx, rtx, bErr = r.resource.beginResource(ctx, state.txCallbacks)
+			switch {
+			case bErr != nil:
+			case rtx == nil:
+				bErr = ErrTxExists
+			case rtx.preparable == nil:
+				started[i] = rtx
+				bErr = ErrPrepareNotSupported
+			default:
+				started[i] = rtx
+			}
+			if bErr != nil {
+				return fmt.Errorf("participant [%s]: %w", r.name, bErr)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if rbErr := c.rollback(ctx, state, resources, started); rbErr != nil {
+			return newTxError(
+				"two phase coordinator - can't begin",
+				TxError{Phase: TxPhaseBegin, BeginErr: err, RollbackErr: rbErr},
+				err, ErrRollbackFailed, rbErr,
+			)
+		}
+		return newTxError("two phase coordinator - can't begin", TxError{Phase: TxPhaseBegin, BeginErr: err}, err)
+	}
+	ctx = injectCurrentState(ctx, state)
+
+	defer func() {
+		p := recover()
+		if p == nil && err == nil {
+			err = runBeforeCommit(ctx, state, c.hookOrder)
+		}
+
+		switch {
+		case p != nil:
+			pErr := errors.WrapPanic(p)
+			c.observe(ctx, state, TxEvent{Type: TxEventPanicRecovered, Err: pErr})
+			if c.panicPolicy == PanicPolicyRepanic {
+				panic(p)
+			}
+			if rbErr := c.rollback(ctx, state, resources, started); rbErr != nil {
+				err = newTxError(
+					"two phase coordinator - panic",
+					TxError{Phase: TxPhaseRollback, RollbackErr: rbErr, Panic: p, Stack: pErr.Stack()},
+					ErrRollbackFailed, ErrPanicRecovered, rbErr, pErr,
+				)
+			} else {
+				err = newTxError(
+					"two phase coordinator - panic",
+					TxError{Phase: TxPhaseFn, Panic: p, Stack: pErr.Stack()},
+					ErrRollbackSuccess, ErrPanicRecovered, pErr,
+				)
+			}
+			err = runAfterRollback(parentCtx, state, err, c.rollbackCtxFactory)
+			if c.panicPolicy == PanicPolicyRollbackRepanic {
+				panic(p)
+			}
+		case err != nil:
+			if rbErr := c.rollback(ctx, state, resources, started); rbErr != nil {
+				err = newTxError(
+					"two phase coordinator - call",
+					TxError{Phase: TxPhaseRollback, Err: err, RollbackErr: rbErr},
+					ErrRollbackFailed, rbErr, err,
+				)
+			} else {
+				err = newTxError("two phase coordinator - call", TxError{Phase: TxPhaseFn, Err: err}, ErrRollbackSuccess, err)
+			}
+			err = runAfterRollback(parentCtx, state, err, c.rollbackCtxFactory)
+		default:
+			var inDoubt bool
+			if cErr := c.observed(ctx, state, TxEventCommitStart, TxEventCommitEnd, func() (cErr error) {
+				inDoubt, cErr = c.commit(ctx, started)
+				return cErr
+			}); cErr != nil {
+				err = newTxError("two phase coordinator", TxError{Phase: TxPhaseCommit, CommitErr: cErr}, cErr)
+				if !inDoubt {
+					err = runAfterRollback(parentCtx, state, err, c.rollbackCtxFactory)
+				}
+				return
+			}
+			recorder.markCommitted()
+			err = runAfterCommit(parentCtx, state)
+		}
+	}()
+
+	err = fn(ctx)
+	return err
+}
+
+// rollback rolls back the started transactions of the participants in the reverse registration order.
+func (c *TwoPhaseCoordinator) rollback(ctx context.Context, state *txState, resources []namedResource, started []*resourceTx) error {
+	return c.observed(ctx, state, TxEventRollbackStart, TxEventRollbackEnd, func() error {
+		return rollbackResources(c.rollbackCtxFactory(ctx), resources, started, nil)
+	})
+}
+
+// observe sends the event to the observer (if any).
+func (c *TwoPhaseCoordinator) observe(ctx context.Context, state *txState, event TxEvent) {
+	observeTx(ctx, c.observer, state, event)
+}
+
+// observed calls the operation between the "Start" and "End" events.
+func (c *TwoPhaseCoordinator) observed(ctx context.Context, state *txState, start, end TxEventType, op func() error) error {
+	return observedTx(ctx, c.observer, state, start, end, op)
+}
+
+// commit prepares all transactions, logs the decision and commits the prepared transactions.
+// Reports whether the outcome is in doubt (the decision was made, but the commit failed).
+func (c *TwoPhaseCoordinator) commit(ctx context.Context, started []*resourceTx) (bool, error) {
+	var (
+		id    = c.newID()
+		names = make([]string, len(c.participants))
+		gids  = make([]string, len(c.participants))
+	)
+	for i, p := range c.participants {
+		names[i] = p.name
+		gids[i] = c.gid(id, p.name)
+	}
+
+	// phase one
+	for i, rtx := range started {
+		if pErr := rtx.preparable.Prepare(ctx, gids[i]); pErr != nil {
+			err := fmt.Errorf("participant [%s]: %w", names[i], pErr)
+			if rbErr := c.abort(ctx, started, gids, i); rbErr != nil {
+				return false, fmt.Errorf("prepare: %w", errors.Join(ErrPrepareFailed, err, ErrRollbackFailed, rbErr))
+			}
+			return false, fmt.Errorf("prepare: %w", errors.Join(ErrPrepareFailed, err, ErrRollbackSuccess))
+		}
+	}
+
+	// decision
+	if lErr := c.log.Log(ctx, Decision{ID: id, Participants: names}); lErr != nil {
+		if rbErr := c.abort(ctx, started, gids, len(started)); rbErr != nil {
+			return false, fmt.Errorf("decision: %w", errors.Join(ErrDecisionLogFailed, lErr, ErrRollbackFailed, rbErr))
+		}
+		return false, fmt.Errorf("decision: %w", errors.Join(ErrDecisionLogFailed, lErr, ErrRollbackSuccess))
+	}
+
+	// phase two
+	var errs []error
+	for i, rtx := range started {
+		if cErr := rtx.preparable.CommitPrepared(ctx, gids[i]); cErr != nil {
+			errs = append(errs, fmt.Errorf("participant [%s]: %w", names[i], cErr))
+		}
+	}
+	if len(errs) > 0 {
+		// the decision is kept: Recover commits the remaining prepared transactions.
+		return true, fmt.Errorf("commit prepared: %w", errors.Join(append([]error{ErrCommitFailed, ErrInDoubt}, errs...)...))
+	}
+
+	// the error is ignored: the decision without prepared transactions is removed by Recover.
+	_ = c.log.Forget(ctx, id)
+	return false, nil
+}
+
+// abort rolls back the transactions prepared before the failed one (with RollbackPrepared)
+// and the other transactions (with Rollback).
+func (c *TwoPhaseCoordinator) abort(ctx context.Context, started []*resourceTx, gids []string, failed int) error {
+	var (
+		errs        []error
+		rollbackCtx = c.rollbackCtxFactory(ctx)
+	)
+	for i := len(started) - 1; i >= 0; i-- {
+		var (
+			rtx   = started[i]
+			rbErr error
+		)
+		if i < failed {
+			rbErr = rtx.preparable.RollbackPrepared(rollbackCtx, gids[i])
+		} else {
+			rbErr = rtx.rollback(rollbackCtx)
+		}
+		if rbErr != nil {
+			errs = append(errs, fmt.Errorf("participant [%s]: %w", c.participants[i].name, rbErr))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Recover resolves the in-doubt transactions of the participants after a crash:
+// the prepared transactions with a commit decision are committed, the others are rolled back (presumed abort).
+// The decisions are removed from the DecisionLog when all their prepared transactions are committed.
+//
+// Recover must be called when no WithinTx call of a coordinator with the same ID prefix
+// is in progress (for example, on the application start), otherwise transactions
+// which are being prepared can be rolled back.
+func (c *TwoPhaseCoordinator) Recover(ctx context.Context) (RecoveryResult, error) {
+	var result RecoveryResult
+	if c.log == nil {
+		return result, fmt.Errorf("two phase coordinator - recover: %w", ErrNilDecisionLog)
+	}
+
+	decisions, err := c.log.Decisions(ctx)
+	if err != nil {
+		return result, fmt.Errorf("two phase coordinator - recover: %w", errors.Join(ErrRecoveryFailed, ErrDecisionLogFailed, err))
+	}
+
+	var (
+		errs       []error
+		committed  = make(map[string]bool, len(decisions))
+		unresolved = make(map[string]bool)
+		keep       = func(name string) {
+			for _, d := range decisions {
+				if slices.Contains(d.Participants, name) {
+					unresolved[d.ID] = true
+				}
+			}
+		}
+	)
+	for _, d := range decisions {
+		committed[d.ID] = true
+	}
+
+	for _, p := range c.participants {
+		if p.resolver == nil {
+			keep(p.name)
+			continue
+		}
+		gids, pErr := p.resolver.PreparedIDs(ctx)
+		if pErr != nil {
+			keep(p.name)
+			errs = append(errs, fmt.Errorf("participant [%s]: %w", p.name, pErr))
+			continue
+		}
+		for _, gid := range gids {
+			id, ok := c.parseGID(gid, p.name)
+			if !ok {
+				continue
+			}
+			if committed[id] {
+				if cErr := p.resolver.CommitPrepared(ctx, gid); cErr != nil {
+					unresolved[id] = true
+					errs = append(errs, fmt.Errorf("participant [%s] - commit [%s]: %w", p.name, gid, cErr))
+					continue
+				}
+				result.Committed = append(result.Committed, gid)
+				continue
+			}
+			if rbErr := p.resolver.RollbackPrepared(ctx, gid); rbErr != nil {
+				errs = append(errs, fmt.Errorf("participant [%s] - rollback [%s]: %w", p.name, gid, rbErr))
+				continue
+			}
+			result.RolledBack = append(result.RolledBack, gid)
+		}
+	}
+
+	for _, d := range decisions {
+		if unresolved[d.ID] {
+			continue
+		}
+		if fErr := c.log.Forget(ctx, d.ID); fErr != nil {
+			errs = append(errs, fmt.Errorf("decision [%s]: %w", d.ID, errors.Join(ErrDecisionLogFailed, fErr)))
+		}
+	}
+
+	if len(errs) > 0 {
+		return result, fmt.Errorf("two phase coordinator - recover: %w", errors.Join(append([]error{ErrRecoveryFailed}, errs...)...))
+	}
+	return result, nil
+}
+
+// resources returns the resources of the participants.
+func (c *TwoPhaseCoordinator) resources() []namedResource {
+	resources := make([]namedResource, len(c.participants))
+	for i, p := range c.participants {
+		resources[i] = p.namedResource
+	}
+	return resources
+}
+
+// newID returns a new global transaction identifier.
+func (c *TwoPhaseCoordinator) newID() string {
+	return fmt.Sprintf("%s_%d_%d", c.prefix, time.Now().UnixNano(), c.seq.Add(1))
+}
+
+// gid returns the global transaction identifier of the participant transaction.
+func (c *TwoPhaseCoordinator) gid(id, name string) string {
+	return id + "." + name
+}
+
+// parseGID returns the global transaction identifier from the identifier of the participant transaction.
+// Reports false if the identifier was not created by a coordinator with the same prefix.
+func (c *TwoPhaseCoordinator) parseGID(gid, name string) (string, bool) {
+	id, ok := strings.CutSuffix(gid, "."+name)
+	if !ok || !strings.HasPrefix(id, c.prefix+"_") {
+		return "", false
+	}
+	return id, true
+}
@@ -0,0 +1,405 @@
+package mtx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+)
+
+// fakeParticipant is an in-memory participant of the two-phase commit.
+type fakeParticipant struct {
+	name     string
+	events   *[]string
+	prepared map[string]bool
+
+	prepareErr        error
+	commitPreparedErr error
+
+	operator   *ContextOperator[*beginnerMock[*preparableMock], *preparableMock]
+	transactor *Transactor[*beginnerMock[*preparableMock], *preparableMock]
+	resolver   *resolverMock
+}
+
+func newFakeParticipant(name string, events *[]string) *fakeParticipant {
+	p := &fakeParticipant{
+		name:     name,
+		events:   events,
+		prepared: make(map[string]bool),
+	}
+	var (
+		commitPrepared = func(_ context.Context, gid string) error {
+			if p.commitPreparedErr != nil {
+				return p.commitPreparedErr
+			}
+			delete(p.prepared, gid)
+			p.event("commit_prepared")
+			return nil
+		}
+		rollbackPrepared = func(_ context.Context, gid string) error {
+			delete(p.prepared, gid)
+			p.event("rollback_prepared")
+			return nil
+		}
+		b = &beginnerMock[*preparableMock]{
+			beginFn: func(context.Context) (*preparableMock, error) {
+				p.event("begin")
+				return &preparableMock{
+					committerMock: committerMock{
+						commitFn: func(context.Context) error {
+							p.event("commit")
+							return nil
+						},
+						rollbackFn: func(context.Context) error {
+							p.event("rollback")
+							return nil
+						},
+					},
+					prepareFn: func(_ context.Context, gid string) error {
+						if p.prepareErr != nil {
+							return p.prepareErr
+						}
+						p.prepared[gid] = true
+						p.event("prepare")
+						return nil
+					},
+					commitPreparedFn:   commitPrepared,
+					rollbackPreparedFn: rollbackPrepared,
+				}, nil
+			},
+		}
+	)
+	p.operator = NewContextOperator[*beginnerMock[*preparableMock], *preparableMock](b)
+	p.transactor = NewTransactor[*beginnerMock[*preparableMock], *preparableMock](b, p.operator)
+	p.resolver = &resolverMock{
+		preparedIDsFn: func(context.Context) ([]string, error) {
+			gids := make([]string, 0, len(p.prepared))
+			for gid := range p.prepared {
+				gids = append(gids, gid)
+			}
+			slices.Sort(gids)
+			return gids, nil
+		},
+		commitPreparedFn:   commitPrepared,
+		rollbackPreparedFn: rollbackPrepared,
+	}
+	return p
+}
+
+func (p *fakeParticipant) event(name string) {
+	*p.events = append(*p.events, name+":"+p.name)
+}
+
+func Test_TwoPhaseCoordinator(t *testing.T) {
+	newCoordinator := func(log DecisionLog, participants ...*fakeParticipant) *TwoPhaseCoordinator {
+		c := NewTwoPhaseCoordinator(log)
+		for _, p := range participants {
+			c = c.WithParticipant(p.name, p.transactor, p.resolver)
+		}
+		return c
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		var (
+			events      []string
+			ctx         = context.Background()
+			log         = NewMemoryDecisionLog()
+			a, b        = newFakeParticipant("a", &events), newFakeParticipant("b", &events)
+			coordinator = newCoordinator(log, a, b)
+		)
+		err := coordinator.WithinTx(ctx, func(ctx context.Context) error {
+			_, ok := a.operator.Extract(ctx)
+			assert.True(t, ok)
+			_, ok = b.operator.Extract(ctx)
+			assert.True(t, ok)
+			return AfterCommit(ctx, func(context.Context) error {
+				events = append(events, "after_commit")
+				return nil
+			})
+		})
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal(
+			[]string{"begin:a", "begin:b", "prepare:a", "prepare:b", "commit_prepared:a", "commit_prepared:b", "after_commit"},
+			events,
+		))
+		decisions, err := log.Decisions(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, decisions, 0)
+	})
+	t.Run("prepare_failed", func(t *testing.T) {
+		var (
+			events      []string
+			ctx         = context.Background()
+			log         = NewMemoryDecisionLog()
+			a, b, c     = newFakeParticipant("a", &events), newFakeParticipant("b", &events), newFakeParticipant("c", &events)
+			coordinator = newCoordinator(log, a, b, c)
+			expErr      = fmt.Errorf("prepare error")
+			rolledBack  bool
+		)
+		b.prepareErr = expErr
+		err := coordinator.WithinTx(ctx, func(ctx context.Context) error {
+			return AfterRollback(ctx, func(context.Context) error {
+				rolledBack = true
+				return nil
+			})
+		})
+		assert.ErrorIs(t, err, ErrPrepareFailed)
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.ErrorIs(t, err, expErr)
+		assert.True(t, rolledBack)
+		assert.True(t, slices.Equal(
+			[]string{"begin:a", "begin:b", "begin:c", "prepare:a", "rollback:c", "rollback:b", "rollback_prepared:a"},
+			events,
+		))
+		assert.Len(t, a.prepared, 0)
+	})
+	t.Run("decision_log_failed", func(t *testing.T) {
+		var (
+			events = []string{}
+			ctx    = context.Background()
+			expErr = fmt.Errorf("log error")
+			log    = &decisionLogMock{
+				MemoryDecisionLog: NewMemoryDecisionLog(),
+				logFn: func(context.Context, Decision) error {
+					return expErr
+				},
+			}
+			a, b        = newFakeParticipant("a", &events), newFakeParticipant("b", &events)
+			coordinator = newCoordinator(log, a, b)
+		)
+		err := coordinator.WithinTx(ctx, func(context.Context) error {
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrDecisionLogFailed)
+		assert.ErrorIs(t, err, expErr)
+		assert.True(t, slices.Equal(
+			[]string{"begin:a", "begin:b", "prepare:a", "prepare:b", "rollback_prepared:b", "rollback_prepared:a"},
+			events,
+		))
+	})
+	t.Run("in_doubt_and_recover", func(t *testing.T) {
+		var (
+			events      []string
+			ctx         = context.Background()
+			log         = NewMemoryDecisionLog()
+			a, b        = newFakeParticipant("a", &events), newFakeParticipant("b", &events)
+			coordinator = newCoordinator(log, a, b)
+		)
+		b.commitPreparedErr = fmt.Errorf("connection lost")
+		err := coordinator.WithinTx(ctx, func(ctx context.Context) error {
+			_ = AfterCommit(ctx, func(context.Context) error {
+				t.Fatalf("should not have been called")
+				return nil
+			})
+			return AfterRollback(ctx, func(context.Context) error {
+				t.Fatalf("should not have been called")
+				return nil
+			})
+		})
+		assert.ErrorIs(t, err, ErrInDoubt)
+		assert.ErrorIs(t, err, ErrCommitFailed)
+		assert.Len(t, a.prepared, 0)
+		assert.Len(t, b.prepared, 1)
+
+		decisions, err := log.Decisions(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, decisions, 1)
+		assert.True(t, slices.Equal([]string{"a", "b"}, decisions[0].Participants))
+
+		// the coordinator crashed and was restarted
+		b.commitPreparedErr = nil
+		result, err := NewTwoPhaseCoordinator(log).
+			WithParticipant(a.name, a.transactor, a.resolver).
+			WithParticipant(b.name, b.transactor, b.resolver).
+			Recover(ctx)
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal([]string{decisions[0].ID + ".b"}, result.Committed))
+		assert.Len(t, result.RolledBack, 0)
+		assert.Len(t, b.prepared, 0)
+
+		decisions, err = log.Decisions(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, decisions, 0)
+	})
+	t.Run("recover_presumed_abort", func(t *testing.T) {
+		var (
+			events      []string
+			ctx         = context.Background()
+			log         = NewMemoryDecisionLog()
+			a, b        = newFakeParticipant("a", &events), newFakeParticipant("b", &events)
+			coordinator = newCoordinator(log, a, b)
+		)
+		// crashed after prepare, before the decision
+		a.prepared["oniontx_1_1.a"] = true
+		b.prepared["oniontx_1_1.b"] = true
+		// prepared by other applications
+		a.prepared["other_1_1.a"] = true
+		b.prepared["oniontx_1_1.c"] = true
+
+		result, err := coordinator.Recover(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, result.Committed, 0)
+		assert.True(t, slices.Equal([]string{"oniontx_1_1.a", "oniontx_1_1.b"}, result.RolledBack))
+		assert.True(t, a.prepared["other_1_1.a"])
+		assert.True(t, b.prepared["oniontx_1_1.c"])
+	})
+	t.Run("recover_error", func(t *testing.T) {
+		var (
+			events []string
+			ctx    = context.Background()
+			log    = NewMemoryDecisionLog()
+			a      = newFakeParticipant("a", &events)
+			expErr = fmt.Errorf("resolver error")
+		)
+		assert.NoError(t, log.Log(ctx, Decision{ID: "oniontx_1_1", Participants: []string{"a"}}))
+		a.prepared["oniontx_1_1.a"] = true
+		a.commitPreparedErr = expErr
+
+		_, err := newCoordinator(log, a).Recover(ctx)
+		assert.ErrorIs(t, err, ErrRecoveryFailed)
+		assert.ErrorIs(t, err, expErr)
+
+		// the decision is kept until the prepared transaction is committed
+		decisions, err := log.Decisions(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, decisions, 1)
+	})
+	t.Run("rollback_on_error", func(t *testing.T) {
+		var (
+			events      []string
+			ctx         = context.Background()
+			a, b        = newFakeParticipant("a", &events), newFakeParticipant("b", &events)
+			coordinator = newCoordinator(NewMemoryDecisionLog(), a, b)
+			expErr      = fmt.Errorf("call error")
+		)
+		err := coordinator.WithinTx(ctx, func(context.Context) error {
+			return expErr
+		})
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.ErrorIs(t, err, expErr)
+		assert.True(t, slices.Equal([]string{"begin:a", "begin:b", "rollback:b", "rollback:a"}, events))
+	})
+	t.Run("tx_error", func(t *testing.T) {
+		var (
+			events      []string
+			ctx         = context.Background()
+			a, b        = newFakeParticipant("a", &events), newFakeParticipant("b", &events)
+			coordinator = newCoordinator(NewMemoryDecisionLog(), a, b)
+			txErr       *TxError
+		)
+		b.prepareErr = fmt.Errorf("prepare error")
+		err := coordinator.WithinTx(ctx, func(context.Context) error {
+			return nil
+		})
+		assert.True(t, errors.As(err, &txErr))
+		assert.Equal(t, TxPhaseCommit, txErr.Phase)
+		assert.ErrorIs(t, txErr.CommitErr, ErrPrepareFailed)
+
+		err = coordinator.WithinTx(ctx, func(context.Context) error {
+			panic("two phase panic")
+		})
+		assert.True(t, errors.As(err, &txErr))
+		assert.Equal(t, TxPhaseFn, txErr.Phase)
+		assert.Equal(t, any("two phase panic"), txErr.Panic)
+	})
+	t.Run("panic_policy_rollback_repanic", func(t *testing.T) {
+		var (
+			events      []string
+			ctx         = context.Background()
+			a           = newFakeParticipant("a", &events)
+			coordinator = newCoordinator(NewMemoryDecisionLog(), a).WithPanicPolicy(PanicPolicyRollbackRepanic)
+			p           any
+		)
+		func() {
+			defer func() {
+				p = recover()
+			}()
+			_ = coordinator.WithinTx(ctx, func(context.Context) error {
+				panic("two phase panic")
+			})
+		}()
+		assert.Equal(t, any("two phase panic"), p)
+		assert.True(t, slices.Equal([]string{"begin:a", "rollback:a"}, events))
+	})
+	t.Run("observer", func(t *testing.T) {
+		var (
+			events      []string
+			ctx         = context.Background()
+			a           = newFakeParticipant("a", &events)
+			types       []TxEventType
+			coordinator = newCoordinator(NewMemoryDecisionLog(), a).WithObserver(TxObserverFunc(func(_ context.Context, e TxEvent) {
+				types = append(types, e.Type)
+			}))
+		)
+		assert.NoError(t, coordinator.WithinTx(ctx, func(context.Context) error {
+			return nil
+		}))
+		assert.True(t, slices.Equal(
+			[]TxEventType{TxEventBeginStart, TxEventBeginEnd, TxEventCommitStart, TxEventCommitEnd},
+			types,
+		))
+	})
+	t.Run("prepare_not_supported", func(t *testing.T) {
+		var (
+			events     []string
+			ctx        = context.Background()
+			a          = newFakeParticipant("a", &events)
+			rolledBack bool
+			b          = &beginnerMock[*committerMock]{
+				beginFn: func(context.Context) (*committerMock, error) {
+					return &committerMock{
+						rollbackFn: func(context.Context) error {
+							rolledBack = true
+							return nil
+						},
+					}, nil
+				},
+			}
+			coordinator = newCoordinator(NewMemoryDecisionLog(), a).WithParticipant("b",
+				NewTransactor[*beginnerMock[*committerMock], *committerMock](b, NewContextOperator[*beginnerMock[*committerMock], *committerMock](b)),
+				nil,
+			)
+		)
+		err := coordinator.WithinTx(ctx, func(context.Context) error {
+			t.Fatalf("should not have been called")
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrPrepareNotSupported)
+		assert.True(t, rolledBack)
+		assert.True(t, slices.Equal([]string{"begin:a", "rollback:a"}, events))
+	})
+	t.Run("error_tx_exists", func(t *testing.T) {
+		var (
+			events      []string
+			ctx         = context.Background()
+			a           = newFakeParticipant("a", &events)
+			coordinator = newCoordinator(NewMemoryDecisionLog(), a)
+		)
+		err := a.transactor.WithinTx(ctx, func(ctx context.Context) error {
+			return coordinator.WithinTx(ctx, func(context.Context) error {
+				return nil
+			})
+		})
+		assert.ErrorIs(t, err, ErrTxExists)
+	})
+	t.Run("error_nil_decision_log", func(t *testing.T) {
+		err := NewTwoPhaseCoordinator(nil).WithinTx(context.Background(), func(context.Context) error {
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrNilDecisionLog)
+	})
+	t.Run("gid", func(t *testing.T) {
+		coordinator := NewTwoPhaseCoordinator(NewMemoryDecisionLog()).WithIDPrefix("app")
+		id := coordinator.newID()
+		assert.True(t, strings.HasPrefix(id, "app_"))
+		parsed, ok := coordinator.parseGID(coordinator.gid(id, "pg"), "pg")
+		assert.True(t, ok)
+		assert.Equal(t, id, parsed)
+		_, ok = coordinator.parseGID(coordinator.gid(id, "pg"), "redis")
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,101 @@
+package mtx
+
+import (
+	"fmt"
+
+	"github.com/kozmod/oniontx/internal/errors"
+)
+
+// TxPhase is the phase of a transaction in which a TxError occurred.
+type TxPhase uint8
+
+const (
+	// TxPhaseBegin means that beginning the transaction
+	// (or creating the savepoint of a nested call) has failed.
+	TxPhaseBegin TxPhase = iota
+
+	// TxPhaseFn means that the function (or a before-commit hook) has failed or panicked
+	// and the transaction (or the savepoint) was rolled back successfully.
+	TxPhaseFn
+
+	// TxPhaseCommit means that committing the transaction
+	// (or releasing the savepoint) has failed.
+	TxPhaseCommit
+
+	// TxPhaseRollback means that the function has failed or panicked
+	// and rolling back the transaction (or the savepoint) has failed.
+	TxPhaseRollback
+)
+
+// String returns the name of the TxPhase.
+func (p TxPhase) String() string {
+	switch p {
+	case TxPhaseBegin:
+		return "Begin"
+	case TxPhaseFn:
+		return "Fn"
+	case TxPhaseCommit:
+		return "Commit"
+	case TxPhaseRollback:
+		return "Rollback"
+	default:
+		return fmt.Sprintf("TxPhase(%d)", p)
+	}
+}
+
+// TxError is returned by Transactor.WithinTx when a transaction (or a savepoint) fails.
+// It can be obtained by errors.As (the after-rollback callback errors are joined to it, see AfterRollback):
+//
+//	var txErr *mtx.TxError
+//	if errors.As(err, &txErr) && txErr.Panic != nil {
+//	    log.Printf("panic in tx: %v\n%s", txErr.Panic, txErr.Stack)
+//	}
+//
+// TxError wraps the same sentinel errors as before (ErrRollbackSuccess, ErrRollbackFailed, ErrCommitFailed,
+// ErrPanicRecovered, ErrBeginTx, etc.), so errors.Is works as usual.
+// The errors of the configuration or the propagation (ErrNilTxFunc, ErrTxNotFound, etc.) are not wrapped by TxError.
+type TxError struct {
+	// Phase is the phase in which the error occurred.
+	Phase TxPhase
+	// Err is the error of the function, a before-commit hook (ErrBeforeCommitFailed),
+	// the timeout (ErrTxTimeout) or the rollback-only mark (ErrRollbackOnly).
+	// It is nil if the function panicked or did not fail.
+	Err error
+	// BeginErr is the error of beginning the transaction or creating the savepoint.
+	BeginErr error
+	// CommitErr is the error of committing the transaction or releasing the savepoint.
+	CommitErr error
+	// RollbackErr is the error of rolling back the transaction or rolling back to the savepoint.
+	RollbackErr error
+	// Panic is the value recovered from the panic of the function (nil if there was no panic).
+	Panic any
+	// Stack is the stack trace of the goroutine at the moment the panic was recovered.
+	Stack []byte
+	// Depth is the nesting depth of the call: 0 for the top-level call, 1 for the first nested call, etc.
+	Depth int
+	// Savepoint reports whether the error occurred in a nested call within a savepoint (see SavepointTx).
+	Savepoint bool
+
+	op  string
+	err error
+}
+
+// newTxError returns a copy of e with the message prefix op that wraps errs.
+func newTxError(op string, e TxError, errs ...error) *TxError {
+	e.op = op
+	e.err = errors.Join(errs...)
+	return &e
+}
+
+// Error returns the error message.
+func (e *TxError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("%s: %s", e.op, e.Phase)
+	}
+	return fmt.Sprintf("%s: %v", e.op, e.err)
+}
+
+// Unwrap returns the sentinel errors and the errors of the transaction.
+func (e *TxError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,220 @@
+package mtx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+)
+
+func Test_TxError(t *testing.T) {
+	type errs struct {
+		beginErr, commitErr, rollbackErr error
+	}
+	newInstance := func(e errs) *Transactor[*beginnerMock[*committerMock], *committerMock] {
+		b := &beginnerMock[*committerMock]{
+			beginFn: func(context.Context) (*committerMock, error) {
+				if e.beginErr != nil {
+					return nil, e.beginErr
+				}
+				return &committerMock{
+					commitFn: func(context.Context) error {
+						return e.commitErr
+					},
+					rollbackFn: func(context.Context) error {
+						return e.rollbackErr
+					},
+				}, nil
+			},
+		}
+		return NewTransactor[*beginnerMock[*committerMock], *committerMock](
+			b,
+			NewContextOperator[*beginnerMock[*committerMock], *committerMock](b),
+		)
+	}
+	asTxError := func(t *testing.T, err error) *TxError {
+		t.Helper()
+		var txErr *TxError
+		assert.True(t, errors.As(err, &txErr))
+		return txErr
+	}
+
+	t.Run("begin", func(t *testing.T) {
+		var (
+			ctx      = context.Background()
+			beginErr = fmt.Errorf("begin error")
+		)
+		err := newInstance(errs{beginErr: beginErr}).WithinTx(ctx, func(context.Context) error {
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrBeginTx)
+		assert.ErrorIs(t, err, beginErr)
+		txErr := asTxError(t, err)
+		assert.Equal(t, TxPhaseBegin, txErr.Phase)
+		assert.Equal(t, beginErr, txErr.BeginErr)
+		assert.NoError(t, txErr.Err)
+	})
+	t.Run("fn", func(t *testing.T) {
+		var (
+			ctx   = context.Background()
+			fnErr = fmt.Errorf("fn error")
+		)
+		err := newInstance(errs{}).WithinTx(ctx, func(context.Context) error {
+			return fnErr
+		})
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.ErrorIs(t, err, fnErr)
+		txErr := asTxError(t, err)
+		assert.Equal(t, TxPhaseFn, txErr.Phase)
+		assert.Equal(t, fnErr, txErr.Err)
+		assert.Equal(t, 0, txErr.Depth)
+		assert.False(t, txErr.Savepoint)
+		assert.True(t, strings.HasPrefix(err.Error(), "transactor - call: "))
+	})
+	t.Run("commit", func(t *testing.T) {
+		var (
+			ctx       = context.Background()
+			commitErr = fmt.Errorf("commit error")
+		)
+		err := newInstance(errs{commitErr: commitErr}).WithinTx(ctx, func(context.Context) error {
+			return nil
+		})
+		assert.ErrorIs(t, err, ErrCommitFailed)
+		txErr := asTxError(t, err)
+		assert.Equal(t, TxPhaseCommit, txErr.Phase)
+		assert.Equal(t, commitErr, txErr.CommitErr)
+		assert.NoError(t, txErr.Err)
+	})
+	t.Run("rollback", func(t *testing.T) {
+		var (
+			ctx         = context.Background()
+			fnErr       = fmt.Errorf("fn error")
+			rollbackErr = fmt.Errorf("rollback error")
+		)
+		err := newInstance(errs{rollbackErr: rollbackErr}).WithinTx(ctx, func(context.Context) error {
+			return fnErr
+		})
+		assert.ErrorIs(t, err, ErrRollbackFailed)
+		assert.ErrorIs(t, err, fnErr)
+		assert.ErrorIs(t, err, rollbackErr)
+		txErr := asTxError(t, err)
+		assert.Equal(t, TxPhaseRollback, txErr.Phase)
+		assert.Equal(t, fnErr, txErr.Err)
+		assert.Equal(t, rollbackErr, txErr.RollbackErr)
+	})
+	t.Run("panic", func(t *testing.T) {
+		var (
+			ctx      = context.Background()
+			panicVal = fmt.Errorf("panic value")
+		)
+		err := newInstance(errs{}).WithinTx(ctx, func(context.Context) error {
+			panic(panicVal)
+		})
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.ErrorIs(t, err, ErrPanicRecovered)
+		txErr := asTxError(t, err)
+		assert.Equal(t, TxPhaseFn, txErr.Phase)
+		assert.Equal[any](t, panicVal, txErr.Panic)
+		assert.NoError(t, txErr.Err)
+		assert.True(t, strings.Contains(string(txErr.Stack), "Test_TxError"))
+	})
+	t.Run("after_rollback_callback", func(t *testing.T) {
+		var (
+			ctx   = context.Background()
+			fnErr = fmt.Errorf("fn error")
+		)
+		err := newInstance(errs{}).WithinTx(ctx, func(ctx context.Context) error {
+			_ = AfterRollback(ctx, func(context.Context) error {
+				return fmt.Errorf("callback error")
+			})
+			return fnErr
+		})
+		assert.ErrorIs(t, err, ErrAfterRollbackFailed)
+		txErr := asTxError(t, err)
+		assert.Equal(t, fnErr, txErr.Err)
+	})
+	t.Run("nested_panic", func(t *testing.T) {
+		var (
+			ctx = context.Background()
+			tr  = newInstance(errs{})
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			err := tr.WithinTx(ctx, func(ctx context.Context) error {
+				panic("nested panic")
+			})
+			txErr := asTxError(t, err)
+			assert.Equal(t, 1, txErr.Depth)
+			assert.Equal(t, TxPhaseFn, txErr.Phase)
+			assert.Equal[any](t, "nested panic", txErr.Panic)
+			assert.ErrorIs(t, err, ErrPanicRecovered)
+			assert.ErrorIsNot(t, err, ErrRollbackSuccess)
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+	t.Run("savepoint", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			releaseErr = fmt.Errorf("release error")
+			tx         = &savepointMock{
+				committerMock: committerMock{
+					commitFn: func(context.Context) error {
+						return nil
+					},
+					rollbackFn: func(context.Context) error {
+						return nil
+					},
+				},
+				savepointFn: func(context.Context, string) error {
+					return nil
+				},
+				rollbackToFn: func(context.Context, string) error {
+					return nil
+				},
+				releaseFn: func(context.Context, string) error {
+					return releaseErr
+				},
+			}
+			b = &beginnerMock[*savepointMock]{
+				beginFn: func(context.Context) (*savepointMock, error) {
+					return tx, nil
+				},
+			}
+			tr = NewTransactor[*beginnerMock[*savepointMock], *savepointMock](
+				b,
+				NewContextOperator[*beginnerMock[*savepointMock], *savepointMock](b),
+			)
+			nestedErr = fmt.Errorf("nested error")
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			err := tr.WithinTx(ctx, func(ctx context.Context) error {
+				return nestedErr
+			})
+			assert.ErrorIs(t, err, ErrRollbackToSavepointSuccess)
+			txErr := asTxError(t, err)
+			assert.Equal(t, TxPhaseFn, txErr.Phase)
+			assert.Equal(t, nestedErr, txErr.Err)
+			assert.Equal(t, 1, txErr.Depth)
+			assert.True(t, txErr.Savepoint)
+
+			err = tr.WithinTx(ctx, func(ctx context.Context) error {
+				return nil
+			})
+			assert.ErrorIs(t, err, ErrReleaseSavepointFailed)
+			txErr = asTxError(t, err)
+			assert.Equal(t, TxPhaseCommit, txErr.Phase)
+			assert.Equal(t, releaseErr, txErr.CommitErr)
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+	t.Run("not_wrapped", func(t *testing.T) {
+		var txErr *TxError
+		err := newInstance(errs{}).WithinTx(context.Background(), nil)
+		assert.ErrorIs(t, err, ErrNilTxFunc)
+		assert.False(t, errors.As(err, &txErr))
+	})
+}
@@ -0,0 +1,94 @@
+package mtx
+
+import (
+	"context"
+	"fmt"
+)
+
+// Executor executes functions within transactions.
+// It is implemented by Transactor, Router, MultiTransactor and TwoPhaseCoordinator.
+type Executor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Within executes the function within a transaction of the executor (see Transactor.WithinTx)
+// and returns the result of the function.
+//
+// The panic and rollback semantics of the executor are preserved.
+// If the transaction is not committed (the function returns an error or panics,
+// the commit fails, etc.), the zero value of R is returned with the error.
+// If the transaction is committed, but an after-commit callback fails (ErrAfterCommitFailed),
+// the result is returned with the error. The commit is reported by the executors of the package,
+// so the zero value is returned when an error of fn wraps ErrAfterCommitFailed,
+// but the transaction is rolled back.
+//
+// Example:
+//
+//	user, err := mtx.Within(ctx, transactor, func(ctx context.Context) (User, error) {
+//	    return repo.CreateUser(ctx, name)
+//	})
+func Within[R any](ctx context.Context, executor Executor, fn func(ctx context.Context) (R, error)) (R, error) {
+	var result R
+	if fn == nil {
+		return result, fmt.Errorf("within - can't execute: %w", ErrNilTxFunc)
+	}
+	committed, err := within(ctx, executor, func(ctx context.Context) error {
+		r, err := fn(ctx)
+		if err != nil {
+			return err
+		}
+		result = r
+		return nil
+	})
+	if err != nil && !committed {
+		var zero R
+		return zero, err
+	}
+	return result, err
+}
+
+// Within2 is Within for functions with two results.
+//
+// Example:
+//
+//	order, payment, err := mtx.Within2(ctx, transactor, func(ctx context.Context) (Order, Payment, error) {
+//	    return useCase.Checkout(ctx, cart)
+//	})
+func Within2[R1, R2 any](ctx context.Context, executor Executor, fn func(ctx context.Context) (R1, R2, error)) (R1, R2, error) {
+	var (
+		result1 R1
+		result2 R2
+	)
+	if fn == nil {
+		return result1, result2, fmt.Errorf("within - can't execute: %w", ErrNilTxFunc)
+	}
+	committed, err := within(ctx, executor, func(ctx context.Context) error {
+		r1, r2, err := fn(ctx)
+		if err != nil {
+			return err
+		}
+		result1, result2 = r1, r2
+		return nil
+	})
+	if err != nil && !committed {
+		var (
+			zero1 R1
+			zero2 R2
+		)
+		return zero1, zero2, err
+	}
+	return result1, result2, err
+}
+
+// within executes the function within a transaction of the executor.
+// The results of the function are assigned only when the function succeeds,
+// so a re-executed (see Transactor.WithRetry) function overrides the results of the previous attempts.
+// Reports whether the top-level transaction of the executor is committed.
+func within(ctx context.Context, executor Executor, fn func(ctx context.Context) error) (bool, error) {
+	if executor == nil {
+		return false, fmt.Errorf("within: executor is nil")
+	}
+	ctx, recorder := withCommitRecorder(ctx)
+	err := executor.WithinTx(ctx, fn)
+	return recorder.isCommitted(), err
+}
@@ -0,0 +1,195 @@
+package mtx
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+)
+
+func Test_Within(t *testing.T) {
+	type calls struct {
+		commit, rollback int
+		commitErr        error
+	}
+	newInstance := func(c *calls) *Transactor[*beginnerMock[*committerMock], *committerMock] {
+		b := &beginnerMock[*committerMock]{
+			beginFn: func(context.Context) (*committerMock, error) {
+				return &committerMock{
+					commitFn: func(context.Context) error {
+						c.commit++
+						return c.commitErr
+					},
+					rollbackFn: func(context.Context) error {
+						c.rollback++
+						return nil
+					},
+				}, nil
+			},
+		}
+		return NewTransactor[*beginnerMock[*committerMock], *committerMock](
+			b,
+			NewContextOperator[*beginnerMock[*committerMock], *committerMock](b),
+		)
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+			tr  = newInstance(&c)
+		)
+		res, err := Within(ctx, tr, func(ctx context.Context) (int, error) {
+			// nested call joins the transaction.
+			return Within(ctx, tr, func(context.Context) (int, error) {
+				return 42, nil
+			})
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 42, res)
+		assert.Equal(t, 1, c.commit)
+	})
+	t.Run("rollback_returns_zero", func(t *testing.T) {
+		var (
+			c       calls
+			ctx     = context.Background()
+			tr      = newInstance(&c)
+			callErr = fmt.Errorf("call error")
+		)
+		res, err := Within(ctx, tr, func(ctx context.Context) (string, error) {
+			return "partial", callErr
+		})
+		assert.ErrorIs(t, err, callErr)
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.Equal(t, "", res)
+		assert.Equal(t, 1, c.rollback)
+	})
+	t.Run("commit_failed_returns_zero", func(t *testing.T) {
+		var (
+			c   = calls{commitErr: fmt.Errorf("commit error")}
+			ctx = context.Background()
+		)
+		res, err := Within(ctx, newInstance(&c), func(ctx context.Context) (int, error) {
+			return 1, nil
+		})
+		assert.ErrorIs(t, err, ErrCommitFailed)
+		assert.Equal(t, 0, res)
+	})
+	t.Run("before_commit_veto_returns_zero", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+		)
+		res, err := Within(ctx, newInstance(&c), func(ctx context.Context) (int, error) {
+			return 1, BeforeCommit(ctx, func(context.Context) error {
+				return fmt.Errorf("veto")
+			})
+		})
+		assert.ErrorIs(t, err, ErrBeforeCommitFailed)
+		assert.Equal(t, 0, res)
+		assert.Equal(t, 1, c.rollback)
+	})
+	t.Run("panic", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+		)
+		res, err := Within(ctx, newInstance(&c), func(ctx context.Context) (*int, error) {
+			panic("within panic")
+		})
+		assert.ErrorIs(t, err, ErrPanicRecovered)
+		assert.True(t, res == nil)
+		assert.Equal(t, 1, c.rollback)
+	})
+	t.Run("after_commit_failed_returns_result", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+		)
+		res, err := Within(ctx, newInstance(&c), func(ctx context.Context) (int, error) {
+			return 7, AfterCommit(ctx, func(context.Context) error {
+				return fmt.Errorf("after commit error")
+			})
+		})
+		assert.ErrorIs(t, err, ErrAfterCommitFailed)
+		assert.Equal(t, 7, res)
+		assert.Equal(t, 1, c.commit)
+	})
+	t.Run("after_commit_failed_of_requires_new_returns_zero", func(t *testing.T) {
+		var (
+			c        calls
+			ctx      = context.Background()
+			tr       = newInstance(&c)
+			innerRes int
+		)
+		res, err := Within(ctx, tr, func(ctx context.Context) (int, error) {
+			var err error
+			innerRes, err = Within(ctx, tr.WithPropagation(PropagationRequiresNew), func(ctx context.Context) (int, error) {
+				return 1, AfterCommit(ctx, func(context.Context) error {
+					return fmt.Errorf("after commit error")
+				})
+			})
+			return 7, err
+		})
+		assert.ErrorIs(t, err, ErrAfterCommitFailed)
+		assert.ErrorIs(t, err, ErrRollbackSuccess)
+		assert.Equal(t, 0, res)
+		assert.Equal(t, 1, innerRes)
+		assert.Equal(t, 1, c.commit)
+		assert.Equal(t, 1, c.rollback)
+	})
+	t.Run("retry_returns_last_result", func(t *testing.T) {
+		var (
+			c        calls
+			ctx      = context.Background()
+			attempts int
+			tr       = newInstance(&c).WithRetry(&retryPolicyMock{attempts: 3}, func(error) bool {
+				return true
+			})
+		)
+		res, err := Within(ctx, tr, func(context.Context) (int, error) {
+			attempts++
+			if attempts < 3 {
+				return attempts, fmt.Errorf("attempt error")
+			}
+			return attempts, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, res)
+	})
+	t.Run("two_results", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+			tr  = newInstance(&c)
+		)
+		r1, r2, err := Within2(ctx, tr, func(ctx context.Context) (int, string, error) {
+			return 1, "one", nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, r1)
+		assert.Equal(t, "one", r2)
+
+		r1, r2, err = Within2(ctx, tr, func(ctx context.Context) (int, string, error) {
+			return 2, "two", fmt.Errorf("call error")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 0, r1)
+		assert.Equal(t, "", r2)
+	})
+	t.Run("nil_args", func(t *testing.T) {
+		var (
+			c   calls
+			ctx = context.Background()
+		)
+		_, err := Within[int](ctx, newInstance(&c), nil)
+		assert.ErrorIs(t, err, ErrNilTxFunc)
+		_, _, err = Within2[int, int](ctx, newInstance(&c), nil)
+		assert.ErrorIs(t, err, ErrNilTxFunc)
+		_, err = Within(ctx, nil, func(context.Context) (int, error) {
+			return 1, nil
+		})
+		assert.Error(t, err)
+	})
+}
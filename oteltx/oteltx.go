@@ -0,0 +1,175 @@
+// Package oteltx provides OpenTelemetry tracing for the mtx and saga packages.
+package oteltx
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/kozmod/oniontx/mtx"
+)
+
+// ScopeName is the instrumentation scope name of the default tracer.
+const ScopeName = "github.com/kozmod/oniontx/oteltx"
+
+// DefaultTxSpanName is the default name of the WithinTx spans.
+const DefaultTxSpanName = "oniontx.WithinTx"
+
+const (
+	// AttrTxID is the identifier of the top-level transaction (see mtx.TxEvent.TxID).
+	AttrTxID = attribute.Key("oniontx.tx.id")
+	// AttrTxDepth is the nesting depth of the WithinTx call.
+	AttrTxDepth = attribute.Key("oniontx.tx.depth")
+	// AttrTxNested reports whether the WithinTx call joined the existing transaction.
+	AttrTxNested = attribute.Key("oniontx.tx.nested")
+	// AttrTxOutcome is the outcome of the transaction (see the Outcome constants).
+	AttrTxOutcome = attribute.Key("oniontx.tx.outcome")
+	// AttrDurationMs is the duration of the operation in milliseconds (the "End" events).
+	AttrDurationMs = attribute.Key("oniontx.duration_ms")
+)
+
+const (
+	// OutcomeCommit is the outcome of the committed transaction.
+	OutcomeCommit = "commit"
+	// OutcomeCommitFailed is the outcome of the transaction which failed to commit.
+	OutcomeCommitFailed = "commit_failed"
+	// OutcomeRollback is the outcome of the rolled back transaction (or nested call rolled back to its savepoint).
+	OutcomeRollback = "rollback"
+	// OutcomeRollbackFailed is the outcome of the transaction which failed to roll back.
+	OutcomeRollbackFailed = "rollback_failed"
+)
+
+// Transactor is implemented by mtx.Transactor and the transactors of the adapters.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// TracedTransactor creates a span for each WithinTx call of the wrapped Transactor.
+// If the wrapped mtx.Transactor has the Observer (see Instrument), the spans are annotated
+// with the transaction identifier, the nesting depth and the outcome.
+type TracedTransactor struct {
+	transactor Transactor
+	tracer     trace.Tracer
+	name       string
+}
+
+// NewTransactor creates a new TracedTransactor.
+// If tracer is nil, the tracer of the global TracerProvider is used.
+func NewTransactor(transactor Transactor, tracer trace.Tracer) *TracedTransactor {
+	if tracer == nil {
+		tracer = otel.Tracer(ScopeName)
+	}
+	return &TracedTransactor{
+		transactor: transactor,
+		tracer:     tracer,
+		name:       DefaultTxSpanName,
+	}
+}
+
+// Instrument returns a TracedTransactor for the mtx.Transactor configured with the Observer.
+// The original mtx.Transactor is not modified.
+func Instrument[B mtx.TxBeginner[T], T mtx.Tx](transactor *mtx.Transactor[B, T], tracer trace.Tracer) *TracedTransactor {
+	return NewTransactor(transactor.WithObserver(NewObserver()), tracer)
+}
+
+// WithSpanName returns a new TracedTransactor that uses the span name.
+// The original TracedTransactor is not modified.
+func (t *TracedTransactor) WithSpanName(name string) *TracedTransactor {
+	c := *t
+	c.name = name
+	return &c
+}
+
+// WithinTx calls WithinTx of the wrapped Transactor within a new span.
+// The error of the call is recorded in the span.
+func (t *TracedTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	ctx, span := t.tracer.Start(ctx, t.name)
+	defer span.End()
+
+	ctx = context.WithValue(ctx, spanScopeKey{}, &spanScope{span: span})
+	err := t.transactor.WithinTx(ctx, fn)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// spanScopeKey is the context key of spanScope.
+type spanScopeKey struct{}
+
+// spanScope binds the span of a TracedTransactor.WithinTx call to the depth of the call,
+// so the events of the nested calls (without own span) do not change the span attributes.
+type spanScope struct {
+	span    trace.Span
+	mu      sync.Mutex
+	claimed bool
+	depth   int
+}
+
+// owns reports whether the event belongs to the WithinTx call of the scope.
+func (s *spanScope) owns(depth int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.claimed {
+		s.claimed = true
+		s.depth = depth
+	}
+	return s.depth == depth
+}
+
+// Observer is a mtx.TxObserver that adds the transaction lifecycle events to the span from the context.
+// The attributes (AttrTxID, AttrTxDepth, AttrTxNested, AttrTxOutcome) are set on the spans of TracedTransactor.
+type Observer struct{}
+
+// NewObserver creates a new Observer.
+func NewObserver() *Observer {
+	return &Observer{}
+}
+
+// OnTxEvent implements mtx.TxObserver.
+func (o *Observer) OnTxEvent(ctx context.Context, event mtx.TxEvent) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	attrs := []attribute.KeyValue{
+		AttrTxID.Int64(int64(event.TxID)),
+		AttrTxDepth.Int(event.Depth),
+	}
+	switch event.Type {
+	case mtx.TxEventBeginEnd, mtx.TxEventCommitEnd, mtx.TxEventRollbackEnd:
+		attrs = append(attrs, AttrDurationMs.Float64(float64(event.Duration.Microseconds())/1000))
+	}
+	span.AddEvent("oniontx."+event.Type.String(), trace.WithAttributes(attrs...))
+	if event.Err != nil {
+		span.RecordError(event.Err)
+	}
+
+	scope, ok := ctx.Value(spanScopeKey{}).(*spanScope)
+	if !ok || scope.span != span || !scope.owns(event.Depth) {
+		return
+	}
+	switch event.Type {
+	case mtx.TxEventBeginEnd:
+		span.SetAttributes(AttrTxID.Int64(int64(event.TxID)), AttrTxDepth.Int(event.Depth), AttrTxNested.Bool(false))
+	case mtx.TxEventJoin:
+		span.SetAttributes(AttrTxID.Int64(int64(event.TxID)), AttrTxDepth.Int(event.Depth), AttrTxNested.Bool(true))
+	case mtx.TxEventCommitEnd:
+		span.SetAttributes(AttrTxOutcome.String(outcome(event.Err, OutcomeCommit, OutcomeCommitFailed)))
+	case mtx.TxEventRollbackEnd:
+		span.SetAttributes(AttrTxOutcome.String(outcome(event.Err, OutcomeRollback, OutcomeRollbackFailed)))
+	}
+}
+
+func outcome(err error, success, failure string) string {
+	if err != nil {
+		return failure
+	}
+	return success
+}
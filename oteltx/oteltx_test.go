@@ -0,0 +1,193 @@
+package oteltx
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+	"github.com/kozmod/oniontx/mtx"
+)
+
+type (
+	txMock struct {
+		commitErr   error
+		rollbackErr error
+	}
+
+	beginnerMock struct {
+		tx *txMock
+	}
+)
+
+func (t *txMock) Commit(context.Context) error {
+	return t.commitErr
+}
+
+func (t *txMock) Rollback(context.Context) error {
+	return t.rollbackErr
+}
+
+func (b *beginnerMock) BeginTx(context.Context) (*txMock, error) {
+	return b.tx, nil
+}
+
+func newTracing(t *testing.T) (*tracetest.InMemoryExporter, *sdktrace.TracerProvider) {
+	t.Helper()
+	var (
+		exporter = tracetest.NewInMemoryExporter()
+		provider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	)
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+	})
+	return exporter, provider
+}
+
+func newTransactor(tx *txMock) *mtx.Transactor[*beginnerMock, *txMock] {
+	b := &beginnerMock{tx: tx}
+	return mtx.NewTransactor[*beginnerMock, *txMock](b, mtx.NewContextOperator[*beginnerMock, *txMock](b))
+}
+
+func spanAttr(span tracetest.SpanStub, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func spanEvents(span tracetest.SpanStub) []string {
+	res := make([]string, 0, len(span.Events))
+	for _, e := range span.Events {
+		res = append(res, e.Name)
+	}
+	return res
+}
+
+func Test_TracedTransactor(t *testing.T) {
+	t.Run("commit_nested", func(t *testing.T) {
+		var (
+			ctx                = context.Background()
+			exporter, provider = newTracing(t)
+			tr                 = Instrument(newTransactor(&txMock{}), provider.Tracer("test"))
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			return tr.WithinTx(ctx, func(ctx context.Context) error {
+				return nil
+			})
+		})
+		assert.NoError(t, err)
+
+		spans := exporter.GetSpans()
+		assert.Equal(t, 2, len(spans))
+		nested, top := spans[0], spans[1]
+		assert.Equal(t, DefaultTxSpanName, top.Name)
+		assert.Equal(t, top.SpanContext.SpanID(), nested.Parent.SpanID())
+
+		v, ok := spanAttr(top, AttrTxOutcome)
+		assert.True(t, ok)
+		assert.Equal(t, OutcomeCommit, v.AsString())
+		v, _ = spanAttr(top, AttrTxNested)
+		assert.False(t, v.AsBool())
+		v, _ = spanAttr(top, AttrTxDepth)
+		assert.Equal(t, int64(0), v.AsInt64())
+		assert.Equal(t, codes.Unset, top.Status.Code)
+
+		v, _ = spanAttr(nested, AttrTxNested)
+		assert.True(t, v.AsBool())
+		v, _ = spanAttr(nested, AttrTxDepth)
+		assert.Equal(t, int64(1), v.AsInt64())
+		_, ok = spanAttr(nested, AttrTxOutcome)
+		assert.False(t, ok)
+
+		topID, _ := spanAttr(top, AttrTxID)
+		nestedID, _ := spanAttr(nested, AttrTxID)
+		assert.Equal(t, topID.AsInt64(), nestedID.AsInt64())
+	})
+	t.Run("rollback", func(t *testing.T) {
+		var (
+			ctx                = context.Background()
+			exporter, provider = newTracing(t)
+			tr                 = Instrument(newTransactor(&txMock{}), provider.Tracer("test"))
+			callErr            = fmt.Errorf("call error")
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			return callErr
+		})
+		assert.ErrorIs(t, err, callErr)
+
+		spans := exporter.GetSpans()
+		assert.Equal(t, 1, len(spans))
+		v, _ := spanAttr(spans[0], AttrTxOutcome)
+		assert.Equal(t, OutcomeRollback, v.AsString())
+		assert.Equal(t, codes.Error, spans[0].Status.Code)
+		assert.Equal(t,
+			fmt.Sprint([]string{"oniontx.BeginStart", "oniontx.BeginEnd", "oniontx.RollbackStart", "oniontx.RollbackEnd", "exception"}),
+			fmt.Sprint(spanEvents(spans[0])),
+		)
+	})
+	t.Run("commit_failed", func(t *testing.T) {
+		var (
+			ctx                = context.Background()
+			exporter, provider = newTracing(t)
+			tr                 = Instrument(newTransactor(&txMock{commitErr: fmt.Errorf("commit error")}), provider.Tracer("test"))
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			return nil
+		})
+		assert.ErrorIs(t, err, mtx.ErrCommitFailed)
+
+		spans := exporter.GetSpans()
+		assert.Equal(t, 1, len(spans))
+		v, _ := spanAttr(spans[0], AttrTxOutcome)
+		assert.Equal(t, OutcomeCommitFailed, v.AsString())
+		assert.Equal(t, codes.Error, spans[0].Status.Code)
+	})
+	t.Run("rollback_failed", func(t *testing.T) {
+		var (
+			ctx                = context.Background()
+			exporter, provider = newTracing(t)
+			tr                 = Instrument(newTransactor(&txMock{rollbackErr: fmt.Errorf("rollback error")}), provider.Tracer("test"))
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			return fmt.Errorf("call error")
+		})
+		assert.ErrorIs(t, err, mtx.ErrRollbackFailed)
+
+		spans := exporter.GetSpans()
+		assert.Equal(t, 1, len(spans))
+		v, _ := spanAttr(spans[0], AttrTxOutcome)
+		assert.Equal(t, OutcomeRollbackFailed, v.AsString())
+	})
+	t.Run("nested_without_span", func(t *testing.T) {
+		var (
+			ctx                = context.Background()
+			exporter, provider = newTracing(t)
+			base               = newTransactor(&txMock{}).WithObserver(NewObserver())
+			tr                 = NewTransactor(base, provider.Tracer("test")).WithSpanName("tx")
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			return base.WithinTx(ctx, func(ctx context.Context) error {
+				return nil
+			})
+		})
+		assert.NoError(t, err)
+
+		spans := exporter.GetSpans()
+		assert.Equal(t, 1, len(spans))
+		assert.Equal(t, "tx", spans[0].Name)
+		v, _ := spanAttr(spans[0], AttrTxNested)
+		assert.False(t, v.AsBool())
+		v, _ = spanAttr(spans[0], AttrTxDepth)
+		assert.Equal(t, int64(0), v.AsInt64())
+		v, _ = spanAttr(spans[0], AttrTxOutcome)
+		assert.Equal(t, OutcomeCommit, v.AsString())
+	})
+}
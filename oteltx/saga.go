@@ -0,0 +1,86 @@
+package oteltx
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/kozmod/oniontx/saga"
+)
+
+const (
+	// AttrSagaOperation is the kind of the saga operation (see saga.OperationKind).
+	AttrSagaOperation = attribute.Key("oniontx.saga.operation")
+	// AttrSagaStepName is the name of the saga step.
+	AttrSagaStepName = attribute.Key("oniontx.saga.step.name")
+	// AttrSagaStepPosition is the position of the saga step.
+	AttrSagaStepPosition = attribute.Key("oniontx.saga.step.position")
+	// AttrSagaRetry is the number of the retry attempt.
+	AttrSagaRetry = attribute.Key("oniontx.saga.retry")
+)
+
+// SagaInstrumentation is a saga.Instrumentation that creates spans for saga.Saga.Execute,
+// the step actions, the compensations and the retry attempts.
+//
+// Example:
+//
+//	result, err := saga.NewSaga(steps).
+//	    WithInstrumentation(oteltx.NewSagaInstrumentation(tracer)).
+//	    Execute(ctx)
+type SagaInstrumentation struct {
+	tracer trace.Tracer
+}
+
+// NewSagaInstrumentation creates a new SagaInstrumentation.
+// If tracer is nil, the tracer of the global TracerProvider is used.
+func NewSagaInstrumentation(tracer trace.Tracer) *SagaInstrumentation {
+	if tracer == nil {
+		tracer = otel.Tracer(ScopeName)
+	}
+	return &SagaInstrumentation{
+		tracer: tracer,
+	}
+}
+
+// Start implements saga.Instrumentation.
+func (i *SagaInstrumentation) Start(ctx context.Context, info saga.OperationInfo) (context.Context, func(err error)) {
+	attrs := []attribute.KeyValue{
+		AttrSagaOperation.String(string(info.Kind)),
+	}
+	if info.Kind != saga.OperationKindSaga {
+		attrs = append(attrs,
+			AttrSagaStepName.String(info.StepName),
+			AttrSagaStepPosition.Int64(int64(info.StepPosition)),
+		)
+	}
+	if info.Kind == saga.OperationKindRetry {
+		attrs = append(attrs, AttrSagaRetry.Int64(int64(info.Retry)))
+	}
+
+	ctx, span := i.tracer.Start(ctx, sagaSpanName(info), trace.WithAttributes(attrs...))
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+func sagaSpanName(info saga.OperationInfo) string {
+	switch info.Kind {
+	case saga.OperationKindSaga:
+		return "saga.Execute"
+	case saga.OperationKindAction:
+		return "saga.Action " + info.StepName
+	case saga.OperationKindCompensation:
+		return "saga.Compensation " + info.StepName
+	case saga.OperationKindRetry:
+		return "saga.Retry " + info.StepName
+	default:
+		return "saga." + string(info.Kind)
+	}
+}
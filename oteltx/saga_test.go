@@ -0,0 +1,73 @@
+package oteltx
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+	"github.com/kozmod/oniontx/saga"
+)
+
+func Test_SagaInstrumentation(t *testing.T) {
+	var (
+		ctx                = context.Background()
+		exporter, provider = newTracing(t)
+		steps              = []saga.Step{
+			saga.NewStep("reserve").
+				WithAction(saga.NewOperation(func(context.Context, saga.Track) error {
+					return nil
+				})).
+				WithCompensation(saga.NewOperation(func(context.Context, saga.Track) error {
+					return nil
+				})),
+			saga.NewStep("charge").
+				WithAction(saga.NewOperation(func(context.Context, saga.Track) error {
+					return fmt.Errorf("charge error")
+				}).WithRetry(saga.NewBaseRetryPolicy(1, 0))),
+		}
+	)
+
+	_, err := saga.NewSaga(steps).
+		WithInstrumentation(NewSagaInstrumentation(provider.Tracer("test"))).
+		Execute(ctx)
+	assert.Error(t, err)
+
+	spans := exporter.GetSpans()
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	assert.Equal(t,
+		fmt.Sprint([]string{"saga.Action reserve", "saga.Retry charge", "saga.Action charge", "saga.Compensation reserve", "saga.Execute"}),
+		fmt.Sprint(names),
+	)
+
+	var (
+		reserve      = spans[0]
+		retry        = spans[1]
+		charge       = spans[2]
+		compensation = spans[3]
+		root         = spans[4]
+	)
+	assert.Equal(t, codes.Unset, reserve.Status.Code)
+	assert.Equal(t, codes.Error, charge.Status.Code)
+	assert.Equal(t, codes.Error, root.Status.Code)
+	assert.Equal(t, charge.SpanContext.SpanID(), retry.Parent.SpanID())
+	for _, span := range []int{0, 2, 3} {
+		assert.Equal(t, root.SpanContext.SpanID(), spans[span].Parent.SpanID())
+	}
+
+	v, _ := spanAttr(retry, AttrSagaRetry)
+	assert.Equal(t, int64(1), v.AsInt64())
+	v, _ = spanAttr(charge, AttrSagaStepPosition)
+	assert.Equal(t, int64(1), v.AsInt64())
+	v, _ = spanAttr(compensation, AttrSagaOperation)
+	assert.Equal(t, string(saga.OperationKindCompensation), v.AsString())
+	v, _ = spanAttr(compensation, AttrSagaStepName)
+	assert.Equal(t, "reserve", v.AsString())
+	_, ok := spanAttr(root, AttrSagaStepName)
+	assert.False(t, ok)
+}
@@ -0,0 +1,99 @@
+package outbox
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultTable is the default name of the outbox table.
+const DefaultTable = "oniontx_outbox"
+
+// Dialect describes the SQL differences between the supported databases.
+type Dialect struct {
+	// Name is the name of the dialect.
+	Name string
+	// Placeholder returns the placeholder of the n-th (starting from 1) query argument.
+	Placeholder func(n int) string
+	// Lock is the clause that is appended to the query selecting the pending messages
+	// to lock them within the relay transaction (empty if the database does not support row locks).
+	Lock string
+	// Schema is the template of the outbox table schema. The only template argument is the table name.
+	Schema string
+}
+
+var (
+	// Postgres is the PostgreSQL dialect.
+	// The pending messages are locked with "FOR UPDATE SKIP LOCKED",
+	// so several relays can process the same table concurrently.
+	Postgres = Dialect{
+		Name: "postgres",
+		Placeholder: func(n int) string {
+			return fmt.Sprintf("$%d", n)
+		},
+		Lock: "FOR UPDATE SKIP LOCKED",
+		Schema: `CREATE TABLE IF NOT EXISTS %[1]s (
+	id           BIGSERIAL PRIMARY KEY,
+	topic        TEXT     NOT NULL,
+	key          TEXT     NOT NULL DEFAULT '',
+	payload      BYTEA    NOT NULL,
+	headers      TEXT     NOT NULL DEFAULT '{}',
+	status       SMALLINT NOT NULL DEFAULT 0,
+	attempts     INTEGER  NOT NULL DEFAULT 0,
+	last_error   TEXT     NOT NULL DEFAULT '',
+	created_at   BIGINT   NOT NULL,
+	available_at BIGINT   NOT NULL,
+	published_at BIGINT
+);
+CREATE INDEX IF NOT EXISTS %[1]s_pending_idx ON %[1]s (status, available_at, id);
+CREATE INDEX IF NOT EXISTS %[1]s_key_idx ON %[1]s (key, status, id);`,
+	}
+
+	// SQLite is the SQLite dialect.
+	// SQLite does not support row locks, so only one relay should process the table.
+	SQLite = Dialect{
+		Name: "sqlite",
+		Placeholder: func(int) string {
+			return "?"
+		},
+		Schema: `CREATE TABLE IF NOT EXISTS %[1]s (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	topic        TEXT    NOT NULL,
+	key          TEXT    NOT NULL DEFAULT '',
+	payload      BLOB    NOT NULL,
+	headers      TEXT    NOT NULL DEFAULT '{}',
+	status       INTEGER NOT NULL DEFAULT 0,
+	attempts     INTEGER NOT NULL DEFAULT 0,
+	last_error   TEXT    NOT NULL DEFAULT '',
+	created_at   INTEGER NOT NULL,
+	available_at INTEGER NOT NULL,
+	published_at INTEGER
+);
+CREATE INDEX IF NOT EXISTS %[1]s_pending_idx ON %[1]s (status, available_at, id);
+CREATE INDEX IF NOT EXISTS %[1]s_key_idx ON %[1]s (key, status, id);`,
+	}
+)
+
+// SchemaSQL returns the statements creating the outbox table (and its indexes) with the given name.
+func (d Dialect) SchemaSQL(table string) string {
+	return fmt.Sprintf(d.Schema, table)
+}
+
+// query replaces the "?" placeholders of the query with the dialect placeholders.
+func (d Dialect) query(query string) string {
+	if d.Placeholder == nil {
+		return query
+	}
+	var (
+		b strings.Builder
+		n int
+	)
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n++
+		b.WriteString(d.Placeholder(n))
+	}
+	return b.String()
+}
@@ -0,0 +1,195 @@
+// Package outbox implements the transactional outbox pattern on top of the mtx package.
+//
+// Outbox stores outgoing messages in the same database transaction as the business writes
+// (the transaction is obtained from the context by the Transactor), so a message is stored
+// if and only if the business transaction is committed.
+// Relay polls the stored messages and publishes them through the Publisher
+// with retry, ordering by key and dead-lettering.
+//
+// The package works with the database/sql transactions. The schema of the outbox table
+// for the supported databases is provided by Dialect.SchemaSQL.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kozmod/oniontx/mtx"
+)
+
+var (
+	// ErrNilTransactor is returned when Transactor is nil.
+	ErrNilTransactor = fmt.Errorf("transactor is nil")
+
+	// ErrEmptyTopic is returned when a message is added without a topic.
+	ErrEmptyTopic = fmt.Errorf("message topic is empty")
+
+	// ErrAddFailed indicates that the message cannot be stored in the outbox table.
+	ErrAddFailed = fmt.Errorf("add message failed")
+)
+
+// Status is the status of a stored message.
+type Status uint8
+
+const (
+	// StatusPending is the status of a message waiting to be published.
+	StatusPending Status = iota
+	// StatusPublished is the status of a published message.
+	StatusPublished
+	// StatusDead is the status of a message that was not published after all retry attempts (dead letter).
+	StatusDead
+)
+
+// String returns the name of the status.
+func (s Status) String() string {
+	switch s {
+	case StatusPending:
+		return "Pending"
+	case StatusPublished:
+		return "Published"
+	case StatusDead:
+		return "Dead"
+	default:
+		return fmt.Sprintf("Status(%d)", s)
+	}
+}
+
+// Message is an outgoing message.
+type Message struct {
+	// ID is the identifier of the stored message (set by the database).
+	ID int64
+	// Topic is the destination of the message.
+	Topic string
+	// Key defines the ordering of the messages: messages with the same non-empty key
+	// are published in the order they were added. Messages with an empty key are not ordered.
+	Key string
+	// Payload is the body of the message.
+	Payload []byte
+	// Headers are the optional metadata of the message.
+	Headers map[string]string
+	// Attempts is the number of the failed publish attempts.
+	Attempts uint32
+	// CreatedAt is the time the message was added.
+	CreatedAt time.Time
+}
+
+// Transactor provides the database/sql transaction from the context
+// and executes functions within a transaction.
+//
+// The stdlib adapter (a Transactor built on mtx.Transactor) implements the interface.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+	TryGetTx(ctx context.Context) (*sql.Tx, bool)
+}
+
+// Outbox stores the messages in the outbox table within the transaction from the context.
+type Outbox struct {
+	transactor Transactor
+	dialect    Dialect
+	table      string
+	clock      func() time.Time
+}
+
+// New creates a new Outbox with the SQLite dialect and DefaultTable.
+func New(transactor Transactor) *Outbox {
+	return &Outbox{
+		transactor: transactor,
+		dialect:    SQLite,
+		table:      DefaultTable,
+		clock:      time.Now,
+	}
+}
+
+// WithDialect returns a new Outbox that uses the dialect.
+// The original Outbox is not modified.
+func (o *Outbox) WithDialect(dialect Dialect) *Outbox {
+	c := *o
+	c.dialect = dialect
+	return &c
+}
+
+// WithTable returns a new Outbox that uses the table.
+// The original Outbox is not modified.
+func (o *Outbox) WithTable(table string) *Outbox {
+	c := *o
+	c.table = table
+	return &c
+}
+
+// WithClock returns a new Outbox that uses the clock to get the current time.
+// The original Outbox is not modified.
+func (o *Outbox) WithClock(clock func() time.Time) *Outbox {
+	c := *o
+	if clock != nil {
+		c.clock = clock
+	}
+	return &c
+}
+
+// Add stores the messages within the transaction from the context.
+// The IDs of the stored messages are not returned: the messages become visible
+// to the Relay only after the transaction is committed.
+//
+// Returns mtx.ErrTxNotFound if the context does not contain a transaction,
+// so the messages are never stored outside the business transaction.
+func (o *Outbox) Add(ctx context.Context, msgs ...Message) error {
+	if o.transactor == nil {
+		return fmt.Errorf("outbox - add: %w", errors.Join(ErrAddFailed, ErrNilTransactor))
+	}
+	tx, ok := o.transactor.TryGetTx(ctx)
+	if !ok {
+		return fmt.Errorf("outbox - add: %w", errors.Join(ErrAddFailed, mtx.ErrTxNotFound))
+	}
+
+	var (
+		now   = o.clock().UnixNano()
+		query = o.dialect.query(fmt.Sprintf(
+			`INSERT INTO %s (topic, key, payload, headers, status, attempts, created_at, available_at) VALUES (?, ?, ?, ?, ?, 0, ?, ?)`,
+			o.table,
+		))
+	)
+	for i, msg := range msgs {
+		if msg.Topic == "" {
+			return fmt.Errorf("outbox - add [%d]: %w", i, errors.Join(ErrAddFailed, ErrEmptyTopic))
+		}
+		headers, err := marshalHeaders(msg.Headers)
+		if err != nil {
+			return fmt.Errorf("outbox - add [%d]: %w", i, errors.Join(ErrAddFailed, err))
+		}
+		payload := msg.Payload
+		if payload == nil {
+			payload = []byte{}
+		}
+		_, err = tx.ExecContext(ctx, query, msg.Topic, msg.Key, payload, headers, StatusPending, now, now)
+		if err != nil {
+			return fmt.Errorf("outbox - add [%d]: %w", i, errors.Join(ErrAddFailed, err))
+		}
+	}
+	return nil
+}
+
+func marshalHeaders(headers map[string]string) (string, error) {
+	if len(headers) == 0 {
+		return "{}", nil
+	}
+	b, err := json.Marshal(headers)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func unmarshalHeaders(headers string) (map[string]string, error) {
+	if headers == "" || headers == "{}" {
+		return nil, nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal([]byte(headers), &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,168 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+	"github.com/kozmod/oniontx/mtx"
+)
+
+type (
+	dbWrapper struct {
+		*sql.DB
+	}
+
+	txWrapper struct {
+		*sql.Tx
+	}
+
+	testTransactor struct {
+		*mtx.Transactor[*dbWrapper, *txWrapper]
+	}
+)
+
+func (db *dbWrapper) BeginTx(ctx context.Context) (*txWrapper, error) {
+	tx, err := db.DB.BeginTx(ctx, nil)
+	return &txWrapper{Tx: tx}, err
+}
+
+func (t *txWrapper) Commit(_ context.Context) error {
+	return t.Tx.Commit()
+}
+
+func (t *txWrapper) Rollback(_ context.Context) error {
+	return t.Tx.Rollback()
+}
+
+func (t *testTransactor) TryGetTx(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := t.Transactor.TryGetTx(ctx)
+	if !ok || tx == nil {
+		return nil, false
+	}
+	return tx.Tx, true
+}
+
+func newTestTransactor(t *testing.T) (*sql.DB, *testTransactor) {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "outbox.db"))
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(SQLite.SchemaSQL(DefaultTable))
+	assert.NoError(t, err)
+
+	var (
+		wrapper  = &dbWrapper{DB: db}
+		operator = mtx.NewContextOperator[*dbWrapper, *txWrapper](wrapper)
+	)
+	return db, &testTransactor{
+		Transactor: mtx.NewTransactor[*dbWrapper, *txWrapper](wrapper, operator),
+	}
+}
+
+type storedMessage struct {
+	status    Status
+	attempts  uint32
+	lastError string
+}
+
+func selectStored(t *testing.T, db *sql.DB) map[int64]storedMessage {
+	t.Helper()
+	rows, err := db.Query(fmt.Sprintf(`SELECT id, status, attempts, last_error FROM %s`, DefaultTable))
+	assert.NoError(t, err)
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	stored := make(map[int64]storedMessage)
+	for rows.Next() {
+		var (
+			id  int64
+			msg storedMessage
+		)
+		assert.NoError(t, rows.Scan(&id, &msg.status, &msg.attempts, &msg.lastError))
+		stored[id] = msg
+	}
+	assert.NoError(t, rows.Err())
+	return stored
+}
+
+func Test_Outbox_Add(t *testing.T) {
+	t.Run("committed", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			db, tr     = newTestTransactor(t)
+			outbox     = New(tr)
+			businessOK bool
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			businessOK = true
+			return outbox.Add(ctx,
+				Message{Topic: "orders", Key: "1", Payload: []byte("created")},
+				Message{Topic: "orders", Key: "1", Payload: []byte("paid")},
+			)
+		})
+		assert.NoError(t, err)
+		assert.True(t, businessOK)
+
+		stored := selectStored(t, db)
+		assert.Len(t, stored, 2)
+		for _, msg := range stored {
+			assert.Equal(t, StatusPending, msg.status)
+			assert.Equal(t, 0, msg.attempts)
+		}
+	})
+	t.Run("rolled_back", func(t *testing.T) {
+		var (
+			ctx    = context.Background()
+			db, tr = newTestTransactor(t)
+			outbox = New(tr)
+			expErr = fmt.Errorf("business error")
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			err := outbox.Add(ctx, Message{Topic: "orders", Payload: []byte("created")})
+			assert.NoError(t, err)
+			return expErr
+		})
+		assert.ErrorIs(t, err, expErr)
+		assert.Len(t, selectStored(t, db), 0)
+	})
+	t.Run("error_without_tx", func(t *testing.T) {
+		var (
+			ctx   = context.Background()
+			_, tr = newTestTransactor(t)
+		)
+		err := New(tr).Add(ctx, Message{Topic: "orders"})
+		assert.ErrorIs(t, err, ErrAddFailed)
+		assert.ErrorIs(t, err, mtx.ErrTxNotFound)
+
+		err = New(nil).Add(ctx, Message{Topic: "orders"})
+		assert.ErrorIs(t, err, ErrNilTransactor)
+	})
+	t.Run("error_empty_topic", func(t *testing.T) {
+		var (
+			ctx    = context.Background()
+			db, tr = newTestTransactor(t)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			return New(tr).Add(ctx, Message{Topic: "orders"}, Message{})
+		})
+		assert.ErrorIs(t, err, ErrEmptyTopic)
+		assert.Len(t, selectStored(t, db), 0)
+	})
+}
+
+func Test_Dialect_query(t *testing.T) {
+	const query = `UPDATE t SET a = ?, b = ? WHERE id = ?`
+	assert.Equal(t, `UPDATE t SET a = $1, b = $2 WHERE id = $3`, Postgres.query(query))
+	assert.Equal(t, query, SQLite.query(query))
+}
@@ -0,0 +1,324 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/kozmod/oniontx/internal/errors"
+	"github.com/kozmod/oniontx/mtx"
+)
+
+const (
+	// DefaultBatchSize is the default maximum number of messages processed by one Relay.Process call.
+	DefaultBatchSize = 100
+	// DefaultPollInterval is the default interval between the Relay.Process calls
+	// when there are no more pending messages.
+	DefaultPollInterval = time.Second
+)
+
+var (
+	// ErrNilPublisher is returned when Publisher is nil.
+	ErrNilPublisher = fmt.Errorf("publisher is nil")
+
+	// ErrProcessFailed indicates that the relay cannot fetch or update the stored messages.
+	ErrProcessFailed = fmt.Errorf("process messages failed")
+
+	// ErrPublishPanic indicates that the Publisher panicked.
+	// The publish error also wraps mtx.PanicError with the panic value and the stack trace.
+	ErrPublishPanic = fmt.Errorf("publish panic recovered")
+)
+
+type (
+	// Publisher publishes the messages to a message broker (or any other destination).
+	// Publish can be called more than once for the same message (at-least-once delivery),
+	// so the consumers should deduplicate the messages by Message.ID.
+	Publisher interface {
+		Publish(ctx context.Context, msg Message) error
+	}
+
+	// PublisherFunc is an adapter to use an ordinary function as a Publisher.
+	PublisherFunc func(ctx context.Context, msg Message) error
+
+	// DeadLetterHandler is called within the relay transaction when a message
+	// is moved to the dead letters (StatusDead). The error is the last publish error.
+	// If the handler returns an error, the relay transaction is rolled back.
+	DeadLetterHandler func(ctx context.Context, msg Message, err error) error
+)
+
+// Publish calls f(ctx, msg).
+func (f PublisherFunc) Publish(ctx context.Context, msg Message) error {
+	return f(ctx, msg)
+}
+
+// Relay publishes the pending messages stored by Outbox.
+//
+// Each Relay.Process call fetches a batch of the pending messages within a transaction
+// (locking them if the Dialect supports it), publishes them and updates their status:
+//   - published messages get StatusPublished;
+//   - failed messages are retried according to the RetryPolicy
+//     (the delay before the next attempt is defined by RetryPolicy.Delay);
+//   - messages that failed more than RetryPolicy.Attempts times get StatusDead (dead letters).
+//
+// Messages with the same non-empty key are published in the order they were added:
+// a message is not fetched while an earlier message with the same key is pending
+// (including the one waiting for the retry delay).
+type Relay struct {
+	transactor   Transactor
+	publisher    Publisher
+	dialect      Dialect
+	table        string
+	clock        func() time.Time
+	batchSize    int
+	pollInterval time.Duration
+	policy       mtx.RetryPolicy
+	deadLetter   DeadLetterHandler
+}
+
+// NewRelay creates a new Relay with the SQLite dialect, DefaultTable, DefaultBatchSize and DefaultPollInterval.
+// Without a RetryPolicy (see Relay.WithRetry), failed messages are retried after the poll interval
+// and are never moved to the dead letters.
+func NewRelay(transactor Transactor, publisher Publisher) *Relay {
+	return &Relay{
+		transactor:   transactor,
+		publisher:    publisher,
+		dialect:      SQLite,
+		table:        DefaultTable,
+		clock:        time.Now,
+		batchSize:    DefaultBatchSize,
+		pollInterval: DefaultPollInterval,
+	}
+}
+
+// WithDialect returns a new Relay that uses the dialect.
+// The original Relay is not modified.
+func (r *Relay) WithDialect(dialect Dialect) *Relay {
+	c := *r
+	c.dialect = dialect
+	return &c
+}
+
+// WithTable returns a new Relay that uses the table.
+// The original Relay is not modified.
+func (r *Relay) WithTable(table string) *Relay {
+	c := *r
+	c.table = table
+	return &c
+}
+
+// WithClock returns a new Relay that uses the clock to get the current time.
+// The original Relay is not modified.
+func (r *Relay) WithClock(clock func() time.Time) *Relay {
+	c := *r
+	if clock != nil {
+		c.clock = clock
+	}
+	return &c
+}
+
+// WithBatchSize returns a new Relay that processes up to size messages per Relay.Process call.
+// Non-positive size is ignored. The original Relay is not modified.
+func (r *Relay) WithBatchSize(size int) *Relay {
+	c := *r
+	if size > 0 {
+		c.batchSize = size
+	}
+	return &c
+}
+
+// WithPollInterval returns a new Relay that waits the interval between the Relay.Process calls
+// when there are no more pending messages. Non-positive interval is ignored.
+// The original Relay is not modified.
+func (r *Relay) WithPollInterval(interval time.Duration) *Relay {
+	c := *r
+	if interval > 0 {
+		c.pollInterval = interval
+	}
+	return &c
+}
+
+// WithRetry returns a new Relay that retries failed messages according to the policy:
+// a message is moved to the dead letters after policy.Attempts() retries.
+// The policy is compatible with the saga and mtx retry policies.
+// The original Relay is not modified.
+func (r *Relay) WithRetry(policy mtx.RetryPolicy) *Relay {
+	c := *r
+	c.policy = policy
+	return &c
+}
+
+// WithDeadLetter returns a new Relay that calls the handler when a message is moved to the dead letters.
+// The original Relay is not modified.
+func (r *Relay) WithDeadLetter(handler DeadLetterHandler) *Relay {
+	c := *r
+	c.deadLetter = handler
+	return &c
+}
+
+// Run calls Relay.Process until the context is done or Relay.Process returns an error.
+// If a batch is fully published, the next batch is processed immediately, otherwise Run waits for the poll interval
+// (the failed messages are not counted, so a failing Publisher does not make Run refetch them in a loop).
+// Returns the context error when the context is done.
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		_, published, err := r.processBatch(ctx)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return err
+		}
+		if published >= r.batchSize {
+			continue
+		}
+
+		timer := time.NewTimer(r.pollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Process fetches one batch of the pending messages, publishes them and updates their status
+// within one transaction. Returns the number of the processed (published or failed) messages.
+//
+// Publish errors are stored in the outbox table and are not returned.
+// Returns ErrProcessFailed if the messages cannot be fetched or updated.
+func (r *Relay) Process(ctx context.Context) (int, error) {
+	processed, _, err := r.processBatch(ctx)
+	return processed, err
+}
+
+// processBatch processes one batch of the pending messages.
+// Returns the number of the processed messages and the number of the published ones.
+func (r *Relay) processBatch(ctx context.Context) (processed, published int, _ error) {
+	switch {
+	case r.transactor == nil:
+		return 0, 0, fmt.Errorf("outbox - relay: %w", errors.Join(ErrProcessFailed, ErrNilTransactor))
+	case r.publisher == nil:
+		return 0, 0, fmt.Errorf("outbox - relay: %w", errors.Join(ErrProcessFailed, ErrNilPublisher))
+	}
+
+	err := r.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		tx, ok := r.transactor.TryGetTx(ctx)
+		if !ok {
+			return mtx.ErrTxNotFound
+		}
+
+		msgs, err := r.fetch(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("fetch: %w", err)
+		}
+		for _, msg := range msgs {
+			ok, err := r.process(ctx, tx, msg)
+			if err != nil {
+				return fmt.Errorf("message [%d]: %w", msg.ID, err)
+			}
+			processed++
+			if ok {
+				published++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("outbox - relay: %w", errors.Join(ErrProcessFailed, err))
+	}
+	return processed, published, nil
+}
+
+// fetch selects (and locks) the first pending message of each key and the pending messages without a key.
+func (r *Relay) fetch(ctx context.Context, tx *sql.Tx) ([]Message, error) {
+	query := r.dialect.query(fmt.Sprintf(
+		`SELECT o.id, o.topic, o.key, o.payload, o.headers, o.attempts, o.created_at FROM %[1]s o
+WHERE o.status = ? AND o.available_at <= ?
+AND (o.key = '' OR NOT EXISTS (SELECT 1 FROM %[1]s p WHERE p.key = o.key AND p.status = ? AND p.id < o.id))
+ORDER BY o.id LIMIT ? %[2]s`,
+		r.table, r.dialect.Lock,
+	))
+
+	rows, err := tx.QueryContext(ctx, query, StatusPending, r.clock().UnixNano(), StatusPending, r.batchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var msgs []Message
+	for rows.Next() {
+		var (
+			msg       Message
+			headers   string
+			createdAt int64
+		)
+		if err = rows.Scan(&msg.ID, &msg.Topic, &msg.Key, &msg.Payload, &headers, &msg.Attempts, &createdAt); err != nil {
+			return nil, err
+		}
+		if msg.Headers, err = unmarshalHeaders(headers); err != nil {
+			return nil, fmt.Errorf("message [%d] headers: %w", msg.ID, err)
+		}
+		msg.CreatedAt = time.Unix(0, createdAt)
+		msgs = append(msgs, msg)
+	}
+	return msgs, rows.Err()
+}
+
+// process publishes the message and updates its status.
+// Returns "true" if the message is published.
+func (r *Relay) process(ctx context.Context, tx *sql.Tx, msg Message) (bool, error) {
+	pubErr := r.publish(ctx, msg)
+	now := r.clock()
+	if pubErr == nil {
+		_, err := tx.ExecContext(ctx,
+			r.dialect.query(fmt.Sprintf(`UPDATE %s SET status = ?, last_error = '', published_at = ? WHERE id = ?`, r.table)),
+			StatusPublished, now.UnixNano(), msg.ID,
+		)
+		return err == nil, err
+	}
+
+	var (
+		attempts  = msg.Attempts + 1
+		status    = StatusPending
+		available = now
+	)
+	switch {
+	case r.policy == nil:
+		// the minimal backoff: the message is not refetched before the next poll.
+		available = now.Add(r.pollInterval)
+	case attempts > r.policy.Attempts():
+		status = StatusDead
+	default:
+		available = now.Add(r.policy.Delay(attempts - 1))
+	}
+
+	_, err := tx.ExecContext(ctx,
+		r.dialect.query(fmt.Sprintf(`UPDATE %s SET status = ?, attempts = ?, last_error = ?, available_at = ? WHERE id = ?`, r.table)),
+		status, attempts, pubErr.Error(), available.UnixNano(), msg.ID,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	if status == StatusDead && r.deadLetter != nil {
+		msg.Attempts = attempts
+		if err = r.deadLetter(ctx, msg, pubErr); err != nil {
+			return false, fmt.Errorf("dead letter: %w", err)
+		}
+	}
+	return false, nil
+}
+
+// publish calls the Publisher and converts its panic to an error.
+func (r *Relay) publish(ctx context.Context, msg Message) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.Join(ErrPublishPanic, errors.WrapPanic(p))
+		}
+	}()
+	return r.publisher.Publish(ctx, msg)
+}
@@ -0,0 +1,355 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+	"github.com/kozmod/oniontx/mtx"
+	"github.com/kozmod/oniontx/saga"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func addMessages(t *testing.T, tr *testTransactor, outbox *Outbox, msgs ...Message) {
+	t.Helper()
+	err := tr.WithinTx(context.Background(), func(ctx context.Context) error {
+		return outbox.Add(ctx, msgs...)
+	})
+	assert.NoError(t, err)
+}
+
+func payloads(msgs []Message) []string {
+	res := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		res = append(res, string(msg.Payload))
+	}
+	return res
+}
+
+func Test_Relay(t *testing.T) {
+	t.Run("publish", func(t *testing.T) {
+		var (
+			ctx       = context.Background()
+			db, tr    = newTestTransactor(t)
+			publisher = NewMemoryPublisher()
+			relay     = NewRelay(tr, publisher)
+		)
+		addMessages(t, tr, New(tr),
+			Message{Topic: "orders", Key: "1", Payload: []byte("a"), Headers: map[string]string{"trace": "xyz"}},
+			Message{Topic: "users", Payload: []byte("b")},
+		)
+
+		n, err := relay.Process(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		published := publisher.Messages()
+		assert.Len(t, published, 2)
+		assert.Equal(t, "orders", published[0].Topic)
+		assert.Equal(t, "1", published[0].Key)
+		assert.Equal(t, "a", string(published[0].Payload))
+		assert.Equal(t, "xyz", published[0].Headers["trace"])
+		assert.False(t, published[0].CreatedAt.IsZero())
+		assert.Equal(t, "users", published[1].Topic)
+		assert.Len(t, published[1].Headers, 0)
+
+		for _, msg := range selectStored(t, db) {
+			assert.Equal(t, StatusPublished, msg.status)
+		}
+
+		n, err = relay.Process(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+		assert.Len(t, publisher.Messages(), 2)
+	})
+	t.Run("ordering_by_key", func(t *testing.T) {
+		var (
+			ctx       = context.Background()
+			_, tr     = newTestTransactor(t)
+			publisher = NewMemoryPublisher()
+			relay     = NewRelay(tr, publisher)
+		)
+		addMessages(t, tr, New(tr),
+			Message{Topic: "t", Key: "k1", Payload: []byte("k1-1")},
+			Message{Topic: "t", Key: "k1", Payload: []byte("k1-2")},
+			Message{Topic: "t", Key: "k2", Payload: []byte("k2-1")},
+			Message{Topic: "t", Payload: []byte("no-key-1")},
+			Message{Topic: "t", Key: "k1", Payload: []byte("k1-3")},
+			Message{Topic: "t", Payload: []byte("no-key-2")},
+		)
+
+		n, err := relay.Process(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, n)
+		n, err = relay.Process(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		n, err = relay.Process(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		assert.True(t, slices.Equal(
+			[]string{"k1-1", "k2-1", "no-key-1", "no-key-2", "k1-2", "k1-3"},
+			payloads(publisher.Messages()),
+		))
+	})
+	t.Run("batch_size", func(t *testing.T) {
+		var (
+			ctx       = context.Background()
+			_, tr     = newTestTransactor(t)
+			publisher = NewMemoryPublisher()
+			relay     = NewRelay(tr, publisher).WithBatchSize(2)
+		)
+		addMessages(t, tr, New(tr),
+			Message{Topic: "t", Payload: []byte("1")},
+			Message{Topic: "t", Payload: []byte("2")},
+			Message{Topic: "t", Payload: []byte("3")},
+		)
+
+		n, err := relay.Process(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+		n, err = relay.Process(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.True(t, slices.Equal([]string{"1", "2", "3"}, payloads(publisher.Messages())))
+	})
+	t.Run("retry_and_dead_letter", func(t *testing.T) {
+		var (
+			ctx       = context.Background()
+			db, tr    = newTestTransactor(t)
+			clock     = &fakeClock{now: time.Now()}
+			memory    = NewMemoryPublisher()
+			expErr    = fmt.Errorf("broker unavailable")
+			dead      []Message
+			publisher = PublisherFunc(func(ctx context.Context, msg Message) error {
+				if msg.Topic == "bad" {
+					return expErr
+				}
+				return memory.Publish(ctx, msg)
+			})
+			relay = NewRelay(tr, publisher).
+				WithClock(clock.Now).
+				WithRetry(saga.NewBaseRetryPolicy(2, time.Minute)).
+				WithDeadLetter(func(_ context.Context, msg Message, err error) error {
+					assert.ErrorIs(t, err, expErr)
+					dead = append(dead, msg)
+					return nil
+				})
+		)
+		addMessages(t, tr, New(tr).WithClock(clock.Now),
+			Message{Topic: "bad", Key: "k", Payload: []byte("bad")},
+			Message{Topic: "good", Key: "k", Payload: []byte("blocked")},
+			Message{Topic: "good", Payload: []byte("free")},
+		)
+
+		// the first attempt
+		n, err := relay.Process(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.True(t, slices.Equal([]string{"free"}, payloads(memory.Messages())))
+
+		// the retry delay is not over
+		n, err = relay.Process(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+
+		// retries
+		for attempts := uint32(2); attempts <= 3; attempts++ {
+			clock.Add(time.Minute)
+			n, err = relay.Process(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, n)
+			assert.Equal(t, attempts, selectStored(t, db)[1].attempts)
+		}
+
+		stored := selectStored(t, db)[1]
+		assert.Equal(t, StatusDead, stored.status)
+		assert.Equal(t, expErr.Error(), stored.lastError)
+		assert.Len(t, dead, 1)
+		assert.Equal(t, "bad", string(dead[0].Payload))
+		assert.Equal(t, 3, dead[0].Attempts)
+
+		// the dead letter does not block the key anymore
+		n, err = relay.Process(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.True(t, slices.Equal([]string{"free", "blocked"}, payloads(memory.Messages())))
+	})
+	t.Run("retry_without_policy", func(t *testing.T) {
+		var (
+			ctx       = context.Background()
+			db, tr    = newTestTransactor(t)
+			clock     = &fakeClock{now: time.Now()}
+			calls     int
+			publisher = PublisherFunc(func(context.Context, Message) error {
+				calls++
+				if calls < 3 {
+					return fmt.Errorf("broker unavailable")
+				}
+				return nil
+			})
+			relay = NewRelay(tr, publisher).WithClock(clock.Now)
+		)
+		addMessages(t, tr, New(tr).WithClock(clock.Now), Message{Topic: "t"})
+
+		for range 3 {
+			n, err := relay.Process(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, n)
+
+			// the failed message is not available before the poll interval
+			n, err = relay.Process(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, 0, n)
+			clock.Add(DefaultPollInterval)
+		}
+		stored := selectStored(t, db)[1]
+		assert.Equal(t, StatusPublished, stored.status)
+		assert.Equal(t, 2, stored.attempts)
+		assert.Equal(t, "", stored.lastError)
+	})
+	t.Run("publish_panic", func(t *testing.T) {
+		var (
+			ctx       = context.Background()
+			db, tr    = newTestTransactor(t)
+			publisher = PublisherFunc(func(context.Context, Message) error {
+				panic("publisher panic")
+			})
+		)
+		addMessages(t, tr, New(tr), Message{Topic: "t"})
+
+		n, err := NewRelay(tr, publisher).Process(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		stored := selectStored(t, db)[1]
+		assert.Equal(t, StatusPending, stored.status)
+		assert.Equal(t, 1, stored.attempts)
+		assert.True(t, strings.Contains(stored.lastError, ErrPublishPanic.Error()))
+	})
+	t.Run("publish_panic_stack", func(t *testing.T) {
+		var (
+			ctx   = context.Background()
+			relay = NewRelay(nil, PublisherFunc(func(context.Context, Message) error {
+				panic("publisher panic")
+			}))
+		)
+		err := relay.publish(ctx, Message{Topic: "t"})
+		assert.ErrorIs(t, err, ErrPublishPanic)
+
+		var pErr *mtx.PanicError
+		assert.True(t, errors.As(err, &pErr))
+		assert.Equal[any](t, "publisher panic", pErr.Value())
+		assert.True(t, len(pErr.Stack()) > 0)
+	})
+	t.Run("dead_letter_error_rolls_back", func(t *testing.T) {
+		var (
+			ctx       = context.Background()
+			db, tr    = newTestTransactor(t)
+			expErr    = fmt.Errorf("dead letter error")
+			publisher = PublisherFunc(func(context.Context, Message) error {
+				return fmt.Errorf("broker unavailable")
+			})
+			relay = NewRelay(tr, publisher).
+				WithRetry(saga.NewBaseRetryPolicy(0, 0)).
+				WithDeadLetter(func(context.Context, Message, error) error {
+					return expErr
+				})
+		)
+		addMessages(t, tr, New(tr), Message{Topic: "t"})
+
+		n, err := relay.Process(ctx)
+		assert.ErrorIs(t, err, ErrProcessFailed)
+		assert.ErrorIs(t, err, expErr)
+		assert.Equal(t, 0, n)
+
+		stored := selectStored(t, db)[1]
+		assert.Equal(t, StatusPending, stored.status)
+		assert.Equal(t, 0, stored.attempts)
+	})
+	t.Run("run_until_context_done", func(t *testing.T) {
+		var (
+			ctx, cancel = context.WithCancel(context.Background())
+			_, tr       = newTestTransactor(t)
+			publisher   = PublisherFunc(func(ctx context.Context, msg Message) error {
+				if string(msg.Payload) == "last" {
+					cancel()
+				}
+				return nil
+			})
+			relay = NewRelay(tr, publisher).
+				WithBatchSize(1).
+				WithPollInterval(time.Millisecond)
+		)
+		defer cancel()
+		addMessages(t, tr, New(tr),
+			Message{Topic: "t", Payload: []byte("first")},
+			Message{Topic: "t", Payload: []byte("last")},
+		)
+
+		err := relay.Run(ctx)
+		assert.True(t, errors.Is(err, context.Canceled))
+	})
+	t.Run("run_failing_publisher_full_batch", func(t *testing.T) {
+		const (
+			batchSize    = 2
+			pollInterval = 20 * time.Millisecond
+			runTimeout   = 100 * time.Millisecond
+		)
+		var (
+			ctx, cancel = context.WithTimeout(context.Background(), runTimeout)
+			db, tr      = newTestTransactor(t)
+			calls       int
+			publisher   = PublisherFunc(func(context.Context, Message) error {
+				calls++
+				return fmt.Errorf("broker unavailable")
+			})
+			relay = NewRelay(tr, publisher).
+				WithBatchSize(batchSize).
+				WithPollInterval(pollInterval)
+		)
+		defer cancel()
+		addMessages(t, tr, New(tr),
+			Message{Topic: "t", Payload: []byte("1")},
+			Message{Topic: "t", Payload: []byte("2")},
+			Message{Topic: "t", Payload: []byte("3")},
+		)
+
+		err := relay.Run(ctx)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+		// each message is published at most once per poll interval
+		assert.True(t, calls > 0)
+		assert.True(t, calls <= 3*int(runTimeout/pollInterval+1))
+		for _, msg := range selectStored(t, db) {
+			assert.Equal(t, StatusPending, msg.status)
+		}
+	})
+	t.Run("error_nil_dependencies", func(t *testing.T) {
+		var (
+			ctx   = context.Background()
+			_, tr = newTestTransactor(t)
+		)
+		_, err := NewRelay(nil, NewMemoryPublisher()).Process(ctx)
+		assert.ErrorIs(t, err, ErrNilTransactor)
+		_, err = NewRelay(tr, nil).Process(ctx)
+		assert.ErrorIs(t, err, ErrNilPublisher)
+	})
+}
@@ -0,0 +1,243 @@
+// Package pgxtx provides the [pgxpool.Pool] implementation of the mtx contracts.
+package pgxtx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/kozmod/oniontx/mtx"
+)
+
+// ErrSavepointNotFound indicates that the savepoint to roll back to or to release
+// was not created by the transaction (or was already completed).
+var ErrSavepointNotFound = fmt.Errorf("savepoint not found")
+
+// Executor represents common methods of [pgxpool.Pool] and [pgx.Tx], including the bulk operations.
+type Executor interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (commandTag pgconn.CommandTag, err error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+// Wrapper wraps [pgxpool.Pool] and implements [mtx.TxBeginner].
+type Wrapper struct {
+	*pgxpool.Pool
+}
+
+// BeginTx acquires a connection from the pool and starts a transaction.
+func (w *Wrapper) BeginTx(ctx context.Context) (*TxWrapper, error) {
+	return w.begin(ctx, pgx.TxOptions{})
+}
+
+// BeginTxWithOptions acquires a connection from the pool and starts a transaction with [mtx.TxOptions].
+// It implements [mtx.TxBeginnerWithOptions].
+func (w *Wrapper) BeginTxWithOptions(ctx context.Context, opts mtx.TxOptions) (*TxWrapper, error) {
+	return w.begin(ctx, ToPgxTxOptions(opts))
+}
+
+func (w *Wrapper) begin(ctx context.Context, opts pgx.TxOptions) (*TxWrapper, error) {
+	tx, err := w.Pool.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &TxWrapper{Tx: tx}, nil
+}
+
+// ToPgxTxOptions converts [mtx.TxOptions] to [pgx.TxOptions].
+// It can be used by the other [pgx] beginners (for example, of [pgx.Conn]) to implement [mtx.TxBeginnerWithOptions].
+func ToPgxTxOptions(opts mtx.TxOptions) pgx.TxOptions {
+	var txOptions pgx.TxOptions
+	switch opts.Isolation {
+	case mtx.LevelReadUncommitted:
+		txOptions.IsoLevel = pgx.ReadUncommitted
+	case mtx.LevelReadCommitted:
+		txOptions.IsoLevel = pgx.ReadCommitted
+	case mtx.LevelRepeatableRead, mtx.LevelSnapshot:
+		txOptions.IsoLevel = pgx.RepeatableRead
+	case mtx.LevelSerializable, mtx.LevelLinearizable:
+		txOptions.IsoLevel = pgx.Serializable
+	}
+	if opts.ReadOnly {
+		txOptions.AccessMode = pgx.ReadOnly
+	}
+	if opts.Deferrable {
+		txOptions.DeferrableMode = pgx.Deferrable
+	}
+	return txOptions
+}
+
+// TxOptions returns the [mtx.TxOption] which sets the isolation level, the access mode
+// and the deferrable mode of [pgx.TxOptions]. The empty values mean the server defaults.
+//
+// Example:
+//
+//	serializable := transactor.Transactor.WithTxOptions(pgxtx.TxOptions(pgx.TxOptions{
+//	    IsoLevel:       pgx.Serializable,
+//	    AccessMode:     pgx.ReadOnly,
+//	    DeferrableMode: pgx.Deferrable,
+//	}))
+func TxOptions(opts pgx.TxOptions) mtx.TxOption {
+	return func(o *mtx.TxOptions) {
+		switch opts.IsoLevel {
+		case pgx.ReadUncommitted:
+			o.Isolation = mtx.LevelReadUncommitted
+		case pgx.ReadCommitted:
+			o.Isolation = mtx.LevelReadCommitted
+		case pgx.RepeatableRead:
+			o.Isolation = mtx.LevelRepeatableRead
+		case pgx.Serializable:
+			o.Isolation = mtx.LevelSerializable
+		default:
+			o.Isolation = mtx.LevelDefault
+		}
+		o.ReadOnly = opts.AccessMode == pgx.ReadOnly
+		o.Deferrable = opts.DeferrableMode == pgx.Deferrable
+	}
+}
+
+// IsRetryable implements [mtx.RetryClassifier].
+// It reports serialization failures (SQLSTATE 40001) and deadlocks (SQLSTATE 40P01) as retryable.
+func IsRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch pgErr.Code {
+	case "40001", "40P01":
+		return true
+	}
+	return false
+}
+
+// TxWrapper wraps [pgx.Tx] and implements [mtx.Tx] and [mtx.SavepointTx].
+type TxWrapper struct {
+	pgx.Tx
+
+	savepoints []savepoint
+}
+
+// savepoint is a pgx pseudo nested transaction created for a nested WithinTx call.
+type savepoint struct {
+	name string
+	tx   pgx.Tx
+}
+
+// Rollback aborts the transaction and releases the connection to the pool.
+func (t *TxWrapper) Rollback(ctx context.Context) error {
+	t.savepoints = nil
+	return t.Tx.Rollback(ctx)
+}
+
+// Commit commits the transaction and releases the connection to the pool.
+func (t *TxWrapper) Commit(ctx context.Context) error {
+	t.savepoints = nil
+	return t.Tx.Commit(ctx)
+}
+
+// Savepoint starts a pseudo nested transaction ([pgx.Tx.Begin]) within the innermost one.
+func (t *TxWrapper) Savepoint(ctx context.Context, name string) error {
+	parent := t.Tx
+	if n := len(t.savepoints); n > 0 {
+		parent = t.savepoints[n-1].tx
+	}
+	tx, err := parent.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	t.savepoints = append(t.savepoints, savepoint{name: name, tx: tx})
+	return nil
+}
+
+// RollbackTo rolls back the pseudo nested transaction of the savepoint
+// (the savepoints created after it are discarded).
+func (t *TxWrapper) RollbackTo(ctx context.Context, name string) error {
+	tx, err := t.pop(name)
+	if err != nil {
+		return fmt.Errorf("rollback to savepoint [%s]: %w", name, err)
+	}
+	return tx.Rollback(ctx)
+}
+
+// Release commits (releases) the pseudo nested transaction of the savepoint.
+func (t *TxWrapper) Release(ctx context.Context, name string) error {
+	tx, err := t.pop(name)
+	if err != nil {
+		return fmt.Errorf("release savepoint [%s]: %w", name, err)
+	}
+	return tx.Commit(ctx)
+}
+
+// pop removes the savepoint and the savepoints created after it and returns its pseudo nested transaction.
+func (t *TxWrapper) pop(name string) (pgx.Tx, error) {
+	for i := len(t.savepoints) - 1; i >= 0; i-- {
+		if t.savepoints[i].name == name {
+			tx := t.savepoints[i].tx
+			t.savepoints = t.savepoints[:i]
+			return tx, nil
+		}
+	}
+	return nil, ErrSavepointNotFound
+}
+
+// Transactor manage a transaction for single [pgxpool.Pool] instance.
+type Transactor struct {
+	*mtx.Transactor[*Wrapper, *TxWrapper]
+}
+
+// NewTransactor returns new Transactor ([pgxpool] implementation).
+func NewTransactor(pool *pgxpool.Pool) *Transactor {
+	var (
+		base       = Wrapper{Pool: pool}
+		operator   = mtx.NewContextOperator[*Wrapper, *TxWrapper](&base)
+		transactor = mtx.NewTransactor[*Wrapper, *TxWrapper](&base, operator)
+	)
+	return &Transactor{
+		Transactor: transactor,
+	}
+}
+
+// With returns a new [Transactor] with the configured [mtx.Transactor].
+func (t *Transactor) With(configure func(t *mtx.Transactor[*Wrapper, *TxWrapper]) *mtx.Transactor[*Wrapper, *TxWrapper]) *Transactor {
+	return &Transactor{
+		Transactor: configure(t.Transactor),
+	}
+}
+
+// TryGetTx returns [pgx.Tx] and "true" from [context.Context] or return `false`.
+func (t *Transactor) TryGetTx(ctx context.Context) (pgx.Tx, bool) {
+	wrapper, ok := t.Transactor.TryGetTx(ctx)
+	if !ok || wrapper == nil || wrapper.Tx == nil {
+		return nil, false
+	}
+	return wrapper.Tx, true
+}
+
+// TxBeginner returns pointer of [pgxpool.Pool].
+func (t *Transactor) TxBeginner() *pgxpool.Pool {
+	return t.Transactor.TxBeginner().Pool
+}
+
+// GetExecutor returns Executor implementation ([pgx.Tx] from [context.Context] or [pgxpool.Pool]).
+func (t *Transactor) GetExecutor(ctx context.Context) Executor {
+	if tx, ok := t.TryGetTx(ctx); ok {
+		return tx
+	}
+	return t.TxBeginner()
+}
+
+// LargeObjects returns [pgx.LargeObjects] of the transaction from [context.Context].
+// The large objects can be used only within a transaction, so it returns [mtx.ErrTxNotFound] without it.
+func (t *Transactor) LargeObjects(ctx context.Context) (pgx.LargeObjects, error) {
+	tx, ok := t.TryGetTx(ctx)
+	if !ok {
+		return pgx.LargeObjects{}, fmt.Errorf("large objects: %w", mtx.ErrTxNotFound)
+	}
+	return tx.LargeObjects(), nil
+}
@@ -0,0 +1,159 @@
+package pgxtx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+	"github.com/kozmod/oniontx/mtx"
+)
+
+type (
+	// txMock records the calls of the (pseudo nested) transactions.
+	txMock struct {
+		pgx.Tx
+		name  string
+		calls *[]string
+	}
+
+	beginnerMock struct {
+		calls *[]string
+	}
+)
+
+func (tx *txMock) Begin(context.Context) (pgx.Tx, error) {
+	name := tx.name + ">sp"
+	*tx.calls = append(*tx.calls, "begin "+name)
+	return &txMock{name: name, calls: tx.calls}, nil
+}
+
+func (tx *txMock) Commit(context.Context) error {
+	*tx.calls = append(*tx.calls, "commit "+tx.name)
+	return nil
+}
+
+func (tx *txMock) Rollback(context.Context) error {
+	*tx.calls = append(*tx.calls, "rollback "+tx.name)
+	return nil
+}
+
+func (b *beginnerMock) BeginTx(context.Context) (*TxWrapper, error) {
+	*b.calls = append(*b.calls, "begin tx")
+	return &TxWrapper{Tx: &txMock{name: "tx", calls: b.calls}}, nil
+}
+
+func newTestTransactor(calls *[]string) *mtx.Transactor[*beginnerMock, *TxWrapper] {
+	b := &beginnerMock{calls: calls}
+	return mtx.NewTransactor[*beginnerMock, *TxWrapper](b, mtx.NewContextOperator[*beginnerMock, *TxWrapper](b))
+}
+
+func Test_TxWrapper_savepoints(t *testing.T) {
+	t.Run("nested_commit", func(t *testing.T) {
+		var (
+			calls []string
+			ctx   = context.Background()
+			tr    = newTestTransactor(&calls)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			return tr.WithinTx(ctx, func(ctx context.Context) error {
+				return tr.WithinTx(ctx, func(ctx context.Context) error {
+					return nil
+				})
+			})
+		})
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal([]string{
+			"begin tx",
+			"begin tx>sp",
+			"begin tx>sp>sp",
+			"commit tx>sp>sp",
+			"commit tx>sp",
+			"commit tx",
+		}, calls))
+	})
+	t.Run("nested_rollback", func(t *testing.T) {
+		var (
+			calls []string
+			ctx   = context.Background()
+			tr    = newTestTransactor(&calls)
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			err := tr.WithinTx(ctx, func(ctx context.Context) error {
+				return fmt.Errorf("nested error")
+			})
+			assert.ErrorIs(t, err, mtx.ErrRollbackToSavepointSuccess)
+			return tr.WithinTx(ctx, func(ctx context.Context) error {
+				return nil
+			})
+		})
+		assert.NoError(t, err)
+		assert.True(t, slices.Equal([]string{
+			"begin tx",
+			"begin tx>sp",
+			"rollback tx>sp",
+			"begin tx>sp",
+			"commit tx>sp",
+			"commit tx",
+		}, calls))
+	})
+	t.Run("savepoint_not_found", func(t *testing.T) {
+		var (
+			calls []string
+			ctx   = context.Background()
+			tx    = &TxWrapper{Tx: &txMock{name: "tx", calls: &calls}}
+		)
+		assert.NoError(t, tx.Savepoint(ctx, "a"))
+		assert.NoError(t, tx.Savepoint(ctx, "b"))
+		assert.NoError(t, tx.RollbackTo(ctx, "a"))
+		assert.ErrorIs(t, tx.Release(ctx, "b"), ErrSavepointNotFound)
+		assert.ErrorIs(t, tx.RollbackTo(ctx, "a"), ErrSavepointNotFound)
+		assert.True(t, slices.Equal([]string{
+			"begin tx>sp",
+			"begin tx>sp>sp",
+			"rollback tx>sp",
+		}, calls))
+	})
+}
+
+func Test_TxOptions(t *testing.T) {
+	t.Run("to_pgx", func(t *testing.T) {
+		opts := ToPgxTxOptions(mtx.TxOptions{Isolation: mtx.LevelSnapshot, ReadOnly: true, Deferrable: true})
+		assert.Equal(t, pgx.TxOptions{
+			IsoLevel:       pgx.RepeatableRead,
+			AccessMode:     pgx.ReadOnly,
+			DeferrableMode: pgx.Deferrable,
+		}, opts)
+		assert.Equal(t, pgx.TxOptions{}, ToPgxTxOptions(mtx.TxOptions{}))
+	})
+	t.Run("from_pgx", func(t *testing.T) {
+		var opts mtx.TxOptions
+		TxOptions(pgx.TxOptions{
+			IsoLevel:       pgx.Serializable,
+			AccessMode:     pgx.ReadOnly,
+			DeferrableMode: pgx.Deferrable,
+		})(&opts)
+		assert.Equal(t, mtx.TxOptions{Isolation: mtx.LevelSerializable, ReadOnly: true, Deferrable: true}, opts)
+		assert.Equal(t, pgx.TxOptions{
+			IsoLevel:       pgx.Serializable,
+			AccessMode:     pgx.ReadOnly,
+			DeferrableMode: pgx.Deferrable,
+		}, ToPgxTxOptions(opts))
+	})
+}
+
+func Test_IsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: "40001"})))
+	assert.True(t, IsRetryable(&pgconn.PgError{Code: "40P01"}))
+	assert.False(t, IsRetryable(&pgconn.PgError{Code: "23505"}))
+	assert.False(t, IsRetryable(fmt.Errorf("error")))
+}
+
+func Test_Transactor_LargeObjects(t *testing.T) {
+	_, err := NewTransactor(nil).LargeObjects(context.Background())
+	assert.ErrorIs(t, err, mtx.ErrTxNotFound)
+}
@@ -0,0 +1,62 @@
+package saga
+
+import (
+	"context"
+)
+
+// OperationKind is the kind of an instrumented saga operation.
+type OperationKind string
+
+const (
+	// OperationKindSaga is the whole Saga.Execute call.
+	OperationKindSaga OperationKind = "Saga"
+	// OperationKindAction is the action of a step.
+	OperationKindAction OperationKind = "Action"
+	// OperationKindCompensation is the compensation of a step.
+	OperationKindCompensation OperationKind = "Compensation"
+	// OperationKindRetry is a retry attempt of an operation (see WithRetry).
+	OperationKindRetry OperationKind = "Retry"
+)
+
+// OperationInfo describes an instrumented saga operation.
+type OperationInfo struct {
+	Kind OperationKind
+	// StepName and StepPosition are empty for OperationKindSaga.
+	StepName     string
+	StepPosition uint32
+	// Retry is the number of the retry attempt (starting from 1) for OperationKindRetry.
+	Retry uint32
+}
+
+// Instrumentation is notified about the start and the end of the saga operations
+// (for example, to create tracing spans).
+//
+// Start is called before the operation. The returned context is passed to the operation
+// and the returned function is called with the operation error after the operation completes.
+type Instrumentation interface {
+	Start(ctx context.Context, info OperationInfo) (context.Context, func(err error))
+}
+
+// WithInstrumentation configures the instrumentation of the saga, its steps and the retry attempts.
+func (s *Saga) WithInstrumentation(instrumentation Instrumentation) *Saga {
+	s.instrumentation = instrumentation
+	return s
+}
+
+type instrumentationKey struct{}
+
+// startOperation notifies the Instrumentation from the context (if any) about the start of the operation.
+func startOperation(ctx context.Context, info OperationInfo) (context.Context, func(err error)) {
+	instrumentation, ok := ctx.Value(instrumentationKey{}).(Instrumentation)
+	if !ok || instrumentation == nil {
+		return ctx, func(error) {}
+	}
+	newCtx, end := instrumentation.Start(ctx, info)
+	if newCtx == nil {
+		newCtx = ctx
+	}
+	if end == nil {
+		end = func(error) {}
+	}
+	return newCtx, end
+}
@@ -0,0 +1,66 @@
+package saga
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+)
+
+type instrumentationMock struct {
+	started []string
+	ended   []string
+}
+
+type instrumentationCtxKey struct{}
+
+func (m *instrumentationMock) Start(ctx context.Context, info OperationInfo) (context.Context, func(err error)) {
+	name := fmt.Sprintf("%s:%s", info.Kind, info.StepName)
+	if info.Kind == OperationKindRetry {
+		name = fmt.Sprintf("%s#%d", name, info.Retry)
+	}
+	m.started = append(m.started, name)
+	return context.WithValue(ctx, instrumentationCtxKey{}, name), func(err error) {
+		m.ended = append(m.ended, fmt.Sprintf("%s=%t", name, err == nil))
+	}
+}
+
+func TestSaga_WithInstrumentation(t *testing.T) {
+	var (
+		ctx             = context.Background()
+		instrumentation = &instrumentationMock{}
+		calls           int
+		steps           = []Step{
+			NewStep("step0").
+				WithAction(NewOperation(func(ctx context.Context, _ Track) error {
+					assert.Equal(t, "Action:step0", ctx.Value(instrumentationCtxKey{}).(string))
+					return nil
+				})).
+				WithCompensation(NewOperation(func(ctx context.Context, _ Track) error {
+					assert.Equal(t, "Compensation:step0", ctx.Value(instrumentationCtxKey{}).(string))
+					return nil
+				})),
+			NewStep("step1").
+				WithAction(NewOperation(func(ctx context.Context, _ Track) error {
+					calls++
+					if calls > 1 {
+						assert.Equal(t, fmt.Sprintf("Retry:step1#%d", calls-1), ctx.Value(instrumentationCtxKey{}).(string))
+					}
+					return fmt.Errorf("action error")
+				}).WithRetry(NewBaseRetryPolicy(2, 0))),
+		}
+	)
+
+	_, err := NewSaga(steps).WithInstrumentation(instrumentation).Execute(ctx)
+	assert.Error(t, err)
+	assert.True(t, slices.Equal(
+		[]string{"Saga:", "Action:step0", "Action:step1", "Retry:step1#1", "Retry:step1#2", "Compensation:step0"},
+		instrumentation.started,
+	))
+	assert.True(t, slices.Equal(
+		[]string{"Action:step0=true", "Retry:step1#1=false", "Retry:step1#2=false", "Action:step1=false", "Compensation:step0=true", "Saga:=false"},
+		instrumentation.ended,
+	))
+}
@@ -65,13 +65,35 @@ func NewOperation(op OperationFunc) Operation {
 
 // WithPanicRecovery wraps the Operation with panic recovery logic.
 // If the original function panics, the panic is recovered and returned as an error
-// that wraps both the original panic value and ErrPanicRecovered.
+// that wraps both PanicError (the original panic value and the stack trace) and ErrPanicRecovered.
 // Returns a new Operation with panic recovery enabled.
 func (o Operation) WithPanicRecovery() Operation {
 	o.fn = WithPanicRecovery(o.fn)
 	return o
 }
 
+// WithPanicPolicy wraps the Operation with the panic handling defined by the policy (see PanicPolicy).
+// Returns a new Operation with the panic policy applied.
+//
+// Decorators are applied in call order: when WithPanicPolicy is applied before WithRetry,
+// a recovered panic is retried as an ordinary error.
+//
+// Example:
+//
+//	// compensates the completed steps and crashes with the original panic.
+//	action := NewOperation(fn).WithPanicPolicy(PanicPolicyCompensateRepanic)
+func (o Operation) WithPanicPolicy(policy PanicPolicy) Operation {
+	switch policy {
+	case PanicPolicyRecover:
+		return o.WithPanicRecovery()
+	case PanicPolicyCompensateRepanic:
+		o.fn = withCompensateRepanic(o.fn)
+		return o
+	default:
+		return o
+	}
+}
+
 // WithRetry wraps the Operation with retry logic.
 // The function will be retried according to the provided RetryPolicy.
 // Returns a new Operation with retry logic enabled.
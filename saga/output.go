@@ -0,0 +1,69 @@
+package saga
+
+import (
+	"context"
+	"sync"
+)
+
+// Output holds the result of an operation created by NewOperationWithOutput,
+// so the next steps and the compensations can use it (for example, the identifier of a reservation).
+// The zero value is ready to use.
+type Output[R any] struct {
+	mu    sync.RWMutex
+	value R
+	set   bool
+}
+
+// Get returns the result of the last successful call of the operation
+// and reports whether the operation succeeded.
+// If the operation has not succeeded, the zero value of R is returned.
+func (o *Output[R]) Get() (R, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.value, o.set
+}
+
+// Value returns the result of the last successful call of the operation
+// or the zero value of R.
+func (o *Output[R]) Value() R {
+	value, _ := o.Get()
+	return value
+}
+
+func (o *Output[R]) store(value R) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.value = value
+	o.set = true
+}
+
+// NewOperationWithOutput creates a new Operation from the function that produces a result.
+// The result is stored in the output only when the function succeeds,
+// so a failed call (or a failed retry attempt) does not change the output.
+// A nil output discards the result. Passing nil op creates a no-op operation.
+//
+// Example:
+//
+//	var reservation saga.Output[ReservationID]
+//	step := saga.NewStep("reserve").
+//	    WithAction(saga.NewOperationWithOutput(&reservation, func(ctx context.Context, _ saga.Track) (ReservationID, error) {
+//	        return inventory.Reserve(ctx, item)
+//	    })).
+//	    WithCompensation(saga.NewOperation(func(ctx context.Context, _ saga.Track) error {
+//	        return inventory.Release(ctx, reservation.Value())
+//	    }))
+func NewOperationWithOutput[R any](output *Output[R], op func(ctx context.Context, track Track) (R, error)) Operation {
+	if op == nil {
+		return NewOperation(nil)
+	}
+	return NewOperation(func(ctx context.Context, track Track) error {
+		value, err := op(ctx, track)
+		if err != nil {
+			return err
+		}
+		if output != nil {
+			output.store(value)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,98 @@
+package saga
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+)
+
+func Test_NewOperationWithOutput(t *testing.T) {
+	t.Run("success_and_compensation", func(t *testing.T) {
+		var (
+			ctx         = context.Background()
+			reservation Output[string]
+			released    string
+			steps       = []Step{
+				NewStep("reserve").
+					WithAction(NewOperationWithOutput(&reservation, func(context.Context, Track) (string, error) {
+						return "reservation_1", nil
+					})).
+					WithCompensation(NewOperation(func(context.Context, Track) error {
+						released = reservation.Value()
+						return nil
+					})),
+				NewStep("charge").
+					WithAction(NewOperation(func(context.Context, Track) error {
+						return fmt.Errorf("charge error")
+					})),
+			}
+		)
+
+		_, err := NewSaga(steps).Execute(ctx)
+		assert.ErrorIs(t, err, ErrActionFailed)
+		assert.Equal(t, "reservation_1", released)
+
+		value, ok := reservation.Get()
+		assert.True(t, ok)
+		assert.Equal(t, "reservation_1", value)
+	})
+	t.Run("failed_attempts_do_not_change_output", func(t *testing.T) {
+		var (
+			ctx   = context.Background()
+			out   Output[int]
+			calls int
+			steps = []Step{
+				NewStep("step").
+					WithAction(NewOperationWithOutput(&out, func(context.Context, Track) (int, error) {
+						calls++
+						if calls < 2 {
+							return -1, fmt.Errorf("attempt error")
+						}
+						return calls, nil
+					}).WithRetry(NewBaseRetryPolicy(2, 0))),
+			}
+		)
+
+		res, err := NewSaga(steps).Execute(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, StageResultSuccess, res.Status)
+		assert.Equal(t, 2, out.Value())
+	})
+	t.Run("failed_operation", func(t *testing.T) {
+		var (
+			ctx   = context.Background()
+			out   Output[int]
+			steps = []Step{
+				NewStep("step").
+					WithAction(NewOperationWithOutput(&out, func(context.Context, Track) (int, error) {
+						return 1, fmt.Errorf("action error")
+					})),
+			}
+		)
+
+		_, err := NewSaga(steps).Execute(ctx)
+		assert.Error(t, err)
+		value, ok := out.Get()
+		assert.False(t, ok)
+		assert.Equal(t, 0, value)
+	})
+	t.Run("nil", func(t *testing.T) {
+		var (
+			ctx   = context.Background()
+			steps = []Step{
+				NewStep("nil_output").
+					WithAction(NewOperationWithOutput[int](nil, func(context.Context, Track) (int, error) {
+						return 1, nil
+					})),
+				NewStep("nil_op").
+					WithAction(NewOperationWithOutput[int](&Output[int]{}, nil)),
+			}
+		)
+
+		res, err := NewSaga(steps).Execute(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, StageResultSuccess, res.Status)
+	})
+}
@@ -2,13 +2,51 @@ package saga
 
 import (
 	"context"
+	stderrors "errors"
+	"fmt"
 
 	"github.com/kozmod/oniontx/internal/errors"
 )
 
+// PanicError is the error created from a recovered panic (see WithPanicRecovery).
+// It is wrapped by the operation error together with ErrPanicRecovered.
+// PanicError.Value returns the original panic value and PanicError.Stack returns the stack trace captured at the recovery.
+type PanicError = errors.PanicError
+
+// PanicPolicy defines how an Operation handles a panic (see Operation.WithPanicPolicy).
+type PanicPolicy uint8
+
+const (
+	// PanicPolicyRecover recovers the panic and returns an error with ErrPanicRecovered and PanicError
+	// (the same as Operation.WithPanicRecovery).
+	PanicPolicyRecover PanicPolicy = iota
+
+	// PanicPolicyCompensateRepanic recovers the panic, so the Saga fails the operation as usual
+	// and runs the compensations. After that, Saga.Execute re-panics with the original panic value.
+	PanicPolicyCompensateRepanic
+
+	// PanicPolicyRepanic does not recover the panic: it is propagated from Saga.Execute
+	// without the compensations.
+	PanicPolicyRepanic
+)
+
+// String returns the name of the PanicPolicy.
+func (p PanicPolicy) String() string {
+	switch p {
+	case PanicPolicyRecover:
+		return "Recover"
+	case PanicPolicyCompensateRepanic:
+		return "CompensateRepanic"
+	case PanicPolicyRepanic:
+		return "Repanic"
+	default:
+		return fmt.Sprintf("PanicPolicy(%d)", p)
+	}
+}
+
 // WithPanicRecovery returns an OperationFunc with panic recovery logic.
 // The returned function recovers from panics raised by fn and converts them to
-// an error that includes both ErrPanicRecovered and the original panic value.
+// an error that includes both ErrPanicRecovered and PanicError with the original panic value and the stack trace.
 //
 // Example:
 //
@@ -30,3 +68,40 @@ func WithPanicRecovery(fn func(ctx context.Context, track Track) error) Operatio
 		return err
 	}
 }
+
+// repanicError marks the PanicError of an operation with PanicPolicyCompensateRepanic:
+// Saga.Execute re-panics with the panic value after the compensations.
+type repanicError struct {
+	err *PanicError
+}
+
+func (e *repanicError) Error() string {
+	return e.err.Error()
+}
+
+func (e *repanicError) Unwrap() error {
+	return e.err
+}
+
+// withCompensateRepanic returns an OperationFunc which recovers from panics raised by fn
+// and converts them to an error with ErrPanicRecovered and repanicError.
+func withCompensateRepanic(fn OperationFunc) OperationFunc {
+	return func(ctx context.Context, track Track) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = errors.Join(ErrPanicRecovered, &repanicError{err: errors.WrapPanic(p)})
+			}
+		}()
+		err = fn(ctx, track)
+		return err
+	}
+}
+
+// repanicValue returns the panic value of the operation error created with PanicPolicyCompensateRepanic.
+func repanicValue(err error) (any, bool) {
+	var rErr *repanicError
+	if err == nil || !stderrors.As(err, &rErr) {
+		return nil, false
+	}
+	return rErr.err.Value(), true
+}
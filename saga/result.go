@@ -2,7 +2,9 @@ package saga
 
 import (
 	"fmt"
+	"log/slog"
 	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/kozmod/oniontx/internal/errors"
@@ -59,6 +61,19 @@ func (r Result) String() string {
 	return builder.String()
 }
 
+// LogValue implements slog.LogValuer.
+// The steps and the errors of the steps are logged as nested groups.
+func (r Result) LogValue() slog.Value {
+	steps := make([]slog.Attr, 0, len(r.Steps))
+	for i, step := range r.Steps {
+		steps = append(steps, slog.Any(strconv.Itoa(i), step))
+	}
+	return slog.GroupValue(
+		slog.String("status", string(r.Status)),
+		slog.Attr{Key: "steps", Value: slog.GroupValue(steps...)},
+	)
+}
+
 // prepareResult analyzes execution tracks and produces a final Result.
 func prepareResult(tracks []*simpleTracker) (Result, error) {
 	var (
@@ -1,9 +1,12 @@
 package saga
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"slices"
 	"strings"
 	"testing"
@@ -62,6 +65,69 @@ func Test_Result_String(t *testing.T) {
 	})
 }
 
+func Test_Result_LogValue(t *testing.T) {
+	var (
+		buf       bytes.Buffer
+		logger    = slog.New(slog.NewJSONHandler(&buf, nil))
+		actionErr = fmt.Errorf("retry [1]: %w", errors.Join(ErrActionFailed, fmt.Errorf("card declined")))
+		res       = Result{
+			Status: StageResultCompensated,
+			Steps: []StepData{
+				{
+					StepPosition: 0,
+					StepName:     "payment",
+					Action: TrackData{
+						Calls:  2,
+						Errors: []error{actionErr},
+						Status: ExecutionStatusFail,
+					},
+					Compensation: TrackData{
+						Calls:  1,
+						Status: ExecutionStatusSuccess,
+					},
+					CompensationOnActionFailure: true,
+				},
+			},
+		}
+	)
+	logger.Info("saga", slog.Any("result", res))
+
+	var record struct {
+		Result struct {
+			Status string `json:"status"`
+			Steps  map[string]struct {
+				Name   string `json:"name"`
+				Action struct {
+					Status string `json:"status"`
+					Calls  int    `json:"calls"`
+					Errors map[string]struct {
+						Message string `json:"message"`
+						Errors  map[string]struct {
+							Message string `json:"message"`
+						} `json:"errors"`
+					} `json:"errors"`
+				} `json:"action"`
+				Compensation struct {
+					Status string `json:"status"`
+				} `json:"compensation"`
+				CompensationOnActionFailure bool `json:"compensation_on_action_failure"`
+			} `json:"steps"`
+		} `json:"result"`
+	}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, string(StageResultCompensated), record.Result.Status)
+
+	step := record.Result.Steps["0"]
+	assert.Equal(t, "payment", step.Name)
+	assert.Equal(t, 2, step.Action.Calls)
+	assert.Equal(t, string(ExecutionStatusFail), step.Action.Status)
+	assert.Equal(t, string(ExecutionStatusSuccess), step.Compensation.Status)
+	assert.True(t, step.CompensationOnActionFailure)
+	assert.Equal(t, actionErr.Error(), step.Action.Errors["0"].Message)
+	assert.Equal(t, ErrActionFailed.Error(), step.Action.Errors["0"].Errors["0"].Message)
+	assert.Equal(t, "card declined", step.Action.Errors["0"].Errors["1"].Message)
+}
+
 func Test_SagaExecute_preservesExecutionErrors(t *testing.T) {
 	var (
 		actionErr       = fmt.Errorf("action error")
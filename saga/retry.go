@@ -183,7 +183,18 @@ func WithRetry(policy RetryPolicy, fn func(ctx context.Context, track Track) err
 
 				break stop
 			default:
-				err = fn(ctx, retryTrack)
+				var stepData StepData
+				if track != nil {
+					stepData = track.GetStepData()
+				}
+				retryCtx, endRetry := startOperation(ctx, OperationInfo{
+					Kind:         OperationKindRetry,
+					StepName:     stepData.StepName,
+					StepPosition: stepData.StepPosition,
+					Retry:        i + 1,
+				})
+				err = fn(retryCtx, retryTrack)
+				endRetry(err)
 				if err == nil {
 					retryTrack.apply(newTrackSucceededAct())
 					break stop
@@ -62,6 +62,7 @@ var (
 type Saga struct {
 	steps                      []Step
 	compensationContextFactory CtxFactory
+	instrumentation            Instrumentation
 }
 
 // NewSaga creates a new Saga instance.
@@ -87,8 +88,14 @@ func (s *Saga) Execute(ctx context.Context) (Result, error) {
 	var (
 		tracks         []*simpleTracker
 		completedTrack []*simpleTracker
+		repanic        []any
 	)
 
+	if s.instrumentation != nil {
+		ctx = context.WithValue(ctx, instrumentationKey{}, s.instrumentation)
+	}
+	ctx, end := startOperation(ctx, OperationInfo{Kind: OperationKindSaga})
+
 stop:
 	for i, step := range s.steps {
 		tr := newInMemoryTrack(uint32(i), step)
@@ -103,7 +110,7 @@ stop:
 					),
 				),
 			)
-			s.compensate(ctx, completedTrack)
+			repanic = s.compensate(ctx, completedTrack)
 			break stop
 		default:
 			if step.action.fn == nil {
@@ -114,10 +121,19 @@ stop:
 				completedTrack = append(completedTrack, tr)
 			}
 
-			err := step.action.fn(ctx, tr.action)
+			actionCtx, endAction := startOperation(ctx, OperationInfo{
+				Kind:         OperationKindAction,
+				StepName:     step.name,
+				StepPosition: uint32(i),
+			})
+			err := step.action.fn(actionCtx, tr.action)
+			endAction(operationError(err, tr.action))
 
 			switch status := tr.action.GetTrackData().Status; {
 			case err != nil || status == ExecutionStatusFail:
+				if p, ok := repanicValue(err); ok {
+					repanic = append(repanic, p)
+				}
 				if err != nil {
 					err = errors.Join(ErrActionFailed, err)
 					tr.action.apply(
@@ -127,7 +143,7 @@ stop:
 					)
 				}
 				// Run compensation when an action error arises.
-				s.compensate(ctx, completedTrack)
+				repanic = append(repanic, s.compensate(ctx, completedTrack)...)
 				break stop
 			default:
 				if status != ExecutionStatusSuccess {
@@ -142,11 +158,28 @@ stop:
 	}
 
 	result, err := prepareResult(tracks)
+	end(err)
+	if len(repanic) > 0 {
+		// the first panic is raised after the compensations (see PanicPolicyCompensateRepanic).
+		panic(repanic[0])
+	}
 	return result, err
 }
 
+// operationError returns err or the errors of the failed track.
+func operationError(err error, track Track) error {
+	if err != nil {
+		return err
+	}
+	if data := track.GetTrackData(); data.Status == ExecutionStatusFail {
+		return errors.Join(data.Errors...)
+	}
+	return nil
+}
+
 // compensate triggers compensation operations in reverse completion order.
-func (s *Saga) compensate(ctx context.Context, tracks []*simpleTracker) {
+// It returns the panic values of the compensations with PanicPolicyCompensateRepanic.
+func (s *Saga) compensate(ctx context.Context, tracks []*simpleTracker) (repanic []any) {
 	ctx = s.compensationContextFactory.Apply(ctx)
 
 stop:
@@ -170,7 +203,16 @@ stop:
 
 			break stop
 		default:
-			err := tr.compensationFunc(ctx, tr.compensation)
+			compensationCtx, endCompensation := startOperation(ctx, OperationInfo{
+				Kind:         OperationKindCompensation,
+				StepName:     tr.stepName,
+				StepPosition: tr.stepPosition,
+			})
+			err := tr.compensationFunc(compensationCtx, tr.compensation)
+			endCompensation(operationError(err, tr.compensation))
+			if p, ok := repanicValue(err); ok {
+				repanic = append(repanic, p)
+			}
 			if err != nil {
 				tr.compensation.apply(newTrackFailedAct(
 					fmt.Errorf("compensation failed [%d#%s]: %w", i, tr.stepName, err),
@@ -180,4 +222,5 @@ stop:
 			}
 		}
 	}
+	return repanic
 }
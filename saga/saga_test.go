@@ -369,6 +369,96 @@ func Test_Saga_panic_recovery(t *testing.T) {
 	})
 }
 
+func Test_Saga_panic_policy(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		recovered = func(fn func()) (p any) {
+			defer func() {
+				p = recover()
+			}()
+			fn()
+			return nil
+		}
+	)
+	newSteps := func(policy PanicPolicy, compensated *int) []Step {
+		return []Step{
+			NewStep("step0").
+				WithAction(NewOperation(func(context.Context, Track) error {
+					return nil
+				})).
+				WithCompensation(NewOperation(func(context.Context, Track) error {
+					*compensated++
+					return nil
+				})),
+			NewStep("step1").
+				WithAction(NewOperation(func(context.Context, Track) error {
+					panic("panic_policy!")
+				}).WithPanicPolicy(policy)),
+		}
+	}
+
+	t.Run("recover", func(t *testing.T) {
+		var compensated int
+		res, err := NewSaga(newSteps(PanicPolicyRecover, &compensated)).Execute(ctx)
+		assert.ErrorIs(t, err, ErrActionFailed)
+		assert.Equal(t, 1, compensated)
+		assert.ErrorIs(t, res.Steps[1].Action.Errors[0], ErrPanicRecovered)
+
+		var pErr *PanicError
+		assert.True(t, errors.As(res.Steps[1].Action.Errors[0], &pErr))
+		assert.Equal[any](t, "panic_policy!", pErr.Value())
+		assert.True(t, strings.Contains(string(pErr.Stack()), "Test_Saga_panic_policy"))
+	})
+	t.Run("compensate_repanic", func(t *testing.T) {
+		var compensated int
+		p := recovered(func() {
+			_, _ = NewSaga(newSteps(PanicPolicyCompensateRepanic, &compensated)).Execute(ctx)
+		})
+		assert.Equal[any](t, "panic_policy!", p)
+		assert.Equal(t, 1, compensated)
+	})
+	t.Run("compensate_repanic_compensation", func(t *testing.T) {
+		var (
+			calls int
+			steps = []Step{
+				NewStep("step0").
+					WithAction(NewOperation(func(context.Context, Track) error {
+						return nil
+					})).
+					WithCompensation(NewOperation(func(context.Context, Track) error {
+						calls++
+						return nil
+					})),
+				NewStep("step1").
+					WithAction(NewOperation(func(context.Context, Track) error {
+						return nil
+					})).
+					WithCompensation(NewOperation(func(context.Context, Track) error {
+						panic("compensation_panic!")
+					}).WithPanicPolicy(PanicPolicyCompensateRepanic)),
+				NewStep("step2").
+					WithAction(NewOperation(func(context.Context, Track) error {
+						return testtool.ErrExpTestA
+					})),
+			}
+		)
+		p := recovered(func() {
+			_, _ = NewSaga(steps).Execute(ctx)
+		})
+		assert.Equal[any](t, "compensation_panic!", p)
+		// the remaining compensations are called before the panic.
+		assert.Equal(t, 1, calls)
+	})
+	t.Run("repanic", func(t *testing.T) {
+		var compensated int
+		p := recovered(func() {
+			_, _ = NewSaga(newSteps(PanicPolicyRepanic, &compensated)).Execute(ctx)
+		})
+		assert.Equal[any](t, "panic_policy!", p)
+		assert.Equal(t, 0, compensated)
+	})
+}
+
 func Test_actions_v2(t *testing.T) {
 	var (
 		ctx = context.Background()
@@ -2,8 +2,12 @@ package saga
 
 import (
 	"fmt"
+	"log/slog"
 	"slices"
+	"strconv"
 	"strings"
+
+	"github.com/kozmod/oniontx/internal/errors"
 )
 
 // ExecutionStatus represents the current state of an action or compensation execution.
@@ -75,6 +79,17 @@ func (s StepData) string(errorsInTrackDataString bool) string {
 	)
 }
 
+// LogValue implements slog.LogValuer.
+func (s StepData) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Uint64("position", uint64(s.StepPosition)),
+		slog.String("name", s.StepName),
+		slog.Attr{Key: "action", Value: s.Action.logValue()},
+		slog.Attr{Key: "compensation", Value: s.Compensation.logValue()},
+		slog.Bool("compensation_on_action_failure", s.CompensationOnActionFailure),
+	)
+}
+
 // TrackData contains execution metrics for a single operation snapshot.
 type TrackData struct {
 	Calls  uint32
@@ -113,6 +128,22 @@ func (ed *TrackData) string(includeErrors bool) string {
 	return builder.String()
 }
 
+// logValue returns the structured value of the TrackData (see StepData.LogValue).
+func (ed *TrackData) logValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("status", string(ed.Status)),
+		slog.Uint64("calls", uint64(ed.Calls)),
+	}
+	if len(ed.Errors) > 0 {
+		errs := make([]slog.Attr, 0, len(ed.Errors))
+		for i, err := range ed.Errors {
+			errs = append(errs, slog.Attr{Key: strconv.Itoa(i), Value: errors.LogValue(err)})
+		}
+		attrs = append(attrs, slog.Attr{Key: "errors", Value: slog.GroupValue(errs...)})
+	}
+	return slog.GroupValue(attrs...)
+}
+
 // executionTrack holds execution details for a single operation.
 type executionTrack struct {
 	calls  uint32
@@ -0,0 +1,107 @@
+package slogtx
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"time"
+
+	"github.com/kozmod/oniontx/saga"
+)
+
+// SagaEvent is the type of the saga operation transition.
+type SagaEvent string
+
+const (
+	// SagaEventStarted is logged before the operation.
+	SagaEventStarted SagaEvent = "Started"
+	// SagaEventSucceeded is logged after the successful operation.
+	SagaEventSucceeded SagaEvent = "Succeeded"
+	// SagaEventFailed is logged after the failed operation.
+	SagaEventFailed SagaEvent = "Failed"
+)
+
+// SagaLogger is a saga.Instrumentation that logs the transitions of the saga operations
+// (saga.Saga.Execute, the step actions, the compensations and the retry attempts).
+//
+// By default, SagaEventStarted is logged with slog.LevelDebug, SagaEventSucceeded with slog.LevelInfo
+// and SagaEventFailed with slog.LevelError.
+//
+// Example:
+//
+//	result, err := saga.NewSaga(steps).
+//	    WithInstrumentation(slogtx.NewSagaLogger(logger)).
+//	    Execute(ctx)
+type SagaLogger struct {
+	logger *slog.Logger
+	levels map[SagaEvent]slog.Level
+}
+
+// NewSagaLogger creates a new SagaLogger.
+// If logger is nil, slog.Default is used.
+func NewSagaLogger(logger *slog.Logger) *SagaLogger {
+	return &SagaLogger{
+		logger: logger,
+		levels: map[SagaEvent]slog.Level{
+			SagaEventStarted:   slog.LevelDebug,
+			SagaEventSucceeded: slog.LevelInfo,
+			SagaEventFailed:    slog.LevelError,
+		},
+	}
+}
+
+// WithLevel returns a new SagaLogger that logs the event with the level.
+// The original SagaLogger is not modified.
+func (l *SagaLogger) WithLevel(event SagaEvent, level slog.Level) *SagaLogger {
+	c := *l
+	c.levels = maps.Clone(l.levels)
+	c.levels[event] = level
+	return &c
+}
+
+// Start implements saga.Instrumentation.
+func (l *SagaLogger) Start(ctx context.Context, info saga.OperationInfo) (context.Context, func(err error)) {
+	var (
+		logger = loggerOrDefault(l.logger)
+		start  = time.Now()
+	)
+	l.log(ctx, logger, info, SagaEventStarted)
+	return ctx, func(err error) {
+		if err != nil {
+			l.log(ctx, logger, info, SagaEventFailed,
+				slog.String(KeyStatus, string(saga.ExecutionStatusFail)),
+				slog.Duration(KeyDuration, time.Since(start)),
+				Err(err),
+			)
+			return
+		}
+		l.log(ctx, logger, info, SagaEventSucceeded,
+			slog.String(KeyStatus, string(saga.ExecutionStatusSuccess)),
+			slog.Duration(KeyDuration, time.Since(start)),
+		)
+	}
+}
+
+func (l *SagaLogger) log(ctx context.Context, logger *slog.Logger, info saga.OperationInfo, event SagaEvent, attrs ...slog.Attr) {
+	level := l.levels[event]
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	record := make([]slog.Attr, 0, len(attrs)+5)
+	record = append(record,
+		slog.String(KeyEvent, string(event)),
+		slog.String(KeyOperation, string(info.Kind)),
+	)
+	if info.Kind != saga.OperationKindSaga {
+		record = append(record,
+			slog.String(KeyStepName, info.StepName),
+			slog.Uint64(KeyStepPosition, uint64(info.StepPosition)),
+		)
+	}
+	if info.Kind == saga.OperationKindRetry {
+		record = append(record, slog.Uint64(KeyAttempt, uint64(info.Retry)))
+	}
+	record = append(record, attrs...)
+	logger.LogAttrs(ctx, level, "saga "+string(info.Kind)+" "+string(event), record...)
+}
@@ -0,0 +1,81 @@
+package slogtx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+	"github.com/kozmod/oniontx/saga"
+)
+
+func Test_SagaLogger(t *testing.T) {
+	var (
+		buf   bytes.Buffer
+		ctx   = context.Background()
+		steps = []saga.Step{
+			saga.NewStep("reserve").
+				WithAction(saga.NewOperation(func(context.Context, saga.Track) error {
+					return nil
+				})).
+				WithCompensation(saga.NewOperation(func(context.Context, saga.Track) error {
+					return nil
+				})),
+			saga.NewStep("charge").
+				WithAction(saga.NewOperation(func(context.Context, saga.Track) error {
+					return fmt.Errorf("charge error")
+				}).WithRetry(saga.NewBaseRetryPolicy(1, 0))),
+		}
+		logger = NewSagaLogger(newLogger(&buf, slog.LevelInfo)).
+			WithLevel(SagaEventStarted, slog.LevelInfo).
+			WithLevel(SagaEventFailed, slog.LevelWarn)
+	)
+
+	_, err := saga.NewSaga(steps).WithInstrumentation(logger).Execute(ctx)
+	assert.Error(t, err)
+
+	rs := records(t, &buf)
+	msgs := make([]string, 0, len(rs))
+	for _, r := range rs {
+		msgs = append(msgs, r.Msg)
+	}
+	assert.Equal(t, fmt.Sprint([]string{
+		"saga Saga Started",
+		"saga Action Started",
+		"saga Action Succeeded",
+		"saga Action Started",
+		"saga Retry Started",
+		"saga Retry Failed",
+		"saga Action Failed",
+		"saga Compensation Started",
+		"saga Compensation Succeeded",
+		"saga Saga Failed",
+	}), fmt.Sprint(msgs))
+
+	var (
+		succeeded = rs[2]
+		retry     = rs[5]
+		action    = rs[6]
+		root      = rs[9]
+	)
+	assert.Equal(t, slog.LevelInfo.String(), succeeded.Level)
+	assert.Equal(t, "reserve", succeeded.StepName)
+	assert.Equal(t, string(saga.ExecutionStatusSuccess), succeeded.Status)
+	assert.True(t, succeeded.Duration != nil)
+	assert.True(t, succeeded.Error == nil)
+
+	assert.Equal(t, slog.LevelWarn.String(), retry.Level)
+	assert.Equal(t, string(saga.OperationKindRetry), retry.Operation)
+	assert.Equal(t, uint32(1), retry.Attempt)
+	assert.Equal(t, "charge error", retry.Error.Message)
+
+	assert.Equal(t, "charge", action.StepName)
+	assert.Equal(t, uint32(1), action.StepPosition)
+	assert.Equal(t, string(saga.ExecutionStatusFail), action.Status)
+
+	assert.Equal(t, "", root.StepName)
+	assert.Equal(t, err.Error(), root.Error.Message)
+	assert.ErrorIs(t, err, saga.ErrActionFailed)
+}
@@ -0,0 +1,48 @@
+// Package slogtx logs the mtx transaction lifecycle events and the saga operations
+// as structured log/slog records.
+//
+// TxLogger is a mtx.TxObserver and SagaLogger is a saga.Instrumentation.
+// The errors are logged as nested groups (see ErrorAttr), so the joined errors can be indexed
+// by the log pipelines.
+package slogtx
+
+import (
+	"log/slog"
+
+	"github.com/kozmod/oniontx/internal/errors"
+)
+
+// The keys of the record attributes.
+const (
+	KeyEvent        = "event"
+	KeyTxID         = "tx_id"
+	KeyDepth        = "depth"
+	KeyDuration     = "duration"
+	KeyTxDuration   = "tx_duration"
+	KeyOperation    = "operation"
+	KeyStepName     = "step_name"
+	KeyStepPosition = "step_position"
+	KeyAttempt      = "attempt"
+	KeyStatus       = "status"
+	KeyError        = "error"
+	// KeyErrorMessage and KeyJoinedErrors are the keys inside the error group (see ErrorAttr).
+	KeyErrorMessage = errors.LogKeyMessage
+	KeyJoinedErrors = errors.LogKeyErrors
+)
+
+// ErrorAttr returns the attribute of the error.
+// The value is a group that contains the message of the error and the joined errors
+// (`Unwrap() []error`) as nested groups keyed by the index of the error:
+//
+//	{"message": "before commit: hook [0]: a: b", "errors": {"0": {"message": "a"}, "1": {"message": "b"}}}
+//
+// The errors wrapped by a single error (`Unwrap() error`) are not logged separately,
+// because the message of the wrapper already contains their messages.
+func ErrorAttr(key string, err error) slog.Attr {
+	return slog.Attr{Key: key, Value: errors.LogValue(err)}
+}
+
+// Err returns ErrorAttr with the KeyError key.
+func Err(err error) slog.Attr {
+	return ErrorAttr(KeyError, err)
+}
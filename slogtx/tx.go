@@ -0,0 +1,96 @@
+package slogtx
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+
+	"github.com/kozmod/oniontx/mtx"
+)
+
+// TxLogger is a mtx.TxObserver that logs the transaction lifecycle events.
+//
+// By default, the events are logged with slog.LevelDebug and the events with errors
+// (failed begin, commit or rollback, recovered panic) are logged with slog.LevelError.
+//
+// Example:
+//
+//	transactor = transactor.WithObserver(slogtx.NewTxLogger(logger).
+//	    WithLevel(mtx.TxEventCommitEnd, slog.LevelInfo))
+type TxLogger struct {
+	logger     *slog.Logger
+	levels     map[mtx.TxEventType]slog.Level
+	errorLevel slog.Level
+}
+
+// NewTxLogger creates a new TxLogger.
+// If logger is nil, slog.Default is used.
+func NewTxLogger(logger *slog.Logger) *TxLogger {
+	return &TxLogger{
+		logger:     logger,
+		levels:     make(map[mtx.TxEventType]slog.Level),
+		errorLevel: slog.LevelError,
+	}
+}
+
+// WithLevel returns a new TxLogger that logs the events of the type (without errors) with the level.
+// The original TxLogger is not modified.
+func (l *TxLogger) WithLevel(eventType mtx.TxEventType, level slog.Level) *TxLogger {
+	c := *l
+	c.levels = maps.Clone(l.levels)
+	c.levels[eventType] = level
+	return &c
+}
+
+// WithErrorLevel returns a new TxLogger that logs the events with errors with the level.
+// The original TxLogger is not modified.
+func (l *TxLogger) WithErrorLevel(level slog.Level) *TxLogger {
+	c := *l
+	c.errorLevel = level
+	return &c
+}
+
+// OnTxEvent implements mtx.TxObserver.
+func (l *TxLogger) OnTxEvent(ctx context.Context, event mtx.TxEvent) {
+	var (
+		logger = loggerOrDefault(l.logger)
+		level  = l.level(event)
+	)
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String(KeyEvent, event.Type.String()),
+		slog.Uint64(KeyTxID, event.TxID),
+		slog.Int(KeyDepth, event.Depth),
+	}
+	switch event.Type {
+	case mtx.TxEventBeginEnd, mtx.TxEventCommitEnd, mtx.TxEventRollbackEnd:
+		attrs = append(attrs, slog.Duration(KeyDuration, event.Duration))
+	}
+	if event.TxDuration > 0 {
+		attrs = append(attrs, slog.Duration(KeyTxDuration, event.TxDuration))
+	}
+	if event.Err != nil {
+		attrs = append(attrs, Err(event.Err))
+	}
+	logger.LogAttrs(ctx, level, "tx "+event.Type.String(), attrs...)
+}
+
+func (l *TxLogger) level(event mtx.TxEvent) slog.Level {
+	if event.Err != nil {
+		return l.errorLevel
+	}
+	if level, ok := l.levels[event.Type]; ok {
+		return level
+	}
+	return slog.LevelDebug
+}
+
+func loggerOrDefault(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+	return logger
+}
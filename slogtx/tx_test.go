@@ -0,0 +1,190 @@
+package slogtx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/kozmod/oniontx/internal/errors"
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+	"github.com/kozmod/oniontx/mtx"
+)
+
+type (
+	txMock struct {
+		commitErr error
+	}
+
+	beginnerMock struct {
+		tx *txMock
+	}
+
+	record struct {
+		Level        string `json:"level"`
+		Msg          string `json:"msg"`
+		Event        string `json:"event"`
+		TxID         uint64 `json:"tx_id"`
+		Depth        int    `json:"depth"`
+		Duration     *int64 `json:"duration"`
+		Operation    string `json:"operation"`
+		StepName     string `json:"step_name"`
+		StepPosition uint32 `json:"step_position"`
+		Attempt      uint32 `json:"attempt"`
+		Status       string `json:"status"`
+		Error        *struct {
+			Message string `json:"message"`
+			Errors  map[string]struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		} `json:"error"`
+	}
+)
+
+func (t *txMock) Commit(context.Context) error {
+	return t.commitErr
+}
+
+func (t *txMock) Rollback(context.Context) error {
+	return nil
+}
+
+func (b *beginnerMock) BeginTx(context.Context) (*txMock, error) {
+	return b.tx, nil
+}
+
+func newLogger(buf *bytes.Buffer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: level}))
+}
+
+func records(t *testing.T, buf *bytes.Buffer) []record {
+	t.Helper()
+	var (
+		res     []record
+		scanner = bufio.NewScanner(buf)
+	)
+	for scanner.Scan() {
+		var r record
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &r))
+		res = append(res, r)
+	}
+	return res
+}
+
+func newTransactor(tx *txMock, observer mtx.TxObserver) *mtx.Transactor[*beginnerMock, *txMock] {
+	b := &beginnerMock{tx: tx}
+	return mtx.NewTransactor[*beginnerMock, *txMock](b, mtx.NewContextOperator[*beginnerMock, *txMock](b)).
+		WithObserver(observer)
+}
+
+func Test_TxLogger(t *testing.T) {
+	t.Run("commit", func(t *testing.T) {
+		var (
+			buf bytes.Buffer
+			ctx = context.Background()
+			tr  = newTransactor(&txMock{}, NewTxLogger(newLogger(&buf, slog.LevelDebug)).
+				WithLevel(mtx.TxEventCommitEnd, slog.LevelInfo))
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			return tr.WithinTx(ctx, func(context.Context) error {
+				return nil
+			})
+		})
+		assert.NoError(t, err)
+
+		rs := records(t, &buf)
+		assert.Equal(t, 5, len(rs))
+		assert.Equal(t, fmt.Sprint([]string{"BeginStart", "BeginEnd", "Join", "CommitStart", "CommitEnd"}), fmt.Sprint(events(rs)))
+		assert.Equal(t, "tx CommitEnd", rs[4].Msg)
+		assert.Equal(t, slog.LevelInfo.String(), rs[4].Level)
+		assert.Equal(t, slog.LevelDebug.String(), rs[0].Level)
+		assert.Equal(t, 1, rs[2].Depth)
+		assert.True(t, rs[0].TxID != 0)
+		assert.Equal(t, rs[0].TxID, rs[4].TxID)
+		assert.True(t, rs[4].Duration != nil)
+		assert.True(t, rs[0].Duration == nil)
+		assert.True(t, rs[4].Error == nil)
+	})
+	t.Run("commit_failed", func(t *testing.T) {
+		var (
+			buf       bytes.Buffer
+			ctx       = context.Background()
+			commitErr = fmt.Errorf("commit error")
+			tr        = newTransactor(&txMock{commitErr: commitErr}, NewTxLogger(newLogger(&buf, slog.LevelWarn)).
+					WithErrorLevel(slog.LevelWarn))
+		)
+		err := tr.WithinTx(ctx, func(context.Context) error {
+			return nil
+		})
+		assert.ErrorIs(t, err, mtx.ErrCommitFailed)
+
+		rs := records(t, &buf)
+		assert.Equal(t, 1, len(rs))
+		assert.Equal(t, "CommitEnd", rs[0].Event)
+		assert.Equal(t, slog.LevelWarn.String(), rs[0].Level)
+		assert.Equal(t, commitErr.Error(), rs[0].Error.Message)
+	})
+	t.Run("before_commit_veto", func(t *testing.T) {
+		var (
+			buf     bytes.Buffer
+			ctx     = context.Background()
+			hookErr = fmt.Errorf("hook error")
+			tr      = newTransactor(&txMock{}, NewTxLogger(newLogger(&buf, slog.LevelDebug)))
+		)
+		err := tr.WithinTx(ctx, func(ctx context.Context) error {
+			return mtx.BeforeCommit(ctx, func(context.Context) error {
+				return hookErr
+			})
+		})
+		assert.ErrorIs(t, err, mtx.ErrBeforeCommitFailed)
+
+		rs := records(t, &buf)
+		assert.Equal(t, fmt.Sprint([]string{"BeginStart", "BeginEnd", "RollbackStart", "RollbackEnd"}), fmt.Sprint(events(rs)))
+		assert.True(t, rs[3].Error == nil)
+	})
+	t.Run("default_logger", func(t *testing.T) {
+		var (
+			buf      bytes.Buffer
+			ctx      = context.Background()
+			original = slog.Default()
+		)
+		slog.SetDefault(newLogger(&buf, slog.LevelDebug))
+		t.Cleanup(func() {
+			slog.SetDefault(original)
+		})
+
+		err := newTransactor(&txMock{}, NewTxLogger(nil)).WithinTx(ctx, func(context.Context) error {
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 4, len(records(t, &buf)))
+	})
+}
+
+func Test_ErrorAttr(t *testing.T) {
+	var (
+		buf    bytes.Buffer
+		logger = newLogger(&buf, slog.LevelInfo)
+		errA   = fmt.Errorf("errA")
+		errB   = fmt.Errorf("errB")
+		err    = fmt.Errorf("wrap: %w", fmt.Errorf("%w", errors.Join(errA, errB)))
+	)
+	logger.Info("msg", Err(err))
+
+	rs := records(t, &buf)
+	assert.Equal(t, 1, len(rs))
+	assert.Equal(t, err.Error(), rs[0].Error.Message)
+	assert.Equal(t, errA.Error(), rs[0].Error.Errors["0"].Message)
+	assert.Equal(t, errB.Error(), rs[0].Error.Errors["1"].Message)
+}
+
+func events(rs []record) []string {
+	res := make([]string, 0, len(rs))
+	for _, r := range rs {
+		res = append(res, r.Event)
+	}
+	return res
+}
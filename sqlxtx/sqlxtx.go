@@ -0,0 +1,133 @@
+// Package sqlxtx provides the [sqlx.DB] implementation of the mtx contracts.
+package sqlxtx
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/kozmod/oniontx/mtx"
+)
+
+// Executor represents common methods of [sqlx.DB] and [sqlx.Tx].
+type Executor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	Prepare(query string) (*sql.Stmt, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+
+	DriverName() string
+	Rebind(query string) string
+	BindNamed(query string, arg any) (string, []any, error)
+
+	Queryx(query string, args ...any) (*sqlx.Rows, error)
+	QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error)
+	QueryRowx(query string, args ...any) *sqlx.Row
+	QueryRowxContext(ctx context.Context, query string, args ...any) *sqlx.Row
+	Get(dest any, query string, args ...any) error
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+	Select(dest any, query string, args ...any) error
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
+
+	NamedExec(query string, arg any) (sql.Result, error)
+	NamedExecContext(ctx context.Context, query string, arg any) (sql.Result, error)
+	NamedQuery(query string, arg any) (*sqlx.Rows, error)
+
+	Preparex(query string) (*sqlx.Stmt, error)
+	PreparexContext(ctx context.Context, query string) (*sqlx.Stmt, error)
+	PrepareNamed(query string) (*sqlx.NamedStmt, error)
+	PrepareNamedContext(ctx context.Context, query string) (*sqlx.NamedStmt, error)
+}
+
+// Wrapper wraps [sqlx.DB] and implements [mtx.TxBeginner].
+type Wrapper struct {
+	*sqlx.DB
+}
+
+// BeginTx starts a transaction.
+func (w *Wrapper) BeginTx(ctx context.Context) (*TxWrapper, error) {
+	return w.begin(ctx, nil)
+}
+
+// BeginTxWithOptions starts a transaction with [mtx.TxOptions] and implements [mtx.TxBeginnerWithOptions].
+// Deferrable is not supported by database/sql and is ignored.
+func (w *Wrapper) BeginTxWithOptions(ctx context.Context, opts mtx.TxOptions) (*TxWrapper, error) {
+	return w.begin(ctx, &sql.TxOptions{
+		Isolation: sql.IsolationLevel(opts.Isolation),
+		ReadOnly:  opts.ReadOnly,
+	})
+}
+
+func (w *Wrapper) begin(ctx context.Context, opts *sql.TxOptions) (*TxWrapper, error) {
+	tx, err := w.DB.BeginTxx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &TxWrapper{Tx: tx}, nil
+}
+
+// TxWrapper wraps [sqlx.Tx] and implements [mtx.Tx]
+type TxWrapper struct {
+	*sqlx.Tx
+}
+
+// Rollback aborts the transaction.
+func (t *TxWrapper) Rollback(_ context.Context) error {
+	return t.Tx.Rollback()
+}
+
+// Commit commits the transaction.
+func (t *TxWrapper) Commit(_ context.Context) error {
+	return t.Tx.Commit()
+}
+
+// Transactor manage a transaction for single [sqlx.DB] instance.
+type Transactor struct {
+	*mtx.Transactor[*Wrapper, *TxWrapper]
+}
+
+// NewTransactor returns new Transactor ([sqlx] implementation).
+func NewTransactor(db *sqlx.DB) *Transactor {
+	var (
+		base       = Wrapper{DB: db}
+		operator   = mtx.NewContextOperator[*Wrapper, *TxWrapper](&base)
+		transactor = mtx.NewTransactor[*Wrapper, *TxWrapper](&base, operator)
+	)
+	return &Transactor{
+		Transactor: transactor,
+	}
+}
+
+// With returns a new [Transactor] with the configured [mtx.Transactor].
+func (t *Transactor) With(configure func(t *mtx.Transactor[*Wrapper, *TxWrapper]) *mtx.Transactor[*Wrapper, *TxWrapper]) *Transactor {
+	return &Transactor{
+		Transactor: configure(t.Transactor),
+	}
+}
+
+// TryGetTx returns pointer of [sqlx.Tx] and "true" from [context.Context] or return `false`.
+func (t *Transactor) TryGetTx(ctx context.Context) (*sqlx.Tx, bool) {
+	wrapper, ok := t.Transactor.TryGetTx(ctx)
+	if !ok || wrapper == nil || wrapper.Tx == nil {
+		return nil, false
+	}
+	return wrapper.Tx, true
+}
+
+// TxBeginner returns pointer of [sqlx.DB].
+func (t *Transactor) TxBeginner() *sqlx.DB {
+	return t.Transactor.TxBeginner().DB
+}
+
+// GetExecutor returns Executor implementation ([*sqlx.DB] or [*sqlx.Tx] default wrappers).
+func (t *Transactor) GetExecutor(ctx context.Context) Executor {
+	if tx, ok := t.TryGetTx(ctx); ok {
+		return tx
+	}
+	return t.TxBeginner()
+}
@@ -0,0 +1,171 @@
+package sqlxtx
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+	"github.com/kozmod/oniontx/mtx"
+)
+
+func init() {
+	// the modernc.org/sqlite driver name is unknown to sqlx.
+	sqlx.BindDriver("sqlite", sqlx.QUESTION)
+}
+
+type user struct {
+	ID   int64  `db:"id"`
+	Name string `db:"name"`
+}
+
+func newTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	db, err := sqlx.Open("sqlite", filepath.Join(t.TempDir(), "sqlx.db"))
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL)`)
+	assert.NoError(t, err)
+	return db
+}
+
+// repository uses only Executor, so it works with and without a transaction.
+type repository struct {
+	transactor *Transactor
+}
+
+func (r *repository) create(ctx context.Context, u user) error {
+	_, err := r.transactor.GetExecutor(ctx).NamedExecContext(ctx, `INSERT INTO users (id, name) VALUES (:id, :name)`, u)
+	return err
+}
+
+func (r *repository) get(ctx context.Context, id int64) (user, error) {
+	var (
+		u    user
+		exec = r.transactor.GetExecutor(ctx)
+	)
+	err := exec.GetContext(ctx, &u, exec.Rebind(`SELECT id, name FROM users WHERE id = ?`), id)
+	return u, err
+}
+
+func (r *repository) list(ctx context.Context) ([]user, error) {
+	var users []user
+	err := r.transactor.GetExecutor(ctx).SelectContext(ctx, &users, `SELECT id, name FROM users ORDER BY id`)
+	return users, err
+}
+
+func (r *repository) rename(ctx context.Context, id int64, name string) error {
+	exec := r.transactor.GetExecutor(ctx)
+	stmt, err := exec.PreparexContext(ctx, exec.Rebind(`UPDATE users SET name = ? WHERE id = ?`))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+	_, err = stmt.ExecContext(ctx, name, id)
+	return err
+}
+
+func Test_Transactor(t *testing.T) {
+	t.Run("commit", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			transactor = NewTransactor(newTestDB(t))
+			repo       = repository{transactor: transactor}
+		)
+		err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+			_, ok := transactor.TryGetTx(ctx)
+			assert.True(t, ok)
+			if err := repo.create(ctx, user{ID: 1, Name: "a"}); err != nil {
+				return err
+			}
+			if err := repo.create(ctx, user{ID: 2, Name: "b"}); err != nil {
+				return err
+			}
+			if err := repo.rename(ctx, 2, "c"); err != nil {
+				return err
+			}
+			u, err := repo.get(ctx, 2)
+			if err != nil {
+				return err
+			}
+			assert.Equal(t, user{ID: 2, Name: "c"}, u)
+			return nil
+		})
+		assert.NoError(t, err)
+
+		users, err := repo.list(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(users))
+		assert.Equal(t, user{ID: 1, Name: "a"}, users[0])
+		assert.Equal(t, user{ID: 2, Name: "c"}, users[1])
+	})
+	t.Run("rollback", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			transactor = NewTransactor(newTestDB(t))
+			repo       = repository{transactor: transactor}
+			expErr     = fmt.Errorf("call error")
+		)
+		err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+			if err := repo.create(ctx, user{ID: 1, Name: "a"}); err != nil {
+				return err
+			}
+			return expErr
+		})
+		assert.ErrorIs(t, err, expErr)
+		assert.ErrorIs(t, err, mtx.ErrRollbackSuccess)
+
+		users, err := repo.list(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, len(users))
+	})
+	t.Run("without_tx", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			transactor = NewTransactor(newTestDB(t))
+			repo       = repository{transactor: transactor}
+		)
+		_, ok := transactor.TryGetTx(ctx)
+		assert.False(t, ok)
+		assert.NoError(t, repo.create(ctx, user{ID: 1, Name: "a"}))
+		u, err := repo.get(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, user{ID: 1, Name: "a"}, u)
+	})
+	t.Run("tx_options", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			transactor = NewTransactor(newTestDB(t)).With(func(t *mtx.Transactor[*Wrapper, *TxWrapper]) *mtx.Transactor[*Wrapper, *TxWrapper] {
+				return t.WithTxOptions(mtx.Isolation(mtx.LevelSerializable))
+			})
+			repo = repository{transactor: transactor}
+		)
+		err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+			return repo.create(ctx, user{ID: 1, Name: "a"})
+		})
+		assert.NoError(t, err)
+	})
+	t.Run("begin_error", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			db         = newTestDB(t)
+			transactor = NewTransactor(db)
+		)
+		assert.NoError(t, db.Close())
+		err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+			t.Fatalf("should not have been called")
+			return nil
+		})
+		assert.ErrorIs(t, err, mtx.ErrBeginTx)
+	})
+}
@@ -0,0 +1,225 @@
+// Package stdlib provides the database/sql implementation of the mtx contracts.
+package stdlib
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/kozmod/oniontx/mtx"
+)
+
+// Executor represents common methods of sql.DB, sql.Conn (see ConnWrapper) and sql.Tx.
+type Executor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	Prepare(query string) (*sql.Stmt, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// StmtExecutor is an Executor which re-binds the prepared statements to the active transaction.
+type StmtExecutor interface {
+	Executor
+	// StmtContext returns the transaction-specific statement from the existing statement
+	// (see [sql.Tx.StmtContext]) or the statement itself when there is no transaction.
+	// The transaction-specific statement is closed when the transaction is committed or rolled back.
+	StmtContext(ctx context.Context, stmt *sql.Stmt) *sql.Stmt
+}
+
+// Beginner is the constraint of the transaction beginners of the package (Wrapper and ConnWrapper).
+type Beginner interface {
+	mtx.TxBeginner[*TxWrapper]
+	mtx.TxBeginnerWithOptions[*TxWrapper]
+	Executor
+}
+
+// Wrapper wraps [sql.DB] and implements [mtx.TxBeginner].
+type Wrapper struct {
+	*sql.DB
+}
+
+// BeginTx starts a transaction.
+func (db *Wrapper) BeginTx(ctx context.Context) (*TxWrapper, error) {
+	return beginTx(ctx, db.DB.BeginTx, nil)
+}
+
+// BeginTxWithOptions starts a transaction with [mtx.TxOptions] and implements [mtx.TxBeginnerWithOptions].
+func (db *Wrapper) BeginTxWithOptions(ctx context.Context, opts mtx.TxOptions) (*TxWrapper, error) {
+	return beginTx(ctx, db.DB.BeginTx, toSQLTxOptions(opts))
+}
+
+// ConnWrapper wraps [sql.Conn] and implements [mtx.TxBeginner].
+// All transactions are executed on the single connection, so they must not overlap.
+//
+// ConnWrapper implements Executor: the methods without a context use [context.Background].
+type ConnWrapper struct {
+	*sql.Conn
+}
+
+// BeginTx starts a transaction.
+func (c *ConnWrapper) BeginTx(ctx context.Context) (*TxWrapper, error) {
+	return beginTx(ctx, c.Conn.BeginTx, nil)
+}
+
+// BeginTxWithOptions starts a transaction with [mtx.TxOptions] and implements [mtx.TxBeginnerWithOptions].
+func (c *ConnWrapper) BeginTxWithOptions(ctx context.Context, opts mtx.TxOptions) (*TxWrapper, error) {
+	return beginTx(ctx, c.Conn.BeginTx, toSQLTxOptions(opts))
+}
+
+// Exec executes a query without returning any rows.
+func (c *ConnWrapper) Exec(query string, args ...any) (sql.Result, error) {
+	return c.Conn.ExecContext(context.Background(), query, args...)
+}
+
+// Query executes a query that returns rows.
+func (c *ConnWrapper) Query(query string, args ...any) (*sql.Rows, error) {
+	return c.Conn.QueryContext(context.Background(), query, args...)
+}
+
+// QueryRow executes a query that is expected to return at most one row.
+func (c *ConnWrapper) QueryRow(query string, args ...any) *sql.Row {
+	return c.Conn.QueryRowContext(context.Background(), query, args...)
+}
+
+// Prepare creates a prepared statement on the connection.
+func (c *ConnWrapper) Prepare(query string) (*sql.Stmt, error) {
+	return c.Conn.PrepareContext(context.Background(), query)
+}
+
+// beginTx starts a transaction by the begin function of [sql.DB] or [sql.Conn].
+func beginTx(
+	ctx context.Context,
+	begin func(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error),
+	opts *sql.TxOptions,
+) (*TxWrapper, error) {
+	tx, err := begin(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &TxWrapper{Tx: tx}, nil
+}
+
+// toSQLTxOptions converts [mtx.TxOptions] to [sql.TxOptions].
+// The mtx.IsolationLevel values are equal to the sql.IsolationLevel values.
+// Deferrable is not supported by database/sql and is ignored.
+func toSQLTxOptions(opts mtx.TxOptions) *sql.TxOptions {
+	return &sql.TxOptions{
+		Isolation: sql.IsolationLevel(opts.Isolation),
+		ReadOnly:  opts.ReadOnly,
+	}
+}
+
+// TxOptions returns the [mtx.TxOption] which sets the options of [sql.TxOptions].
+//
+// Example:
+//
+//	serializable := transactor.Transactor.WithTxOptions(stdlib.TxOptions(sql.TxOptions{
+//	    Isolation: sql.LevelSerializable,
+//	}))
+func TxOptions(opts sql.TxOptions) mtx.TxOption {
+	return func(o *mtx.TxOptions) {
+		o.Isolation = mtx.IsolationLevel(opts.Isolation)
+		o.ReadOnly = opts.ReadOnly
+	}
+}
+
+// TxWrapper wraps [sql.Tx] and implements [mtx.Tx].
+type TxWrapper struct {
+	*sql.Tx
+}
+
+// Rollback aborts the transaction.
+func (t *TxWrapper) Rollback(_ context.Context) error {
+	return t.Tx.Rollback()
+}
+
+// Commit commits the transaction.
+func (t *TxWrapper) Commit(_ context.Context) error {
+	return t.Tx.Commit()
+}
+
+// Transactor manage a transaction for single [sql.DB] or [sql.Conn] instance.
+type Transactor[B Beginner] struct {
+	*mtx.Transactor[B, *TxWrapper]
+}
+
+// NewTransactor returns new [Transactor] for [sql.DB].
+func NewTransactor(db *sql.DB) *Transactor[*Wrapper] {
+	return newTransactor(&Wrapper{DB: db})
+}
+
+// NewConnTransactor returns new [Transactor] for [sql.Conn].
+func NewConnTransactor(conn *sql.Conn) *Transactor[*ConnWrapper] {
+	return newTransactor(&ConnWrapper{Conn: conn})
+}
+
+func newTransactor[B Beginner](beginner B) *Transactor[B] {
+	operator := mtx.NewContextOperator[B, *TxWrapper](beginner)
+	return &Transactor[B]{
+		Transactor: mtx.NewTransactor[B, *TxWrapper](beginner, operator),
+	}
+}
+
+// With returns a new [Transactor] with the configured [mtx.Transactor].
+func (t *Transactor[B]) With(configure func(t *mtx.Transactor[B, *TxWrapper]) *mtx.Transactor[B, *TxWrapper]) *Transactor[B] {
+	return &Transactor[B]{
+		Transactor: configure(t.Transactor),
+	}
+}
+
+// WithinTx execute all queries with [sql.Tx].
+//
+// Creates new [sql.Tx] or reuse [sql.Tx] obtained from [context.Context].
+func (t *Transactor[B]) WithinTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	return t.Transactor.WithinTx(ctx, fn)
+}
+
+// TryGetTx returns pointer of [sql.Tx] and "true" from [context.Context] or return `false`.
+func (t *Transactor[B]) TryGetTx(ctx context.Context) (*sql.Tx, bool) {
+	wrapper, ok := t.Transactor.TryGetTx(ctx)
+	if !ok || wrapper == nil || wrapper.Tx == nil {
+		return nil, false
+	}
+	return wrapper.Tx, true
+}
+
+// GetExecutor returns [Executor] implementation ([sql.Tx] from [context.Context] or the beginner).
+func (t *Transactor[B]) GetExecutor(ctx context.Context) Executor {
+	tx, ok := t.Transactor.TryGetTx(ctx)
+	if !ok || tx == nil {
+		return t.Transactor.TxBeginner()
+	}
+	return tx
+}
+
+// GetStmtExecutor returns [StmtExecutor] implementation ([sql.Tx] from [context.Context] or the beginner).
+//
+// Example:
+//
+//	stmt, err := db.PrepareContext(ctx, `INSERT INTO users (name) VALUES ($1)`)
+//	...
+//	err = transactor.WithinTx(ctx, func(ctx context.Context) error {
+//	    exec := transactor.GetStmtExecutor(ctx)
+//	    _, err := exec.StmtContext(ctx, stmt).ExecContext(ctx, name)
+//	    return err
+//	})
+func (t *Transactor[B]) GetStmtExecutor(ctx context.Context) StmtExecutor {
+	tx, ok := t.Transactor.TryGetTx(ctx)
+	if !ok || tx == nil {
+		return beginnerExecutor{Executor: t.Transactor.TxBeginner()}
+	}
+	return tx
+}
+
+// beginnerExecutor is the StmtExecutor of the beginner (without a transaction).
+type beginnerExecutor struct {
+	Executor
+}
+
+// StmtContext returns the statement itself.
+func (beginnerExecutor) StmtContext(_ context.Context, stmt *sql.Stmt) *sql.Stmt {
+	return stmt
+}
@@ -0,0 +1,199 @@
+package stdlib
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/kozmod/oniontx/internal/testtool/assert"
+	"github.com/kozmod/oniontx/mtx"
+)
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "stdlib.db"))
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`CREATE TABLE users (name TEXT NOT NULL)`)
+	assert.NoError(t, err)
+	return db
+}
+
+func insertUser(ctx context.Context, exec Executor, name string) error {
+	_, err := exec.ExecContext(ctx, `INSERT INTO users (name) VALUES (?)`, name)
+	return err
+}
+
+func countUsers(t *testing.T, exec Executor) int {
+	t.Helper()
+	var count int
+	assert.NoError(t, exec.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&count))
+	return count
+}
+
+func Test_Transactor(t *testing.T) {
+	t.Run("commit", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			db         = newTestDB(t)
+			transactor = NewTransactor(db)
+		)
+		err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+			_, ok := transactor.TryGetTx(ctx)
+			assert.True(t, ok)
+			if err := insertUser(ctx, transactor.GetExecutor(ctx), "a"); err != nil {
+				return err
+			}
+			return transactor.WithinTx(ctx, func(ctx context.Context) error {
+				return insertUser(ctx, transactor.GetExecutor(ctx), "b")
+			})
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, countUsers(t, db))
+	})
+	t.Run("rollback", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			db         = newTestDB(t)
+			transactor = NewTransactor(db)
+			expErr     = fmt.Errorf("call error")
+		)
+		err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+			if err := insertUser(ctx, transactor.GetExecutor(ctx), "a"); err != nil {
+				return err
+			}
+			return expErr
+		})
+		assert.ErrorIs(t, err, expErr)
+		assert.ErrorIs(t, err, mtx.ErrRollbackSuccess)
+		assert.Equal(t, 0, countUsers(t, db))
+	})
+	t.Run("executor_without_tx", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			db         = newTestDB(t)
+			transactor = NewTransactor(db)
+		)
+		_, ok := transactor.TryGetTx(ctx)
+		assert.False(t, ok)
+		assert.NoError(t, insertUser(ctx, transactor.GetExecutor(ctx), "a"))
+		assert.Equal(t, 1, countUsers(t, db))
+	})
+	t.Run("tx_options", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			db         = newTestDB(t)
+			transactor = NewTransactor(db).With(func(t *mtx.Transactor[*Wrapper, *TxWrapper]) *mtx.Transactor[*Wrapper, *TxWrapper] {
+				return t.WithTxOptions(TxOptions(sql.TxOptions{Isolation: sql.LevelSerializable}))
+			})
+		)
+		err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+			return insertUser(ctx, transactor.GetExecutor(ctx), "a")
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, countUsers(t, db))
+	})
+	t.Run("begin_error", func(t *testing.T) {
+		var (
+			ctx        = context.Background()
+			db         = newTestDB(t)
+			transactor = NewTransactor(db)
+		)
+		assert.NoError(t, db.Close())
+		err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+			t.Fatalf("should not have been called")
+			return nil
+		})
+		assert.ErrorIs(t, err, mtx.ErrBeginTx)
+	})
+}
+
+func Test_TxOptions(t *testing.T) {
+	var opts mtx.TxOptions
+	TxOptions(sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})(&opts)
+	assert.Equal(t, mtx.LevelRepeatableRead, opts.Isolation)
+	assert.True(t, opts.ReadOnly)
+
+	sqlOpts := toSQLTxOptions(mtx.TxOptions{Isolation: mtx.LevelSerializable, ReadOnly: true, Deferrable: true})
+	assert.Equal(t, sql.LevelSerializable, sqlOpts.Isolation)
+	assert.True(t, sqlOpts.ReadOnly)
+}
+
+func Test_ConnTransactor(t *testing.T) {
+	var (
+		ctx = context.Background()
+		db  = newTestDB(t)
+	)
+	conn, err := db.Conn(ctx)
+	assert.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	transactor := NewConnTransactor(conn)
+	_, err = conn.ExecContext(ctx, `CREATE TEMP TABLE conn_users (name TEXT NOT NULL)`)
+	assert.NoError(t, err)
+
+	err = transactor.WithinTx(ctx, func(ctx context.Context) error {
+		_, err := transactor.GetExecutor(ctx).ExecContext(ctx, `INSERT INTO conn_users (name) VALUES (?)`, "a")
+		return err
+	})
+	assert.NoError(t, err)
+
+	err = transactor.WithinTx(ctx, func(ctx context.Context) error {
+		_, err := transactor.GetExecutor(ctx).ExecContext(ctx, `INSERT INTO conn_users (name) VALUES (?)`, "b")
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("call error")
+	})
+	assert.ErrorIs(t, err, mtx.ErrRollbackSuccess)
+
+	// the temporary table is visible only on the connection.
+	var count int
+	assert.NoError(t, transactor.GetExecutor(ctx).QueryRow(`SELECT COUNT(*) FROM conn_users`).Scan(&count))
+	assert.Equal(t, 1, count)
+}
+
+func Test_Transactor_GetStmtExecutor(t *testing.T) {
+	var (
+		ctx        = context.Background()
+		db         = newTestDB(t)
+		transactor = NewTransactor(db)
+	)
+	stmt, err := db.PrepareContext(ctx, `INSERT INTO users (name) VALUES (?)`)
+	assert.NoError(t, err)
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	t.Run("without_tx", func(t *testing.T) {
+		exec := transactor.GetStmtExecutor(ctx)
+		assert.Equal(t, stmt, exec.StmtContext(ctx, stmt))
+		_, err := exec.StmtContext(ctx, stmt).ExecContext(ctx, "a")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, countUsers(t, db))
+	})
+	t.Run("rebind_to_tx", func(t *testing.T) {
+		err := transactor.WithinTx(ctx, func(ctx context.Context) error {
+			exec := transactor.GetStmtExecutor(ctx)
+			txStmt := exec.StmtContext(ctx, stmt)
+			assert.True(t, txStmt != stmt)
+			if _, err := txStmt.ExecContext(ctx, "b"); err != nil {
+				return err
+			}
+			assert.Equal(t, 2, countUsers(t, exec))
+			return fmt.Errorf("call error")
+		})
+		assert.ErrorIs(t, err, mtx.ErrRollbackSuccess)
+		assert.Equal(t, 1, countUsers(t, db))
+	})
+}
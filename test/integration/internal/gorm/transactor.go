@@ -31,6 +31,19 @@ func (w *Wrapper) BeginTx(_ context.Context) (*Wrapper, error) {
 	return &Wrapper{DB: tx}, nil
 }
 
+// BeginTxWithOptions starts a transaction with [mtx.TxOptions] and implements [mtx.TxBeginnerWithOptions].
+// The options override the options passed to [NewDB].
+func (w *Wrapper) BeginTxWithOptions(ctx context.Context, opts mtx.TxOptions) (*Wrapper, error) {
+	tx := w.WithContext(ctx).Begin(&sql.TxOptions{
+		Isolation: sql.IsolationLevel(opts.Isolation),
+		ReadOnly:  opts.ReadOnly,
+	})
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &Wrapper{DB: tx}, nil
+}
+
 // Rollback aborts the transaction.
 func (w *Wrapper) Rollback(_ context.Context) error {
 	tx := w.DB
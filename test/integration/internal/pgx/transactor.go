@@ -7,6 +7,7 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 
 	"github.com/kozmod/oniontx/mtx"
+	"github.com/kozmod/oniontx/pgxtx"
 )
 
 // Executor represents common methods of [pgx.Conn] and [pgx.Tx].
@@ -29,6 +30,12 @@ func (w *Wrapper) BeginTx(ctx context.Context) (*TxWrapper, error) {
 	return &TxWrapper{Tx: tx}, err
 }
 
+// BeginTxWithOptions starts a transaction with [mtx.TxOptions] and implements [mtx.TxBeginnerWithOptions].
+func (w *Wrapper) BeginTxWithOptions(ctx context.Context, opts mtx.TxOptions) (*TxWrapper, error) {
+	tx, err := w.Conn.BeginTx(ctx, pgxtx.ToPgxTxOptions(opts))
+	return &TxWrapper{Tx: tx}, err
+}
+
 // TxWrapper wraps [pgx.Tx] and implements [mtx.Tx]
 type TxWrapper struct {
 	pgx.Tx
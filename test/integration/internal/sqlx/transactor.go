@@ -34,6 +34,15 @@ func (w *Wrapper) BeginTx(ctx context.Context) (*TxWrapper, error) {
 	return &TxWrapper{Tx: tx}, err
 }
 
+// BeginTxWithOptions starts a transaction with [mtx.TxOptions] and implements [mtx.TxBeginnerWithOptions].
+func (w *Wrapper) BeginTxWithOptions(ctx context.Context, opts mtx.TxOptions) (*TxWrapper, error) {
+	tx, err := w.DB.BeginTxx(ctx, &sql.TxOptions{
+		Isolation: sql.IsolationLevel(opts.Isolation),
+		ReadOnly:  opts.ReadOnly,
+	})
+	return &TxWrapper{Tx: tx}, err
+}
+
 // TxWrapper wraps [sqlx.Tx] and implements [mtx.Tx]
 type TxWrapper struct {
 	*sqlx.Tx
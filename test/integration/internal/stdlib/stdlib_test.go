@@ -6,6 +6,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/kozmod/oniontx/mtx"
 	"github.com/kozmod/oniontx/test/integration/internal/entity"
 )
 
@@ -72,6 +73,29 @@ func Test_UseCase(t *testing.T) {
 			assert.Len(t, records, 0)
 		}
 	})
+	t.Run("error_and_rollback_in_read_only_tx", func(t *testing.T) {
+		t.Cleanup(cleanupFn)
+
+		var (
+			ctx         = context.Background()
+			transactor  = NewTransactor(db)
+			repositoryA = NewTextRepository(transactor, false)
+			repositoryB = NewTextRepository(transactor, false)
+			useCase     = NewUseCase(repositoryA, repositoryB,
+				transactor.WithTxOptions(mtx.Isolation(mtx.LevelRepeatableRead), mtx.ReadOnly()),
+			)
+		)
+
+		err := useCase.CreateTextRecords(ctx, textRecord)
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, mtx.ErrRollbackSuccess)
+
+		{
+			records, err := GetTextRecords(db)
+			assert.NoError(t, err)
+			assert.Len(t, records, 0)
+		}
+	})
 	t.Run("ctx_canceled_error_and_rollback", func(t *testing.T) {
 		t.Cleanup(cleanupFn)
 
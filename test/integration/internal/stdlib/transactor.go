@@ -31,6 +31,15 @@ func (db Wrapper) BeginTx(ctx context.Context) (*TxWrapper, error) {
 	return &TxWrapper{Tx: tx}, err
 }
 
+// BeginTxWithOptions starts a transaction with [mtx.TxOptions] and implements [mtx.TxBeginnerWithOptions].
+func (db Wrapper) BeginTxWithOptions(ctx context.Context, opts mtx.TxOptions) (*TxWrapper, error) {
+	tx, err := db.DB.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.IsolationLevel(opts.Isolation),
+		ReadOnly:  opts.ReadOnly,
+	})
+	return &TxWrapper{Tx: tx}, err
+}
+
 // TxWrapper wraps [sql.Tx] and implements [mtx.Tx].
 type TxWrapper struct {
 	*sql.Tx